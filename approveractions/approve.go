@@ -0,0 +1,93 @@
+package approveractions
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+)
+
+func (a *ApproverActor) Approve(ctx context.Context, b schema.Bundle) (schema.Bundle, error) {
+	// the signature bundle should be as follows
+	// 1 - INIT
+	// 2 - AUTHENTICATED
+	// 3 - ACCESS_REQUEST
+	// 4 - DECISION
+	if len(b) != 4 {
+		return nil, errors.New("bundle did not contain 4 envelopes")
+	}
+
+	requestEnv := b[2]
+	// double check that we're approving an access request payload
+	_, err := schema.DeserializePayload(requestEnv.Payload, types.PayloadAccessRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := schema.DeserializePayload(b[3].Payload, types.PayloadDecision)
+	if err != nil {
+		return nil, err
+	}
+	decision := p.(*schema.DecisionPayload)
+	if decision.Decision.EffectiveOutcome() != schema.DecisionPendingApproval {
+		return nil, errors.New("decision does not require approval")
+	}
+
+	approvalPayload := schema.NewApprovalPayload(schema.Approval{
+		ApproverID:    a.id,
+		RequestDigest: requestEnv.Digest(),
+		ApprovedAt:    time.Now(),
+	})
+	approvalEnv, err := schema.NewChainedEnvelope(b, approvalPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	err = approvalEnv.Sign(ctx, a.signer)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := append(b, approvalEnv)
+	return bundle, nil
+}
+
+// CounterSign adds the approver's signature to an existing APPROVAL envelope, for decisions
+// which require approval from several members of an approver group.
+func (a *ApproverActor) CounterSign(ctx context.Context, b schema.Bundle) (schema.Bundle, error) {
+	// the signature bundle should be as follows
+	// 1 - INIT
+	// 2 - AUTHENTICATED
+	// 3 - ACCESS_REQUEST
+	// 4 - DECISION
+	// 5 - APPROVAL
+	if len(b) != 5 {
+		return nil, errors.New("bundle did not contain 5 envelopes")
+	}
+
+	approvalEnv := b[4]
+	p, err := schema.DeserializePayload(approvalEnv.Payload, types.PayloadApproval)
+	if err != nil {
+		return nil, err
+	}
+	// double check that the approval is for the access request in this bundle
+	if p.(*schema.ApprovalPayload).Approval.RequestDigest != b[2].Digest() {
+		return nil, errors.New("approval does not refer to the access request")
+	}
+
+	err = approvalEnv.Sign(ctx, a.signer)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := schema.Bundle{
+		b[0],
+		b[1],
+		b[2],
+		b[3],
+		approvalEnv,
+	}
+	return bundle, nil
+}
@@ -0,0 +1,20 @@
+package approveractions
+
+import (
+	"github.com/common-fate/attestations/schema"
+)
+
+// ApproverActor approves access requests which the identity server
+// decided required approval.
+type ApproverActor struct {
+	id     string
+	signer schema.EnvelopeSigner
+}
+
+// New creates an approver actor. The ID must match the approver's ID in the Facts.
+func New(id string, signer schema.EnvelopeSigner) *ApproverActor {
+	return &ApproverActor{
+		id:     id,
+		signer: signer,
+	}
+}
@@ -2,19 +2,28 @@ package clientactions
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"crypto/x509"
+	"time"
 
 	"github.com/common-fate/attestations/schema"
 )
 
-func (a *ClientActor) Init(ctx context.Context, publicKey ecdsa.PublicKey) (schema.Bundle, error) {
-	publicDerBytes, err := x509.MarshalPKIXPublicKey(&publicKey)
+// Init creates an INIT envelope with a randomly generated nonce.
+func (a *ClientActor) Init(ctx context.Context, publicKey schema.PublicKey) (schema.Bundle, error) {
+	nonce, err := schema.GenerateNonce()
+	if err != nil {
+		return nil, err
+	}
+	return a.InitWithNonce(ctx, publicKey, nonce)
+}
+
+// InitWithNonce creates an INIT envelope using a nonce issued by the server.
+func (a *ClientActor) InitWithNonce(ctx context.Context, publicKey schema.PublicKey, nonce string) (schema.Bundle, error) {
+	publicDerBytes, err := schema.MarshalPublicKey(publicKey)
 	if err != nil {
 		return nil, err
 	}
 
-	msg := schema.NewInitMessage(publicDerBytes)
+	msg := schema.NewInitMessage(publicDerBytes, nonce, time.Now())
 	env, err := schema.EnvelopeFromPayload(msg)
 	if err != nil {
 		return nil, err
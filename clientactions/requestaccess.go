@@ -8,7 +8,7 @@ import (
 
 func (a *ClientActor) RequestAccess(ctx context.Context, bundle schema.Bundle, req schema.AccessRequest) (schema.Bundle, error) {
 	authMsg := schema.NewAccessRequestMessage(req)
-	accessEnvelope, err := schema.EnvelopeFromPayload(authMsg)
+	accessEnvelope, err := schema.NewChainedEnvelope(bundle, authMsg)
 	if err != nil {
 		return nil, err
 	}
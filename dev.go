@@ -6,6 +6,8 @@ import (
 	"encoding/pem"
 	"errors"
 	"io/ioutil"
+
+	"github.com/common-fate/attestations/schema"
 )
 
 func DevPublicKey(path string) (*ecdsa.PublicKey, error) {
@@ -30,3 +32,14 @@ func DevPublicKey(path string) (*ecdsa.PublicKey, error) {
 
 	return pubKey, nil
 }
+
+// DevRootCertificates loads PEM-encoded root certificates, such as the root certificate
+// authority which certifies the identity server's signing keys.
+func DevRootCertificates(path string) ([]*x509.Certificate, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema.ParseCertificatesPEM(bytes)
+}
@@ -0,0 +1,226 @@
+// Package expression evaluates CEL (Common Expression Language) expressions
+// against the facts and decoded payloads of a bundle. It is used by policies
+// to make decisions, and by verification rules to check bundle contents.
+//
+// Each payload type is exposed as a variable, using the same field names as
+// the payload's JSON encoding:
+//
+//	facts     the facts the bundle is evaluated against: time, user.id
+//	init      the INIT payload: nonce, time
+//	auth      the AUTHENTICATED payload: userId, time, claims
+//	request   the ACCESS_REQUEST payload: role, reason, duration, target, ticketId, resources
+//	decision  the DECISION payload: outcome, autoAllow, requireApproval, reason, ruleId,
+//	          message, approverGroup, policyId, policyDigest
+//	approval  the APPROVAL payload: approverId, requestDigest
+//	grant     the grant, after any lifecycle records are applied: type, role, target,
+//	          startsAt, expiresAt, status, and the provider details, such as aws.roleArn
+//
+// Each variable is declared with its fields, so expressions referring to a field
+// which doesn't exist fail to compile. Auth claims and provider details aren't
+// checked, as their fields vary. Times are CEL timestamps and durations are CEL
+// durations. A variable is only bound if the bundle contains the payload, so
+// expressions referring to a missing payload fail to evaluate.
+package expression
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/google/cel-go/cel"
+)
+
+// Variable names which payloads are bound to.
+const (
+	VarFacts    = "facts"
+	VarInit     = "init"
+	VarAuth     = "auth"
+	VarRequest  = "request"
+	VarDecision = "decision"
+	VarApproval = "approval"
+	VarGrant    = "grant"
+)
+
+var variables = []string{VarFacts, VarInit, VarAuth, VarRequest, VarDecision, VarApproval, VarGrant}
+
+// ErrCompile is returned when an expression can't be compiled.
+type ErrCompile struct {
+	Expression string
+	Msg        string
+}
+
+func (e *ErrCompile) Error() string {
+	return fmt.Sprintf("compiling expression %q: %s", e.Expression, e.Msg)
+}
+
+// Expression is a compiled boolean CEL expression.
+type Expression struct {
+	source  string
+	program cel.Program
+}
+
+// Compile compiles a CEL expression, which must evaluate to a boolean.
+func Compile(source string) (*Expression, error) {
+	env, err := newEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	ast, iss := env.Compile(source)
+	if iss.Err() != nil {
+		return nil, &ErrCompile{Expression: source, Msg: iss.Err().Error()}
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) {
+		return nil, &ErrCompile{Expression: source, Msg: fmt.Sprintf("expression must return a bool, not %s", ast.OutputType())}
+	}
+
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, &ErrCompile{Expression: source, Msg: err.Error()}
+	}
+
+	return &Expression{source: source, program: prg}, nil
+}
+
+func newEnv() (*cel.Env, error) {
+	opts := []cel.EnvOption{withObjectTypes}
+	for _, v := range variables {
+		opts = append(opts, cel.Variable(v, variableTypes[v].celType()))
+	}
+	return cel.NewEnv(opts...)
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression against the variables.
+func (e *Expression) Eval(vars Variables) (bool, error) {
+	out, _, err := e.program.Eval(map[string]interface{}(vars))
+	if err != nil {
+		return false, fmt.Errorf("evaluating expression %q: %w", e.source, err)
+	}
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("evaluating expression %q: expression returned %s, not a bool", e.source, out.Type())
+	}
+	return result, nil
+}
+
+// Variables are the values which an expression is evaluated against.
+type Variables map[string]interface{}
+
+// NewVariables binds the facts and each payload to its variable.
+// Payload types which don't have a variable are ignored.
+func NewVariables(f schema.Facts, payloads []schema.Payload) (Variables, error) {
+	vars := Variables{
+		VarFacts: map[string]interface{}{
+			"time": f.Time,
+			"user": map[string]interface{}{"id": f.Actors.User.ID},
+		},
+	}
+
+	for _, p := range payloads {
+		switch p := p.(type) {
+		case *schema.InitPayload:
+			vars[VarInit] = map[string]interface{}{
+				"nonce": p.Nonce,
+				"time":  time.Unix(0, p.Time),
+			}
+		case *schema.AuthenticatedPayload:
+			claims := p.Claims
+			if claims == nil {
+				claims = map[string]interface{}{}
+			}
+			vars[VarAuth] = map[string]interface{}{
+				"userId": p.UserID,
+				"time":   p.AuthenticatedAt(),
+				"claims": claims,
+			}
+		case *schema.AccessRequestPayload:
+			vars[VarRequest] = requestVariable(p.Request)
+		case *schema.DecisionPayload:
+			d := p.Decision
+			vars[VarDecision] = map[string]interface{}{
+				"outcome":         string(d.EffectiveOutcome()),
+				"autoAllow":       d.AutoAllow,
+				"requireApproval": d.RequireApproval,
+				"reason":          d.Reason,
+				"ruleId":          d.RuleID,
+				"message":         d.Message,
+				"approverGroup":   d.ApproverGroup,
+				"policyId":        d.PolicyID,
+				"policyDigest":    d.PolicyDigest,
+			}
+		case *schema.ApprovalPayload:
+			vars[VarApproval] = map[string]interface{}{
+				"approverId":    p.Approval.ApproverID,
+				"requestDigest": p.Approval.RequestDigest,
+			}
+		}
+	}
+
+	if state, err := schema.CurrentGrant(payloads); err == nil {
+		grant, err := grantVariable(state)
+		if err != nil {
+			return nil, err
+		}
+		vars[VarGrant] = grant
+	}
+
+	return vars, nil
+}
+
+func requestVariable(r schema.AccessRequest) map[string]interface{} {
+	resources := []interface{}{}
+	for _, rs := range r.Resources {
+		match := map[string]interface{}{}
+		for k, v := range rs.Match {
+			match[k] = v
+		}
+		resources = append(resources, map[string]interface{}{
+			"type":  rs.Type,
+			"match": match,
+		})
+	}
+	return map[string]interface{}{
+		"role":      r.Role,
+		"reason":    r.Reason,
+		"duration":  r.Duration,
+		"target":    r.Target,
+		"ticketId":  r.TicketID,
+		"resources": resources,
+	}
+}
+
+func grantVariable(state schema.GrantState) (map[string]interface{}, error) {
+	grant := map[string]interface{}{
+		"type":      string(state.Grant.Type),
+		"startsAt":  state.Grant.StartsAt,
+		"expiresAt": state.ExpiresAt,
+		"status":    string(state.Status),
+		"role":      "",
+		"target":    "",
+	}
+	details := state.Grant.Details()
+	if details == nil {
+		return grant, nil
+	}
+	grant["role"] = details.GrantedRole()
+	grant["target"] = details.GrantedTarget()
+
+	// provider details are exposed using their JSON encoding
+	serialized, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+	var provider map[string]interface{}
+	err = json.Unmarshal(serialized, &provider)
+	if err != nil {
+		return nil, err
+	}
+	grant[string(details.GrantType())] = provider
+	return grant, nil
+}
@@ -0,0 +1,91 @@
+package expression
+
+import (
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVariables(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	f := schema.Facts{
+		Actors: schema.Actors{User: schema.User{ID: "alice"}},
+		Time:   now,
+	}
+	payloads := []schema.Payload{
+		schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+			Time:   now.Add(-time.Minute),
+			UserID: "alice",
+			Claims: map[string]interface{}{"groups": []interface{}{"oncall"}},
+		}),
+		schema.NewAccessRequestMessage(schema.AccessRequest{
+			Role:      "prod-admin",
+			Duration:  time.Hour,
+			Resources: []schema.ResourceSelector{{Type: "s3:bucket", Match: map[string]string{"name": "logs"}}},
+		}),
+		schema.NewDecisionPayload(schema.Decision{Outcome: schema.DecisionPendingApproval}),
+		schema.NewGrantCreatedPayload(schema.Grant{
+			Type:      schema.GrantTypeAWS,
+			ExpiresAt: now.Add(time.Hour),
+			AWS:       &schema.AWSGrant{RoleARN: "arn:aws:iam::123456789012:role/prod-admin"},
+		}),
+	}
+	vars, err := NewVariables(f, payloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, source := range []string{
+		`facts.user.id == auth.userId`,
+		`facts.time - auth.time == duration("1m")`,
+		`"oncall" in auth.claims.groups`,
+		`request.role.startsWith("prod-") ? decision.requireApproval : true`,
+		`decision.outcome == "pending-approval"`,
+		`request.resources.exists(r, r.type == "s3:bucket" && r.match.name == "logs")`,
+		`grant.aws.roleArn.endsWith(":role/" + request.role)`,
+		`grant.target == "123456789012" && grant.status == "active"`,
+		`grant.expiresAt - facts.time <= request.duration`,
+	} {
+		expr, err := Compile(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := expr.Eval(vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, result, source)
+	}
+
+	// the bundle doesn't contain an approval
+	expr, err := Compile(`approval.approverId == "bob"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = expr.Eval(vars)
+	assert.Error(t, err)
+}
+
+func TestCompileTypeChecks(t *testing.T) {
+	for _, source := range []string{
+		// misspelled fields
+		`request.rol == "prod-admin"`,
+		`auth.userID == facts.user.id`,
+		`decision.outcom == "allow"`,
+		`facts.user.name == "alice"`,
+		`request.resources.exists(r, r.typ == "s3:bucket")`,
+		// fields of the wrong type
+		`request.duration == "1h"`,
+		`decision.requireApproval == "true"`,
+		// expressions which don't return a bool
+		`request.role`,
+		`auth.claims.admin`,
+		`size(request.role) + 1`,
+	} {
+		_, err := Compile(source)
+		var compileErr *ErrCompile
+		assert.ErrorAs(t, err, &compileErr, source)
+	}
+}
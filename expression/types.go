@@ -0,0 +1,175 @@
+package expression
+
+import (
+	"fmt"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+// grantTypes are the provider details which can be set on the grant variable.
+var grantTypes = []schema.GrantType{
+	schema.GrantTypeAWS,
+	schema.GrantTypeGCP,
+	schema.GrantTypeAzure,
+	schema.GrantTypeOkta,
+	schema.GrantTypeKubernetes,
+}
+
+// objectType is a CEL object type which is declared to the type checker, so that
+// misspelled fields fail to compile. Values of the type are maps keyed by field name,
+// as built by NewVariables.
+type objectType struct {
+	name   string
+	fields map[string]*cel.Type
+}
+
+func (o objectType) celType() *cel.Type {
+	return cel.ObjectType(o.name)
+}
+
+var (
+	userType = objectType{name: "granted.User", fields: map[string]*cel.Type{
+		"id": cel.StringType,
+	}}
+	factsType = objectType{name: "granted.Facts", fields: map[string]*cel.Type{
+		"time": cel.TimestampType,
+		"user": userType.celType(),
+	}}
+	initType = objectType{name: "granted.Init", fields: map[string]*cel.Type{
+		"nonce": cel.StringType,
+		"time":  cel.TimestampType,
+	}}
+	authType = objectType{name: "granted.Authentication", fields: map[string]*cel.Type{
+		"userId": cel.StringType,
+		"time":   cel.TimestampType,
+		"claims": cel.MapType(cel.StringType, cel.DynType),
+	}}
+	resourceType = objectType{name: "granted.ResourceSelector", fields: map[string]*cel.Type{
+		"type":  cel.StringType,
+		"match": cel.MapType(cel.StringType, cel.StringType),
+	}}
+	requestType = objectType{name: "granted.AccessRequest", fields: map[string]*cel.Type{
+		"role":      cel.StringType,
+		"reason":    cel.StringType,
+		"duration":  cel.DurationType,
+		"target":    cel.StringType,
+		"ticketId":  cel.StringType,
+		"resources": cel.ListType(resourceType.celType()),
+	}}
+	decisionType = objectType{name: "granted.Decision", fields: map[string]*cel.Type{
+		"outcome":         cel.StringType,
+		"autoAllow":       cel.BoolType,
+		"requireApproval": cel.BoolType,
+		"reason":          cel.StringType,
+		"ruleId":          cel.StringType,
+		"message":         cel.StringType,
+		"approverGroup":   cel.StringType,
+		"policyId":        cel.StringType,
+		"policyDigest":    cel.StringType,
+	}}
+	approvalType = objectType{name: "granted.Approval", fields: map[string]*cel.Type{
+		"approverId":    cel.StringType,
+		"requestDigest": cel.StringType,
+	}}
+	grantType = newGrantType()
+)
+
+func newGrantType() objectType {
+	g := objectType{name: "granted.Grant", fields: map[string]*cel.Type{
+		"type":      cel.StringType,
+		"startsAt":  cel.TimestampType,
+		"expiresAt": cel.TimestampType,
+		"status":    cel.StringType,
+		"role":      cel.StringType,
+		"target":    cel.StringType,
+	}}
+	// provider details are exposed using their JSON encoding, so their fields
+	// aren't checked
+	for _, t := range grantTypes {
+		g.fields[string(t)] = cel.MapType(cel.StringType, cel.DynType)
+	}
+	return g
+}
+
+// variableTypes are the declared types of each variable.
+var variableTypes = map[string]objectType{
+	VarFacts:    factsType,
+	VarInit:     initType,
+	VarAuth:     authType,
+	VarRequest:  requestType,
+	VarDecision: decisionType,
+	VarApproval: approvalType,
+	VarGrant:    grantType,
+}
+
+var objectTypes = []objectType{userType, factsType, initType, authType, resourceType, requestType, decisionType, approvalType, grantType}
+
+// typeProvider declares the object types to CEL, and falls back to the
+// environment's provider for every other type.
+type typeProvider struct {
+	types.Provider
+	objects map[string]objectType
+}
+
+// withObjectTypes is a cel.EnvOption which registers the object types.
+func withObjectTypes(env *cel.Env) (*cel.Env, error) {
+	p := &typeProvider{Provider: env.CELTypeProvider(), objects: map[string]objectType{}}
+	for _, o := range objectTypes {
+		p.objects[o.name] = o
+	}
+	return cel.CustomTypeProvider(p)(env)
+}
+
+func (p *typeProvider) FindStructType(structType string) (*types.Type, bool) {
+	if _, ok := p.objects[structType]; ok {
+		return types.NewTypeTypeWithParam(types.NewObjectType(structType)), true
+	}
+	return p.Provider.FindStructType(structType)
+}
+
+func (p *typeProvider) FindStructFieldNames(structType string) ([]string, bool) {
+	o, ok := p.objects[structType]
+	if !ok {
+		return p.Provider.FindStructFieldNames(structType)
+	}
+	var names []string
+	for name := range o.fields {
+		names = append(names, name)
+	}
+	return names, true
+}
+
+func (p *typeProvider) FindStructFieldType(structType, fieldName string) (*types.FieldType, bool) {
+	o, ok := p.objects[structType]
+	if !ok {
+		return p.Provider.FindStructFieldType(structType, fieldName)
+	}
+	t, ok := o.fields[fieldName]
+	if !ok {
+		return nil, false
+	}
+	return &types.FieldType{
+		Type: t,
+		IsSet: func(obj any) bool {
+			m, ok := obj.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			_, ok = m[fieldName]
+			return ok
+		},
+		GetFrom: func(obj any) (any, error) {
+			m, ok := obj.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s is a %T, not a %s", fieldName, obj, structType)
+			}
+			v, ok := m[fieldName]
+			if !ok {
+				return nil, fmt.Errorf("no such key: %s", fieldName)
+			}
+			return v, nil
+		},
+	}, true
+}
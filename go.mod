@@ -1,15 +1,22 @@
 module github.com/common-fate/attestations
 
-go 1.17
+// Go 1.18 is required by cel-go, which policy and verification expressions are evaluated with.
+go 1.18
 
-require github.com/stretchr/testify v1.7.0
+require (
+	github.com/google/cel-go v0.20.1
+	github.com/stretchr/testify v1.7.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/alvaroloes/enumer v1.1.2
-	github.com/common-fate/cfcrypto v0.0.0-20211206101059-83f6f08b705d
+	github.com/antlr4-go/antlr/v4 v4.13.0 // indirect
 	github.com/davecgh/go-spew v1.1.0 // indirect
-	github.com/pascaldekloe/name v0.0.0-20180628100202-0fd16699aae1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/tools v0.0.0-20190524210228-3d17549cdc6b // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+	github.com/stoewer/go-strcase v1.2.0 // indirect
+	golang.org/x/exp v0.0.0-20230515195305-f3d0a9c9a5cc // indirect
+	golang.org/x/text v0.9.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20230803162519-f966b187b2e5 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230803162519-f966b187b2e5 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
 )
@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/common-fate/attestations/expression"
+	"github.com/common-fate/attestations/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// ExpressionRule matches access requests using a CEL expression.
+type ExpressionRule struct {
+	ID string `json:"id" yaml:"id"`
+	// When is a CEL expression which must evaluate to true for the rule to match,
+	// for example `request.role.startsWith("prod-") && "oncall" in auth.claims.groups`.
+	// The facts, init, auth and request variables are available. See the expression
+	// package for the fields of each variable.
+	When   string `json:"when" yaml:"when"`
+	Result `yaml:",inline"`
+}
+
+// ExpressionPolicySpec is the definition of an expression policy.
+type ExpressionPolicySpec struct {
+	ID    string           `json:"id" yaml:"id"`
+	Rules []ExpressionRule `json:"rules" yaml:"rules"`
+	// Default is the result when no rule matches. If it isn't set, requests are denied.
+	Default *Result `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// ExpressionPolicy is a policy which decides the outcome of an access request
+// using the first rule whose CEL expression evaluates to true.
+type ExpressionPolicy struct {
+	spec        ExpressionPolicySpec
+	expressions []*expression.Expression
+	digest      string
+}
+
+// NewExpressionPolicy validates an expression policy definition and compiles its expressions.
+func NewExpressionPolicy(spec ExpressionPolicySpec) (*ExpressionPolicy, error) {
+	if spec.ID == "" {
+		return nil, errors.New("policy must have an ID")
+	}
+	ids := []string{}
+	for _, r := range spec.Rules {
+		ids = append(ids, r.ID)
+	}
+	err := validateRuleIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", spec.ID, err)
+	}
+
+	p := ExpressionPolicy{spec: spec}
+	for _, r := range spec.Rules {
+		err = r.Result.validate()
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: rule %s: %w", spec.ID, r.ID, err)
+		}
+		expr, err := expression.Compile(r.When)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: rule %s: %w", spec.ID, r.ID, err)
+		}
+		p.expressions = append(p.expressions, expr)
+	}
+	if spec.Default != nil {
+		err = spec.Default.validate()
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: default: %w", spec.ID, err)
+		}
+	}
+
+	p.digest, err = digest(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ParseExpressionPolicy parses an expression policy definition from YAML or JSON.
+func ParseExpressionPolicy(data []byte) (*ExpressionPolicy, error) {
+	var spec ExpressionPolicySpec
+	err := yaml.Unmarshal(data, &spec)
+	if err != nil {
+		return nil, err
+	}
+	return NewExpressionPolicy(spec)
+}
+
+func (p *ExpressionPolicy) ID() string {
+	return p.spec.ID
+}
+
+func (p *ExpressionPolicy) Digest() string {
+	return p.digest
+}
+
+// Evaluate evaluates each rule's expression in order. An expression which
+// fails to evaluate is an error, rather than a rule which doesn't match.
+func (p *ExpressionPolicy) Evaluate(ctx context.Context, in Input) (schema.Decision, error) {
+	vars, err := expression.NewVariables(in.Facts, in.Payloads)
+	if err != nil {
+		return schema.Decision{}, err
+	}
+	for i, r := range p.spec.Rules {
+		matched, err := p.expressions[i].Eval(vars)
+		if err != nil {
+			return schema.Decision{}, fmt.Errorf("policy %s: rule %s: %w", p.spec.ID, r.ID, err)
+		}
+		if matched {
+			return r.Result.decision(r.ID), nil
+		}
+	}
+	if p.spec.Default != nil {
+		return p.spec.Default.decision(DefaultRuleID), nil
+	}
+	return defaultResult.decision(DefaultRuleID), nil
+}
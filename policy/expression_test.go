@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/common-fate/attestations/expression"
+	"github.com/common-fate/attestations/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+const testExpressionPolicy = `
+id: oncall
+rules:
+  - id: oncall-prod
+    when: 'request.role.startsWith("prod-") && auth.claims.groups.exists(g, g == "oncall")'
+    outcome: allow
+  - id: prod
+    when: 'request.role.startsWith("prod-")'
+    outcome: deny
+    reason: only on-call engineers may access production
+  - id: short
+    when: 'request.duration <= duration("1h")'
+    outcome: allow
+`
+
+func TestExpressionPolicy(t *testing.T) {
+	ctx := context.Background()
+	p, err := ParseExpressionPolicy([]byte(testExpressionPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oncall := map[string]interface{}{"groups": []interface{}{"engineering", "oncall"}}
+	d, err := p.Evaluate(ctx, makeInput(t, "prod-admin", oncall))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, schema.DecisionAllow, d.Outcome)
+	assert.Equal(t, "oncall-prod", d.RuleID)
+
+	notOncall := map[string]interface{}{"groups": []interface{}{"engineering"}}
+	d, err = p.Evaluate(ctx, makeInput(t, "prod-admin", notOncall))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, schema.Decision{
+		Outcome: schema.DecisionDeny,
+		RuleID:  "prod",
+		Reason:  "only on-call engineers may access production",
+	}, d)
+
+	// the test input requests access for an hour
+	d, err = p.Evaluate(ctx, makeInput(t, "dev-admin", notOncall))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "short", d.RuleID)
+}
+
+func TestExpressionPolicyEvaluationError(t *testing.T) {
+	p, err := ParseExpressionPolicy([]byte(testExpressionPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the user has no groups claim, so the first rule can't be evaluated
+	_, err = p.Evaluate(context.Background(), makeInput(t, "prod-admin", nil))
+	assert.Error(t, err)
+}
+
+func TestExpressionPolicyRejectsInvalidExpressions(t *testing.T) {
+	_, err := NewExpressionPolicy(ExpressionPolicySpec{
+		ID:    "test",
+		Rules: []ExpressionRule{{ID: "a", When: `size(request.role) + 1`, Result: Result{Outcome: schema.DecisionAllow}}},
+	})
+	target := &expression.ErrCompile{}
+	assert.ErrorAs(t, err, &target)
+
+	_, err = NewExpressionPolicy(ExpressionPolicySpec{
+		ID:    "test",
+		Rules: []ExpressionRule{{ID: "a", When: "request.role ==", Result: Result{Outcome: schema.DecisionAllow}}},
+	})
+	assert.ErrorAs(t, err, &target)
+}
@@ -0,0 +1,130 @@
+// Package policy contains policies which the identity server evaluates
+// to decide whether an access request is allowed, denied or requires approval.
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+)
+
+// Policy decides the outcome of an access request.
+type Policy interface {
+	// ID identifies the policy
+	ID() string
+	// Digest is a digest of the policy's definition, such as "sha256:<hex>",
+	// which identifies the exact version of the policy that was evaluated.
+	Digest() string
+	// Evaluate decides the outcome of the access request in the input.
+	// The decision's RuleID should identify the rule which matched.
+	Evaluate(ctx context.Context, in Input) (schema.Decision, error)
+}
+
+// Input is the verified bundle which a policy is evaluated against.
+type Input struct {
+	Facts  schema.Facts
+	Bundle schema.Bundle
+	// Payloads are the deserialized payloads of each envelope in the bundle
+	Payloads       []schema.Payload
+	Authentication *schema.AuthenticatedPayload
+	Request        *schema.AccessRequestPayload
+}
+
+// NewInput deserializes a bundle ending in an access request into a policy input.
+// The bundle should already have been verified.
+func NewInput(f schema.Facts, b schema.Bundle) (Input, error) {
+	if len(b) != 3 {
+		return Input{}, errors.New("bundle did not contain 3 envelopes")
+	}
+	expected := []types.Payload{types.PayloadInit, types.PayloadAuthenticated, types.PayloadAccessRequest}
+
+	in := Input{Facts: f, Bundle: b}
+	for i, t := range expected {
+		p, err := schema.DeserializePayload(b[i].Payload, t)
+		if err != nil {
+			return Input{}, err
+		}
+		in.Payloads = append(in.Payloads, p)
+	}
+	in.Authentication = in.Payloads[1].(*schema.AuthenticatedPayload)
+	in.Request = in.Payloads[2].(*schema.AccessRequestPayload)
+	return in, nil
+}
+
+// Result is the decision made when a policy rule matches.
+type Result struct {
+	Outcome schema.DecisionOutcome `json:"outcome" yaml:"outcome"`
+	// ApproverGroup is the group which must approve the request,
+	// if the outcome is pending-approval
+	ApproverGroup string `json:"approverGroup,omitempty" yaml:"approverGroup,omitempty"`
+	Reason        string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Message       string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// DefaultRuleID is the rule ID recorded when no rule in a policy matched.
+const DefaultRuleID = "default"
+
+// defaultResult is used when no rule matched and the policy doesn't specify a default.
+var defaultResult = Result{
+	Outcome: schema.DecisionDeny,
+	Reason:  "no policy rule matched the request",
+}
+
+func (r Result) validate() error {
+	switch r.Outcome {
+	case schema.DecisionAllow, schema.DecisionDeny:
+		if r.ApproverGroup != "" {
+			return fmt.Errorf("an approver group can only be set when the outcome is %s", schema.DecisionPendingApproval)
+		}
+	case schema.DecisionPendingApproval:
+	default:
+		return fmt.Errorf("unknown outcome %q", r.Outcome)
+	}
+	return nil
+}
+
+// decision returns the decision for a rule which matched.
+func (r Result) decision(ruleID string) schema.Decision {
+	d := schema.Decision{
+		Outcome:       r.Outcome,
+		Reason:        r.Reason,
+		RuleID:        ruleID,
+		Message:       r.Message,
+		ApproverGroup: r.ApproverGroup,
+	}
+	if d.Outcome == schema.DecisionDeny && d.Reason == "" {
+		d.Reason = fmt.Sprintf("denied by policy rule %s", ruleID)
+	}
+	return d
+}
+
+// digest returns the digest of a policy definition's canonical JSON encoding.
+func digest(definition interface{}) (string, error) {
+	serialized, err := json.Marshal(definition)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(serialized)
+	return "sha256:" + hex.EncodeToString(h[:]), nil
+}
+
+// validateRuleIDs checks that each rule has a unique ID.
+func validateRuleIDs(ids []string) error {
+	seen := map[string]bool{DefaultRuleID: true}
+	for i, id := range ids {
+		if id == "" {
+			return fmt.Errorf("rule %d must have an ID", i)
+		}
+		if seen[id] {
+			return fmt.Errorf("rule ID %s is used more than once", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
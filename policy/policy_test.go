@@ -0,0 +1,30 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+)
+
+// makeInput returns a policy input for an unsigned bundle requesting the role.
+func makeInput(t *testing.T, role string, claims map[string]interface{}) Input {
+	payloads := []schema.Payload{
+		schema.NewInitMessage([]byte("test"), "nonce", time.Now()),
+		schema.NewAuthenticatedMessage(schema.AuthMessageOpts{Time: time.Now(), UserID: "alice", Claims: claims}),
+		schema.NewAccessRequestMessage(schema.AccessRequest{Role: role, Reason: "test", Duration: time.Hour}),
+	}
+	var b schema.Bundle
+	for _, p := range payloads {
+		e, err := schema.NewChainedEnvelope(b, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b = append(b, e)
+	}
+	in, err := NewInput(schema.Facts{Actors: schema.Actors{User: schema.User{ID: "alice"}}}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return in
+}
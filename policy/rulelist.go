@@ -0,0 +1,120 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/common-fate/attestations/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// RoleRule matches access requests by the requested role.
+type RoleRule struct {
+	ID string `json:"id" yaml:"id"`
+	// Role is a pattern which the requested role must match.
+	// '*' matches any sequence of characters, including '/' and ':',
+	// for example "arn:aws:iam::*:role/dev-*".
+	Role   string `json:"role" yaml:"role"`
+	Result `yaml:",inline"`
+}
+
+// RuleListSpec is the definition of a rule-list policy.
+type RuleListSpec struct {
+	ID    string     `json:"id" yaml:"id"`
+	Rules []RoleRule `json:"rules" yaml:"rules"`
+	// Default is the result when no rule matches. If it isn't set, requests are denied.
+	Default *Result `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// RuleList is a policy which decides the outcome of an access request
+// using the first rule whose role pattern matches the requested role.
+type RuleList struct {
+	spec     RuleListSpec
+	patterns []*regexp.Regexp
+	digest   string
+}
+
+// NewRuleList validates a rule-list policy definition.
+func NewRuleList(spec RuleListSpec) (*RuleList, error) {
+	if spec.ID == "" {
+		return nil, errors.New("policy must have an ID")
+	}
+	ids := []string{}
+	for _, r := range spec.Rules {
+		ids = append(ids, r.ID)
+	}
+	err := validateRuleIDs(ids)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", spec.ID, err)
+	}
+
+	p := RuleList{spec: spec}
+	for _, r := range spec.Rules {
+		if r.Role == "" {
+			return nil, fmt.Errorf("policy %s: rule %s must have a role pattern", spec.ID, r.ID)
+		}
+		err = r.Result.validate()
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: rule %s: %w", spec.ID, r.ID, err)
+		}
+		p.patterns = append(p.patterns, compilePattern(r.Role))
+	}
+	if spec.Default != nil {
+		err = spec.Default.validate()
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: default: %w", spec.ID, err)
+		}
+	}
+
+	p.digest, err = digest(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ParseRuleList parses a rule-list policy definition from YAML or JSON.
+func ParseRuleList(data []byte) (*RuleList, error) {
+	var spec RuleListSpec
+	err := yaml.Unmarshal(data, &spec)
+	if err != nil {
+		return nil, err
+	}
+	return NewRuleList(spec)
+}
+
+// compilePattern compiles a role pattern, in which '*' matches any sequence of characters.
+func compilePattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+func (p *RuleList) ID() string {
+	return p.spec.ID
+}
+
+func (p *RuleList) Digest() string {
+	return p.digest
+}
+
+func (p *RuleList) Evaluate(ctx context.Context, in Input) (schema.Decision, error) {
+	if in.Request == nil {
+		return schema.Decision{}, errors.New("policy input did not contain an access request")
+	}
+	role := in.Request.Request.Role
+	for i, r := range p.spec.Rules {
+		if p.patterns[i].MatchString(role) {
+			return r.Result.decision(r.ID), nil
+		}
+	}
+	if p.spec.Default != nil {
+		return p.spec.Default.decision(DefaultRuleID), nil
+	}
+	return defaultResult.decision(DefaultRuleID), nil
+}
@@ -0,0 +1,89 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRuleList = `
+id: aws-roles
+rules:
+  - id: dev
+    role: "arn:aws:iam::*:role/dev-*"
+    outcome: allow
+  - id: prod
+    role: "arn:aws:iam::*:role/prod-*"
+    outcome: pending-approval
+    approverGroup: admins
+    message: an admin must approve production access
+default:
+  outcome: deny
+  reason: role is not covered by the access policy
+`
+
+func TestRuleList(t *testing.T) {
+	ctx := context.Background()
+	p, err := ParseRuleList([]byte(testRuleList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "aws-roles", p.ID())
+	assert.True(t, strings.HasPrefix(p.Digest(), "sha256:"))
+
+	d, err := p.Evaluate(ctx, makeInput(t, "arn:aws:iam::123456789012:role/dev-readonly", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, schema.Decision{Outcome: schema.DecisionAllow, RuleID: "dev"}, d)
+
+	d, err = p.Evaluate(ctx, makeInput(t, "arn:aws:iam::123456789012:role/prod-admin", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, schema.Decision{
+		Outcome:       schema.DecisionPendingApproval,
+		RuleID:        "prod",
+		Message:       "an admin must approve production access",
+		ApproverGroup: "admins",
+	}, d)
+
+	d, err = p.Evaluate(ctx, makeInput(t, "arn:aws:iam::123456789012:role/billing", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, schema.Decision{
+		Outcome: schema.DecisionDeny,
+		RuleID:  DefaultRuleID,
+		Reason:  "role is not covered by the access policy",
+	}, d)
+}
+
+func TestRuleListDigestChangesWithDefinition(t *testing.T) {
+	a, err := ParseRuleList([]byte(testRuleList))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseRuleList([]byte(strings.Replace(testRuleList, "outcome: allow", "outcome: pending-approval", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, a.Digest(), b.Digest())
+}
+
+func TestRuleListRejectsInvalidSpecs(t *testing.T) {
+	_, err := NewRuleList(RuleListSpec{ID: "test", Rules: []RoleRule{{ID: "a", Role: "*", Result: Result{Outcome: "maybe"}}}})
+	assert.EqualError(t, err, `policy test: rule a: unknown outcome "maybe"`)
+
+	_, err = NewRuleList(RuleListSpec{ID: "test", Rules: []RoleRule{
+		{ID: "a", Role: "*", Result: Result{Outcome: schema.DecisionAllow}},
+		{ID: "a", Role: "*", Result: Result{Outcome: schema.DecisionAllow}},
+	}})
+	assert.EqualError(t, err, "policy test: rule ID a is used more than once")
+
+	_, err = NewRuleList(RuleListSpec{ID: "test", Rules: []RoleRule{{ID: "a", Role: "*", Result: Result{Outcome: schema.DecisionAllow, ApproverGroup: "admins"}}}})
+	assert.EqualError(t, err, "policy test: rule a: an approver group can only be set when the outcome is pending-approval")
+}
@@ -1,19 +1,56 @@
 package schema
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/common-fate/attestations/types"
 )
 
+// MaxAccessRequestDuration is the longest duration which access may be requested for.
+const MaxAccessRequestDuration = 12 * time.Hour
+
+// maxTicketIDLength is the longest external ticket ID which may be referenced.
+const maxTicketIDLength = 128
+
 type AccessRequestPayload struct {
+	Link
 	Request     AccessRequest `json:"request"`
 	PayloadType types.Payload `json:"type"`
+
+	// UpgradedFrom is the payload type which the request was upgraded from
+	// when it was deserialized, or empty if the request wasn't upgraded.
+	UpgradedFrom types.Payload `json:"-"`
 }
 
 type AccessRequest struct {
 	Role   string `json:"role"`
 	Reason string `json:"reason"`
+	// Duration is how long access is requested for
+	Duration time.Duration `json:"duration,omitempty"`
+	// Target is the account or cluster which access is requested to
+	Target string `json:"target,omitempty"`
+	// TicketID references a ticket in an external system, such as a Jira issue
+	TicketID string `json:"ticketId,omitempty"`
+	// Resources narrow the request down to specific resources within the target
+	Resources []ResourceSelector `json:"resources,omitempty"`
+}
+
+// accessRequestV01 is the request contained in v0.1 payloads,
+// which only had a role and a reason.
+type accessRequestV01 struct {
+	Role   string `json:"role"`
+	Reason string `json:"reason"`
+}
+
+// ResourceSelector selects resources of a particular type by their attributes,
+// for example {"type": "s3:bucket", "match": {"name": "logs"}}.
+type ResourceSelector struct {
+	Type  string            `json:"type"`
+	Match map[string]string `json:"match,omitempty"`
 }
 
 func NewAccessRequestMessage(req AccessRequest) *AccessRequestPayload {
@@ -31,6 +68,93 @@ func (m *AccessRequestPayload) MarshalJSON() ([]byte, error) {
 	return json.Marshal(*m)
 }
 
+// UnmarshalJSON unmarshals both v0.1 and v0.2 access request payloads. v0.1 payloads
+// are decoded using the v0.1 request fields, and are rejected if they contain any fields
+// which were added in v0.2, so that a request can't be signed as v0.1 to avoid being
+// validated against the v0.2 rules for those fields.
+func (m *AccessRequestPayload) UnmarshalJSON(data []byte) error {
+	type payload AccessRequestPayload
+	var p payload
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+
+	if p.PayloadType == types.PayloadAccessRequestV01 {
+		var v01 struct {
+			Link
+			Request     accessRequestV01 `json:"request"`
+			PayloadType types.Payload    `json:"type"`
+		}
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		err = d.Decode(&v01)
+		if err != nil {
+			return &ErrInvalidPayloadContents{Msg: fmt.Sprintf("%s access request: %s", p.PayloadType, err)}
+		}
+		p = payload{
+			Link:        v01.Link,
+			Request:     AccessRequest{Role: v01.Request.Role, Reason: v01.Request.Reason},
+			PayloadType: v01.PayloadType,
+		}
+	}
+
+	*m = AccessRequestPayload(p)
+	return nil
+}
+
+// Upgrade upgrades a v0.1 access request to the current version.
+// v0.1 requests didn't specify a duration, so they are taken to request
+// MaxAccessRequestDuration. They didn't specify a target, ticket or resources
+// either, so these are left empty.
+func (m *AccessRequestPayload) Upgrade() error {
+	if m.PayloadType == types.PayloadAccessRequestV01 {
+		m.UpgradedFrom = m.PayloadType
+		m.PayloadType = types.PayloadAccessRequest
+		m.Request.Duration = MaxAccessRequestDuration
+	}
+	return nil
+}
+
+// ValidateContents validates the access request. Requests upgraded
+// from v0.1 are validated against the same rules.
+//
+// Rules:
+// - a role must be requested
+// - a reason must be given
+// - the duration must be positive and no longer than MaxAccessRequestDuration
+// - the ticket ID must not contain whitespace
+// - each resource selector must have a type and non-empty match keys
 func (m *AccessRequestPayload) ValidateContents(f Facts) error {
+	r := m.Request
+	if r.Role == "" {
+		return &ErrInvalidPayloadContents{Msg: "access request did not specify a role"}
+	}
+
+	if strings.TrimSpace(r.Reason) == "" {
+		return &ErrInvalidPayloadContents{Msg: "access request did not specify a reason"}
+	}
+	if r.Duration <= 0 {
+		return &ErrInvalidPayloadContents{Msg: "access request duration must be positive"}
+	}
+	if r.Duration > MaxAccessRequestDuration {
+		return &ErrInvalidPayloadContents{
+			Msg: fmt.Sprintf("access request duration %s exceeds the maximum of %s", r.Duration, MaxAccessRequestDuration),
+		}
+	}
+	if len(r.TicketID) > maxTicketIDLength || strings.ContainsAny(r.TicketID, " \t\r\n") {
+		return &ErrInvalidPayloadContents{Msg: fmt.Sprintf("invalid ticket ID %q", r.TicketID)}
+	}
+	for i, rs := range r.Resources {
+		if rs.Type == "" {
+			return &ErrInvalidPayloadContents{Msg: fmt.Sprintf("resource selector %d did not specify a type", i)}
+		}
+		for k := range rs.Match {
+			if k == "" {
+				return &ErrInvalidPayloadContents{Msg: fmt.Sprintf("resource selector %d contains an empty match key", i)}
+			}
+		}
+	}
+
 	return nil
 }
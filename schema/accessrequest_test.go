@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessRequestValidateContents(t *testing.T) {
+	valid := AccessRequest{
+		Role:     "test-role",
+		Reason:   "investigating an incident",
+		Duration: time.Hour,
+		Target:   "123456789012",
+		TicketID: "OPS-123",
+		Resources: []ResourceSelector{
+			{Type: "s3:bucket", Match: map[string]string{"name": "logs"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(r *AccessRequest)
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			modify: func(r *AccessRequest) {},
+		},
+		{
+			name:    "no role",
+			modify:  func(r *AccessRequest) { r.Role = "" },
+			wantErr: "invalid payload contents: access request did not specify a role",
+		},
+		{
+			name:    "blank reason",
+			modify:  func(r *AccessRequest) { r.Reason = "  " },
+			wantErr: "invalid payload contents: access request did not specify a reason",
+		},
+		{
+			name:    "no duration",
+			modify:  func(r *AccessRequest) { r.Duration = 0 },
+			wantErr: "invalid payload contents: access request duration must be positive",
+		},
+		{
+			name:    "duration too long",
+			modify:  func(r *AccessRequest) { r.Duration = 24 * time.Hour },
+			wantErr: "invalid payload contents: access request duration 24h0m0s exceeds the maximum of 12h0m0s",
+		},
+		{
+			name:    "ticket ID with whitespace",
+			modify:  func(r *AccessRequest) { r.TicketID = "OPS 123" },
+			wantErr: `invalid payload contents: invalid ticket ID "OPS 123"`,
+		},
+		{
+			name:    "resource without type",
+			modify:  func(r *AccessRequest) { r.Resources = []ResourceSelector{{}} },
+			wantErr: "invalid payload contents: resource selector 0 did not specify a type",
+		},
+		{
+			name: "resource with empty match key",
+			modify: func(r *AccessRequest) {
+				r.Resources = []ResourceSelector{{Type: "s3:bucket", Match: map[string]string{"": "logs"}}}
+			},
+			wantErr: "invalid payload contents: resource selector 0 contains an empty match key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.modify(&r)
+			err := NewAccessRequestMessage(r).ValidateContents(Facts{})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestUpgradeAccessRequestV01(t *testing.T) {
+	payload := []byte(`{"request":{"role":"test-role","reason":"investigating an incident"},"type":"granted.dev/AccessRequest/v0.1"}`)
+
+	// v0.1 requests are only accepted if legacy payloads are enabled
+	_, err := DeserializePayload(payload, types.PayloadAccessRequest)
+	assert.ErrorAs(t, err, new(*ErrLegacyPayloadType))
+
+	p, err := DeserializePayloadWithOptions(payload, types.PayloadAccessRequest, DeserializeOptions{AcceptLegacy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := p.(*AccessRequestPayload)
+	assert.Equal(t, types.PayloadAccessRequest, req.Type())
+	assert.Equal(t, types.PayloadAccessRequestV01, req.UpgradedFrom)
+	assert.Equal(t, AccessRequest{Role: "test-role", Reason: "investigating an incident", Duration: MaxAccessRequestDuration}, req.Request)
+	assert.NoError(t, req.ValidateContents(Facts{}))
+}
+
+func TestUpgradeAccessRequestV01Validated(t *testing.T) {
+	opts := DeserializeOptions{AcceptLegacy: true}
+
+	// upgraded requests are validated against the current rules
+	p, err := DeserializePayloadWithOptions([]byte(`{"request":{"role":"test-role","reason":""},"type":"granted.dev/AccessRequest/v0.1"}`), types.PayloadAccessRequest, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualError(t, p.ValidateContents(Facts{}), "invalid payload contents: access request did not specify a reason")
+
+	// a request can't be signed as v0.1 to carry v0.2 fields past the v0.2 rules
+	for _, field := range []string{`"duration":360000000000000`, `"target":"123456789012"`, `"ticketId":"OPS-123"`, `"resources":[{"type":"s3:bucket"}]`} {
+		payload := []byte(`{"request":{"role":"test-role","reason":"test",` + field + `},"type":"granted.dev/AccessRequest/v0.1"}`)
+		_, err := DeserializePayloadWithOptions(payload, types.PayloadAccessRequest, opts)
+		assert.ErrorAs(t, err, new(*ErrInvalidPayloadContents), field)
+	}
+}
+
+// A v0.1 request which claimed a 100 hour duration could previously skip
+// validation, letting a 100 hour grant be created for it.
+func TestAccessRequestV01DurationBypass(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	opts := DeserializeOptions{AcceptLegacy: true}
+
+	_, err := DeserializePayloadWithOptions([]byte(`{"request":{"role":"`+testRoleARN+`","reason":"test","duration":360000000000000},"type":"granted.dev/AccessRequest/v0.1"}`), types.PayloadAccessRequest, opts)
+	assert.EqualError(t, err, `invalid payload contents: granted.dev/AccessRequest/v0.1 access request: json: unknown field "duration"`)
+
+	p, err := DeserializePayloadWithOptions([]byte(`{"request":{"role":"`+testRoleARN+`","reason":"test"},"type":"granted.dev/AccessRequest/v0.1"}`), types.PayloadAccessRequest, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grant := NewGrantCreatedPayload(Grant{
+		Type:      GrantTypeAWS,
+		StartsAt:  start,
+		ExpiresAt: start.Add(100 * time.Hour),
+		AWS:       &AWSGrant{RoleARN: testRoleARN},
+	})
+	err = grant.ValidateBundle(Facts{}, []Payload{p, NewDecisionPayload(Decision{AutoAllow: true})})
+	assert.EqualError(t, err, "inconsistent bundle: grant duration 100h0m0s exceeds requested duration 12h0m0s")
+}
@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+)
+
+// Algorithm identifies the algorithm used to produce a signature.
+// Identifiers follow the JSON Web Algorithms (RFC 7518) names.
+type Algorithm string
+
+const (
+	// AlgorithmES256 is ECDSA using the P-256 curve and SHA-256.
+	AlgorithmES256 Algorithm = "ES256"
+	// AlgorithmES384 is ECDSA using the P-384 curve and SHA-384.
+	AlgorithmES384 Algorithm = "ES384"
+	// AlgorithmEdDSA is Ed25519.
+	AlgorithmEdDSA Algorithm = "EdDSA"
+	// AlgorithmPS256 is RSASSA-PSS using SHA-256, with a salt length equal to the hash length.
+	AlgorithmPS256 Algorithm = "PS256"
+)
+
+// minRSAKeyBits is the smallest RSA modulus which we accept for signing or verification.
+const minRSAKeyBits = 2048
+
+// ErrUnsupportedAlgorithm is returned when a key or signature uses an algorithm
+// which isn't supported.
+type ErrUnsupportedAlgorithm struct {
+	Msg string
+}
+
+func (e *ErrUnsupportedAlgorithm) Error() string {
+	return fmt.Sprintf("unsupported algorithm: %s", e.Msg)
+}
+
+// hash returns the hash function used to digest messages before signing.
+// Ed25519 signs messages directly, so it returns zero.
+func (a Algorithm) hash() crypto.Hash {
+	switch a {
+	case AlgorithmES256, AlgorithmPS256:
+		return crypto.SHA256
+	case AlgorithmES384:
+		return crypto.SHA384
+	default:
+		return 0
+	}
+}
+
+// supported reports whether signatures using the algorithm can be verified.
+func (a Algorithm) supported() bool {
+	switch a {
+	case AlgorithmES256, AlgorithmES384, AlgorithmEdDSA, AlgorithmPS256:
+		return true
+	default:
+		return false
+	}
+}
+
+// digest hashes a message with the algorithm's hash function.
+func (a Algorithm) digest(message []byte) ([]byte, error) {
+	h := a.hash()
+	if h == 0 {
+		return message, nil
+	}
+	hasher := h.New()
+	_, err := hasher.Write(message)
+	if err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
+// ecdsaAlgorithm returns the algorithm used with an ECDSA curve.
+func ecdsaAlgorithm(curve elliptic.Curve) (Algorithm, error) {
+	switch curve {
+	case elliptic.P256():
+		return AlgorithmES256, nil
+	case elliptic.P384():
+		return AlgorithmES384, nil
+	default:
+		return "", &ErrUnsupportedAlgorithm{Msg: fmt.Sprintf("ECDSA curve %s", curve.Params().Name)}
+	}
+}
+
+// GenerateKey generates a new private key for use with the algorithm.
+func GenerateKey(alg Algorithm) (crypto.Signer, error) {
+	switch alg {
+	case AlgorithmES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmES384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	case AlgorithmPS256:
+		return rsa.GenerateKey(rand.Reader, minRSAKeyBits)
+	default:
+		return nil, &ErrUnsupportedAlgorithm{Msg: string(alg)}
+	}
+}
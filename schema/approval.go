@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/common-fate/attestations/types"
+)
+
+// Approval records that an approver has approved an access request
+// which the identity server decided required approval.
+type Approval struct {
+	// ApproverID is the ID of the approver, which must match an approver in the Facts
+	ApproverID string `json:"approverId"`
+	// RequestDigest is the digest of the access request envelope being approved
+	RequestDigest string `json:"requestDigest"`
+	// ApprovedAt is when the request was approved. The approver's signature
+	// is verified using the keys which were valid at this time.
+	ApprovedAt time.Time `json:"approvedAt"`
+}
+
+type ApprovalPayload struct {
+	Link
+	Approval    Approval      `json:"approval"`
+	PayloadType types.Payload `json:"type"`
+}
+
+func NewApprovalPayload(a Approval) *ApprovalPayload {
+	return &ApprovalPayload{
+		Approval:    a,
+		PayloadType: types.PayloadApproval,
+	}
+}
+
+func (m *ApprovalPayload) Type() types.Payload {
+	return m.PayloadType
+}
+
+func (m *ApprovalPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(*m)
+}
+
+func (m *ApprovalPayload) ValidateContents(f Facts) error {
+	if _, ok := f.Actors.Approver(m.Approval.ApproverID); !ok {
+		return &ErrInvalidPayloadContents{
+			Msg: fmt.Sprintf("%s is not a trusted approver", m.Approval.ApproverID),
+		}
+	}
+	if m.Approval.RequestDigest == "" {
+		return &ErrInvalidPayloadContents{
+			Msg: "request digest must be provided",
+		}
+	}
+
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 )
 
 type AuthenticatedPayload struct {
+	Link
 	Time        int64                  `json:"time"`
 	UserID      string                 `json:"userId"`
 	Claims      map[string]interface{} `json:"claims"`
@@ -0,0 +1,272 @@
+package schema
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CertificateChainSigner is implemented by signers whose key is certified by an X.509
+// certificate. The certificate chain is attached to each signature the signer makes,
+// so that verifiers can trust the key by chaining to a root certificate authority
+// rather than pinning the key itself.
+type CertificateChainSigner interface {
+	EnvelopeSigner
+	// CertificateChain returns the DER-encoded certificate chain for the signing key,
+	// starting with the key's certificate and followed by any intermediate certificates.
+	CertificateChain() ([][]byte, error)
+}
+
+// CertificateSigner signs with a key which is certified by an X.509 certificate chain.
+type CertificateSigner struct {
+	EnvelopeSigner
+	// Chain is the DER-encoded certificate chain, starting with the signing key's certificate
+	Chain [][]byte
+}
+
+func (c *CertificateSigner) CertificateChain() ([][]byte, error) {
+	if len(c.Chain) == 0 {
+		return nil, errors.New("certificate chain is empty")
+	}
+	return c.Chain, nil
+}
+
+// CertificateAuthority is the X.509 certificate authority which certifies
+// an identity server's signing keys.
+type CertificateAuthority struct {
+	// Roots are the trusted root certificates
+	Roots []*x509.Certificate
+	// ExtKeyUsages are the extended key usages which a signing certificate must have at least one of.
+	// If empty, signing certificates must have the code signing extended key usage, so that other
+	// certificates issued by the same roots, such as TLS server certificates, can't be used to sign.
+	ExtKeyUsages []x509.ExtKeyUsage
+	// PermittedDNSDomains, if set, constrain the names which signing certificates may be
+	// issued for, in addition to any name constraints in the certificate chain itself.
+	// Each DNS name in a signing certificate must be within one of the domains,
+	// and signing certificates must have at least one DNS name.
+	PermittedDNSDomains []string
+}
+
+// ParseCertificatesPEM parses each of the PEM-encoded certificates in data.
+func ParseCertificatesPEM(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certs, nil
+}
+
+// MarshalCertificatePEM encodes a certificate in PEM form.
+func MarshalCertificatePEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// DefaultExtKeyUsages are the extended key usages which signing certificates
+// must have if the certificate authority doesn't specify any.
+var DefaultExtKeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+
+func (ca *CertificateAuthority) extKeyUsages() []x509.ExtKeyUsage {
+	if len(ca.ExtKeyUsages) == 0 {
+		return DefaultExtKeyUsages
+	}
+	return ca.ExtKeyUsages
+}
+
+// ErrCertificateUsageNotPermitted is returned when a signing certificate
+// isn't issued for signing with the usages which the certificate authority requires.
+type ErrCertificateUsageNotPermitted struct {
+	Msg string
+}
+
+func (e *ErrCertificateUsageNotPermitted) Error() string {
+	return fmt.Sprintf("certificate can't be used for signing: %s", e.Msg)
+}
+
+// ErrCertificateNameNotPermitted is returned when a signing certificate
+// is issued for a name outside of the permitted domains.
+type ErrCertificateNameNotPermitted struct {
+	Name      string
+	Permitted []string
+}
+
+func (e *ErrCertificateNameNotPermitted) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("certificate has no DNS names, but must be issued for a name within: %s", strings.Join(e.Permitted, ", "))
+	}
+	return fmt.Sprintf("certificate name %s is not within any of the permitted domains: %s", e.Name, strings.Join(e.Permitted, ", "))
+}
+
+// VerifyChain verifies a DER-encoded certificate chain, starting with the signing certificate,
+// against the certificate authority. The chain must be valid at t, which should be the time
+// the signature was made. It returns the public key certified by the signing certificate.
+func (ca *CertificateAuthority) VerifyChain(chain [][]byte, t time.Time) (PublicKey, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("certificate chain is empty")
+	}
+	if len(ca.Roots) == 0 {
+		return nil, errors.New("certificate authority has no root certificates")
+	}
+	roots := x509.NewCertPool()
+	for _, cert := range ca.Roots {
+		roots.AddCert(cert)
+	}
+
+	var certs []*x509.Certificate
+	for _, der := range chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	leaf := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   t,
+		KeyUsages:     ca.extKeyUsages(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = ca.checkUsages(leaf)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ca.checkNames(leaf)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPublicKey(leaf.PublicKey)
+}
+
+// checkUsages checks that the signing certificate is issued for digital signatures and has one of
+// the required extended key usages. Certificate verification accepts leaf certificates which
+// don't have any extended key usages, so the usages are checked explicitly.
+func (ca *CertificateAuthority) checkUsages(cert *x509.Certificate) error {
+	if cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return &ErrCertificateUsageNotPermitted{Msg: "certificate does not have the digital signature key usage"}
+	}
+	usages := ca.extKeyUsages()
+	for _, have := range cert.ExtKeyUsage {
+		for _, want := range usages {
+			if have == want {
+				return nil
+			}
+		}
+	}
+	var names []string
+	for _, u := range usages {
+		names = append(names, ExtKeyUsageName(u))
+	}
+	return &ErrCertificateUsageNotPermitted{
+		Msg: fmt.Sprintf("certificate does not have any of the extended key usages: %s", strings.Join(names, ", ")),
+	}
+}
+
+// extKeyUsageNames are the names of extended key usages from RFC 5280.
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageServerAuth:      "serverAuth",
+	x509.ExtKeyUsageClientAuth:      "clientAuth",
+	x509.ExtKeyUsageCodeSigning:     "codeSigning",
+	x509.ExtKeyUsageEmailProtection: "emailProtection",
+	x509.ExtKeyUsageTimeStamping:    "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:     "OCSPSigning",
+}
+
+// ExtKeyUsageName returns the RFC 5280 name of an extended key usage, such as "codeSigning".
+func ExtKeyUsageName(u x509.ExtKeyUsage) string {
+	if name, ok := extKeyUsageNames[u]; ok {
+		return name
+	}
+	return fmt.Sprintf("extKeyUsage(%d)", u)
+}
+
+// ParseExtKeyUsage parses the RFC 5280 name of an extended key usage, such as "codeSigning".
+func ParseExtKeyUsage(name string) (x509.ExtKeyUsage, error) {
+	for u, n := range extKeyUsageNames {
+		if n == name {
+			return u, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported extended key usage %s", name)
+}
+
+func (ca *CertificateAuthority) checkNames(cert *x509.Certificate) error {
+	if len(ca.PermittedDNSDomains) == 0 {
+		return nil
+	}
+	if len(cert.DNSNames) == 0 {
+		return &ErrCertificateNameNotPermitted{Permitted: ca.PermittedDNSDomains}
+	}
+	for _, name := range cert.DNSNames {
+		if !withinDomains(name, ca.PermittedDNSDomains) {
+			return &ErrCertificateNameNotPermitted{Name: name, Permitted: ca.PermittedDNSDomains}
+		}
+	}
+	return nil
+}
+
+func withinDomains(name string, domains []string) bool {
+	name = strings.ToLower(name)
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+		if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// CertificateSigners returns the identity server as an expected signer for each signature on
+// the envelope which carries a certificate chain issued by the identity server's certificate
+// authority, and valid at t. If the envelope carries certificate chains but none of them
+// are valid, the error from verifying the last of them is returned.
+func (i IdentityServer) CertificateSigners(e Envelope, t time.Time) ([]ExpectedSigner, error) {
+	if i.CertificateAuthority == nil {
+		return nil, nil
+	}
+	var signers []ExpectedSigner
+	var lastErr error
+	for _, sig := range e.Signatures {
+		if len(sig.Certificates) == 0 {
+			continue
+		}
+		key, err := i.CertificateAuthority.VerifyChain(sig.Certificates, t)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		signers = append(signers, ExpectedSigner{Name: i.signerName(), PublicKey: key})
+	}
+	if len(signers) == 0 && lastErr != nil {
+		return nil, fmt.Errorf("verifying certificate chain for %s: %w", i.signerName(), lastErr)
+	}
+	return signers, nil
+}
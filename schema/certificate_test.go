@@ -0,0 +1,154 @@
+package schema
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/internal/certtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificateAuthorityVerifyChain(t *testing.T) {
+	root := certtest.Issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	intermediate := certtest.Issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "intermediate"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, &root)
+	leaf := certtest.Issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "idp.example.com"},
+		DNSNames:    []string{"idp.example.com"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, &intermediate)
+	chain := [][]byte{leaf.Cert.Raw, intermediate.Cert.Raw}
+
+	roots := []*x509.Certificate{root.Cert}
+	ca := CertificateAuthority{Roots: roots, PermittedDNSDomains: []string{"example.com"}}
+
+	key, err := ca.VerifyChain(chain, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafKey, err := NewPublicKey(leaf.Key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, PublicKeysEqual(leafKey, key))
+
+	// certificates must be valid at the time of the signature
+	_, err = ca.VerifyChain(chain, time.Now().Add(2*time.Hour))
+	assert.ErrorAs(t, err, new(x509.CertificateInvalidError))
+
+	// intermediate certificates must be included in the chain
+	_, err = ca.VerifyChain(chain[:1], time.Now())
+	assert.ErrorAs(t, err, new(x509.UnknownAuthorityError))
+
+	ca.PermittedDNSDomains = []string{"example.org"}
+	_, err = ca.VerifyChain(chain, time.Now())
+	assert.Equal(t, &ErrCertificateNameNotPermitted{Name: "idp.example.com", Permitted: []string{"example.org"}}, err)
+}
+
+func TestCertificateAuthorityNameConstraints(t *testing.T) {
+	root := certtest.Issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		PermittedDNSDomains:   []string{"idp.example.com"},
+	}, nil)
+	leaf := certtest.Issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "mallory.example.com"},
+		DNSNames:    []string{"mallory.example.com"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, &root)
+
+	roots := []*x509.Certificate{root.Cert}
+	ca := CertificateAuthority{Roots: roots}
+
+	_, err := ca.VerifyChain([][]byte{leaf.Cert.Raw}, time.Now())
+	assert.ErrorAs(t, err, new(x509.CertificateInvalidError))
+}
+
+func TestCertificateAuthorityKeyUsages(t *testing.T) {
+	root := certtest.Issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	ca := CertificateAuthority{Roots: []*x509.Certificate{root.Cert}}
+
+	tests := []struct {
+		name        string
+		keyUsage    x509.KeyUsage
+		extKeyUsage []x509.ExtKeyUsage
+		wantErr     string
+	}{
+		{
+			name:        "code signing",
+			keyUsage:    x509.KeyUsageDigitalSignature,
+			extKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		},
+		{
+			name:        "TLS server certificate",
+			keyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			extKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			wantErr:     "x509: certificate specifies an incompatible key usage",
+		},
+		{
+			name:     "no extended key usage",
+			keyUsage: x509.KeyUsageDigitalSignature,
+			wantErr:  "certificate can't be used for signing: certificate does not have any of the extended key usages: codeSigning",
+		},
+		{
+			name:        "any extended key usage",
+			keyUsage:    x509.KeyUsageDigitalSignature,
+			extKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+			wantErr:     "certificate can't be used for signing: certificate does not have any of the extended key usages: codeSigning",
+		},
+		{
+			name:        "no digital signature key usage",
+			keyUsage:    x509.KeyUsageKeyEncipherment,
+			extKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+			wantErr:     "certificate can't be used for signing: certificate does not have the digital signature key usage",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaf := certtest.Issue(t, &x509.Certificate{
+				Subject:     pkix.Name{CommonName: "idp.example.com"},
+				KeyUsage:    tt.keyUsage,
+				ExtKeyUsage: tt.extKeyUsage,
+			}, &root)
+			_, err := ca.VerifyChain([][]byte{leaf.Cert.Raw}, time.Now())
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+
+	// the certificate authority can require other extended key usages
+	leaf := certtest.Issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "idp.example.com"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &root)
+	_, err := ca.VerifyChain([][]byte{leaf.Cert.Raw}, time.Now())
+	assert.Error(t, err)
+	ca.ExtKeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	_, err = ca.VerifyChain([][]byte{leaf.Cert.Raw}, time.Now())
+	assert.NoError(t, err)
+}
@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Link records the digest of the envelope which precedes a payload in a bundle.
+// Payloads embed a Link so that the signatures over each envelope also cover
+// every envelope before it. This prevents envelopes from being reordered, or
+// being spliced from one bundle into another, without invalidating signatures.
+type Link struct {
+	Previous string `json:"previous,omitempty"`
+}
+
+// PreviousDigest returns the digest of the preceding envelope in the bundle.
+// It is empty for the first envelope in a bundle.
+func (l *Link) PreviousDigest() string {
+	return l.Previous
+}
+
+// SetPreviousDigest sets the digest of the preceding envelope in the bundle.
+func (l *Link) SetPreviousDigest(digest string) {
+	l.Previous = digest
+}
+
+// ErrBrokenChain is returned when an envelope in a bundle doesn't
+// refer to the envelope preceding it.
+type ErrBrokenChain struct {
+	Index    int
+	Expected string
+	Actual   string
+}
+
+func (e *ErrBrokenChain) Error() string {
+	return fmt.Sprintf("envelope %d is not linked to the previous envelope: expected previous digest %q but got %q", e.Index, e.Expected, e.Actual)
+}
+
+// Digest returns the digest of the envelope, which the next envelope in a bundle refers to.
+// The digest covers the payload type and payload, but not the signatures, so that
+// envelopes can be countersigned without breaking the chain.
+func (e *Envelope) Digest() string {
+	digest := sha256.Sum256(PAE(e.PayloadType, e.Payload))
+	return "sha256:" + hex.EncodeToString(digest[:])
+}
+
+// Head returns the digest of the last envelope in the bundle,
+// or an empty string if the bundle is empty.
+func (b Bundle) Head() string {
+	if len(b) == 0 {
+		return ""
+	}
+	return b[len(b)-1].Digest()
+}
+
+// Link returns the link in the envelope's payload, which is empty
+// if the payload doesn't refer to a previous envelope.
+func (e *Envelope) Link() (Link, error) {
+	var link Link
+	err := json.Unmarshal(e.Payload, &link)
+	return link, err
+}
+
+// VerifyChain verifies that each envelope in the bundle refers to the digest of
+// the envelope preceding it, and that the first envelope doesn't refer to any envelope.
+func (b Bundle) VerifyChain() error {
+	for i := range b {
+		err := b.VerifyLink(i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyLink verifies that the envelope at index i refers to the digest of the
+// envelope preceding it, or doesn't refer to any envelope if it is the first.
+func (b Bundle) VerifyLink(i int) error {
+	link, err := b[i].Link()
+	if err != nil {
+		return err
+	}
+
+	var expected string
+	if i > 0 {
+		expected = b[i-1].Digest()
+	}
+
+	if link.Previous != expected {
+		return &ErrBrokenChain{
+			Index:    i,
+			Expected: expected,
+			Actual:   link.Previous,
+		}
+	}
+	return nil
+}
+
+// NewChainedEnvelope creates an envelope for a payload which is to be appended to a bundle.
+// The payload is linked to the last envelope in the bundle.
+func NewChainedEnvelope(b Bundle, p Payload) (Envelope, error) {
+	p.SetPreviousDigest(b.Head())
+	return EnvelopeFromPayload(p)
+}
@@ -0,0 +1,299 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Standard OIDC claim names.
+const (
+	ClaimIssuer   = "iss"
+	ClaimSubject  = "sub"
+	ClaimAudience = "aud"
+	ClaimEmail    = "email"
+	ClaimGroups   = "groups"
+	ClaimAMR      = "amr"
+	ClaimACR      = "acr"
+	ClaimAuthTime = "auth_time"
+)
+
+// OIDCClaims is a typed view over the standard OIDC claims in an authentication.
+// Claims which weren't present are left empty.
+type OIDCClaims struct {
+	Issuer   string
+	Subject  string
+	Audience []string
+	Email    string
+	Groups   []string
+	// AMR are the authentication methods used, such as "pwd" and "mfa"
+	AMR []string
+	// ACR is the authentication context class reference
+	ACR      string
+	AuthTime time.Time
+}
+
+// HasAudience reports whether the audience includes aud.
+func (c OIDCClaims) HasAudience(aud string) bool {
+	return contains(c.Audience, aud)
+}
+
+// InGroup reports whether the user is a member of the group.
+func (c OIDCClaims) InGroup(group string) bool {
+	return contains(c.Groups, group)
+}
+
+// HasAMR reports whether the user authenticated using the method.
+func (c OIDCClaims) HasAMR(method string) bool {
+	return contains(c.AMR, method)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrInvalidClaim is returned when a claim doesn't have the type required by OIDC.
+type ErrInvalidClaim struct {
+	Claim string
+	Msg   string
+}
+
+func (e *ErrInvalidClaim) Error() string {
+	return fmt.Sprintf("invalid %s claim: %s", e.Claim, e.Msg)
+}
+
+// OIDCClaims parses the standard OIDC claims from the authentication's claims.
+func (m *AuthenticatedPayload) OIDCClaims() (OIDCClaims, error) {
+	var c OIDCClaims
+	var err error
+
+	if c.Issuer, err = stringClaim(m.Claims, ClaimIssuer); err != nil {
+		return OIDCClaims{}, err
+	}
+	if c.Subject, err = stringClaim(m.Claims, ClaimSubject); err != nil {
+		return OIDCClaims{}, err
+	}
+	if c.Email, err = stringClaim(m.Claims, ClaimEmail); err != nil {
+		return OIDCClaims{}, err
+	}
+	if c.ACR, err = stringClaim(m.Claims, ClaimACR); err != nil {
+		return OIDCClaims{}, err
+	}
+	// the audience may be a single string, or an array of strings
+	if aud, ok := m.Claims[ClaimAudience].(string); ok {
+		c.Audience = []string{aud}
+	} else if c.Audience, err = stringsClaim(m.Claims, ClaimAudience); err != nil {
+		return OIDCClaims{}, err
+	}
+	if c.Groups, err = stringsClaim(m.Claims, ClaimGroups); err != nil {
+		return OIDCClaims{}, err
+	}
+	if c.AMR, err = stringsClaim(m.Claims, ClaimAMR); err != nil {
+		return OIDCClaims{}, err
+	}
+	if c.AuthTime, err = timeClaim(m.Claims, ClaimAuthTime); err != nil {
+		return OIDCClaims{}, err
+	}
+
+	return c, nil
+}
+
+func stringClaim(claims map[string]interface{}, name string) (string, error) {
+	v, ok := claims[name]
+	if !ok || v == nil {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", &ErrInvalidClaim{Claim: name, Msg: fmt.Sprintf("expected a string but got %T", v)}
+	}
+	return s, nil
+}
+
+func stringsClaim(claims map[string]interface{}, name string) ([]string, error) {
+	v, ok := claims[name]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	switch v := v.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, &ErrInvalidClaim{Claim: name, Msg: fmt.Sprintf("expected an array of strings but it contains %T", item)}
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	default:
+		return nil, &ErrInvalidClaim{Claim: name, Msg: fmt.Sprintf("expected an array of strings but got %T", v)}
+	}
+}
+
+// timeClaim parses a NumericDate claim, which is the number of seconds since the Unix epoch.
+func timeClaim(claims map[string]interface{}, name string) (time.Time, error) {
+	v, ok := claims[name]
+	if !ok || v == nil {
+		return time.Time{}, nil
+	}
+	var seconds float64
+	switch v := v.(type) {
+	case float64:
+		seconds = v
+	case int64:
+		seconds = float64(v)
+	case int:
+		seconds = float64(v)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, &ErrInvalidClaim{Claim: name, Msg: err.Error()}
+		}
+		seconds = f
+	default:
+		return time.Time{}, &ErrInvalidClaim{Claim: name, Msg: fmt.Sprintf("expected a number but got %T", v)}
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+}
+
+// ClaimRequirements are conditions which the OIDC claims of an authentication must meet.
+// Empty fields aren't checked.
+type ClaimRequirements struct {
+	// Present are the names of claims which must be present
+	Present []string `json:"present,omitempty" yaml:"present,omitempty"`
+	// Issuers are the allowed values of the iss claim
+	Issuers []string `json:"issuers,omitempty" yaml:"issuers,omitempty"`
+	// Audience must be included in the aud claim
+	Audience string `json:"audience,omitempty" yaml:"audience,omitempty"`
+	// AMR are authentication methods which must each be included in the amr claim
+	AMR []string `json:"amr,omitempty" yaml:"amr,omitempty"`
+	// ACR are the allowed values of the acr claim
+	ACR []string `json:"acr,omitempty" yaml:"acr,omitempty"`
+	// Groups are groups which the user must be a member of at least one of
+	Groups []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	// EmailDomains are the allowed domains of the email claim
+	EmailDomains []string `json:"emailDomains,omitempty" yaml:"emailDomains,omitempty"`
+}
+
+// ErrMissingClaim is returned when a required claim isn't present.
+type ErrMissingClaim struct {
+	Claim string
+}
+
+func (e *ErrMissingClaim) Error() string {
+	return fmt.Sprintf("required claim %s is missing", e.Claim)
+}
+
+// ErrClaimNotAllowed is returned when a claim's value isn't one of the allowed values.
+type ErrClaimNotAllowed struct {
+	Claim   string
+	Value   string
+	Allowed []string
+}
+
+func (e *ErrClaimNotAllowed) Error() string {
+	return fmt.Sprintf("%s claim %q is not one of the allowed values: %s", e.Claim, e.Value, strings.Join(e.Allowed, ", "))
+}
+
+// ErrMissingClaimValue is returned when a claim doesn't include a required value,
+// for example when the amr claim doesn't include "mfa".
+type ErrMissingClaimValue struct {
+	Claim string
+	// Values are the required values, any one of which would have been sufficient
+	Values []string
+}
+
+func (e *ErrMissingClaimValue) Error() string {
+	if len(e.Values) == 1 {
+		return fmt.Sprintf("%s claim does not include %q", e.Claim, e.Values[0])
+	}
+	return fmt.Sprintf("%s claim does not include any of: %s", e.Claim, strings.Join(e.Values, ", "))
+}
+
+// ValidateClaims checks that the authentication's claims meet the requirements.
+func (m *AuthenticatedPayload) ValidateClaims(r ClaimRequirements) error {
+	for _, name := range r.Present {
+		if v, ok := m.Claims[name]; !ok || v == nil {
+			return &ErrMissingClaim{Claim: name}
+		}
+	}
+
+	c, err := m.OIDCClaims()
+	if err != nil {
+		return err
+	}
+
+	if len(r.Issuers) > 0 && !contains(r.Issuers, c.Issuer) {
+		if c.Issuer == "" {
+			return &ErrMissingClaim{Claim: ClaimIssuer}
+		}
+		return &ErrClaimNotAllowed{Claim: ClaimIssuer, Value: c.Issuer, Allowed: r.Issuers}
+	}
+	if r.Audience != "" && !c.HasAudience(r.Audience) {
+		return &ErrMissingClaimValue{Claim: ClaimAudience, Values: []string{r.Audience}}
+	}
+	for _, method := range r.AMR {
+		if !c.HasAMR(method) {
+			return &ErrMissingClaimValue{Claim: ClaimAMR, Values: []string{method}}
+		}
+	}
+	if len(r.ACR) > 0 && !contains(r.ACR, c.ACR) {
+		if c.ACR == "" {
+			return &ErrMissingClaim{Claim: ClaimACR}
+		}
+		return &ErrClaimNotAllowed{Claim: ClaimACR, Value: c.ACR, Allowed: r.ACR}
+	}
+	if len(r.Groups) > 0 {
+		var member bool
+		for _, g := range r.Groups {
+			if c.InGroup(g) {
+				member = true
+			}
+		}
+		if !member {
+			return &ErrMissingClaimValue{Claim: ClaimGroups, Values: r.Groups}
+		}
+	}
+	if len(r.EmailDomains) > 0 {
+		if c.Email == "" {
+			return &ErrMissingClaim{Claim: ClaimEmail}
+		}
+		domain := c.Email[strings.LastIndex(c.Email, "@")+1:]
+		if !contains(r.EmailDomains, domain) {
+			return &ErrClaimNotAllowed{Claim: ClaimEmail, Value: c.Email, Allowed: r.EmailDomains}
+		}
+	}
+
+	return nil
+}
+
+// ValidateIssuer checks that the authentication was issued by the identity server's
+// issuer, if the identity server has one. This prevents an identity server from
+// vouching for users which were authenticated by a different identity provider.
+func (m *AuthenticatedPayload) ValidateIssuer(server IdentityServer) error {
+	if server.Issuer == "" {
+		return nil
+	}
+	iss, err := stringClaim(m.Claims, ClaimIssuer)
+	if err != nil {
+		return err
+	}
+	if iss == "" {
+		return &ErrMissingClaim{Claim: ClaimIssuer}
+	}
+	if iss != server.Issuer {
+		return &ErrClaimNotAllowed{Claim: ClaimIssuer, Value: iss, Allowed: []string{server.Issuer}}
+	}
+	return nil
+}
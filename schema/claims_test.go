@@ -0,0 +1,120 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parseClaims returns the claims as they are after a round trip through JSON.
+func parseClaims(t *testing.T, raw string) *AuthenticatedPayload {
+	var claims map[string]interface{}
+	err := json.Unmarshal([]byte(raw), &claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthenticatedMessage(AuthMessageOpts{UserID: "alice", Claims: claims})
+}
+
+func TestOIDCClaims(t *testing.T) {
+	p := parseClaims(t, `{
+		"iss": "https://idp.example.com",
+		"sub": "alice",
+		"aud": "granted",
+		"email": "alice@example.com",
+		"groups": ["engineering", "oncall"],
+		"amr": ["pwd", "mfa"],
+		"acr": "urn:example:loa:2",
+		"auth_time": 1640995200
+	}`)
+
+	c, err := p.OIDCClaims()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, OIDCClaims{
+		Issuer:   "https://idp.example.com",
+		Subject:  "alice",
+		Audience: []string{"granted"},
+		Email:    "alice@example.com",
+		Groups:   []string{"engineering", "oncall"},
+		AMR:      []string{"pwd", "mfa"},
+		ACR:      "urn:example:loa:2",
+		AuthTime: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, c)
+	assert.True(t, c.HasAMR("mfa"))
+	assert.True(t, c.InGroup("oncall"))
+	assert.True(t, c.HasAudience("granted"))
+
+	_, err = parseClaims(t, `{"groups": "oncall"}`).OIDCClaims()
+	assert.EqualError(t, err, "invalid groups claim: expected an array of strings but got string")
+}
+
+func TestValidateClaims(t *testing.T) {
+	p := parseClaims(t, `{
+		"iss": "https://idp.example.com",
+		"aud": ["granted", "other"],
+		"email": "alice@example.com",
+		"groups": ["engineering"],
+		"amr": ["pwd"]
+	}`)
+
+	tests := []struct {
+		name string
+		req  ClaimRequirements
+		err  error
+	}{
+		{
+			name: "met",
+			req: ClaimRequirements{
+				Present:      []string{"email"},
+				Issuers:      []string{"https://idp.example.com"},
+				Audience:     "granted",
+				AMR:          []string{"pwd"},
+				Groups:       []string{"oncall", "engineering"},
+				EmailDomains: []string{"example.com"},
+			},
+		},
+		{
+			name: "missing claim",
+			req:  ClaimRequirements{Present: []string{"sub"}},
+			err:  &ErrMissingClaim{Claim: "sub"},
+		},
+		{
+			name: "issuer not allowed",
+			req:  ClaimRequirements{Issuers: []string{"https://other.example.com"}},
+			err:  &ErrClaimNotAllowed{Claim: "iss", Value: "https://idp.example.com", Allowed: []string{"https://other.example.com"}},
+		},
+		{
+			name: "mfa required",
+			req:  ClaimRequirements{AMR: []string{"mfa"}},
+			err:  &ErrMissingClaimValue{Claim: "amr", Values: []string{"mfa"}},
+		},
+		{
+			name: "acr missing",
+			req:  ClaimRequirements{ACR: []string{"urn:example:loa:2"}},
+			err:  &ErrMissingClaim{Claim: "acr"},
+		},
+		{
+			name: "not in group",
+			req:  ClaimRequirements{Groups: []string{"oncall", "admins"}},
+			err:  &ErrMissingClaimValue{Claim: "groups", Values: []string{"oncall", "admins"}},
+		},
+		{
+			name: "email domain not allowed",
+			req:  ClaimRequirements{EmailDomains: []string{"example.org"}},
+			err:  &ErrClaimNotAllowed{Claim: "email", Value: "alice@example.com", Allowed: []string{"example.org"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.ValidateClaims(tt.req)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+
+	assert.EqualError(t, p.ValidateClaims(ClaimRequirements{AMR: []string{"mfa"}}), `amr claim does not include "mfa"`)
+}
@@ -1,22 +1,92 @@
 package schema
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/common-fate/attestations/types"
 )
 
+// DecisionOutcome is the outcome of evaluating an access request.
+type DecisionOutcome string
+
+const (
+	// DecisionAllow allows access without requiring approval
+	DecisionAllow DecisionOutcome = "allow"
+	// DecisionDeny denies access. A denied request can't be approved.
+	DecisionDeny DecisionOutcome = "deny"
+	// DecisionPendingApproval requires the request to be approved before access is granted
+	DecisionPendingApproval DecisionOutcome = "pending-approval"
+)
+
 type Decision struct {
+	// AutoAllow and RequireApproval are the decision's outcome as it was recorded
+	// in v0.1 decisions, before outcomes were introduced. They are kept in sync
+	// with Outcome by NewDecisionPayload.
 	AutoAllow       bool
 	RequireApproval bool
+	// Outcome is the outcome of the decision. If empty, the outcome
+	// is derived from AutoAllow and RequireApproval.
+	Outcome DecisionOutcome `json:",omitempty"`
+	// Reason explains why the outcome was reached, for auditors
+	Reason string `json:",omitempty"`
+	// RuleID is the ID of the policy rule which matched the request
+	RuleID string `json:",omitempty"`
+	// PolicyID and PolicyDigest identify the policy which was evaluated to make the decision,
+	// if it was made by a policy. The digest allows auditors to find the exact policy version.
+	PolicyID     string `json:",omitempty"`
+	PolicyDigest string `json:",omitempty"`
+	// Message is an optional message to show to the user
+	Message string `json:",omitempty"`
+	// ApproverGroup is the name of the approver group which must approve the request.
+	// If empty, approval from any single trusted approver is sufficient.
+	ApproverGroup string `json:",omitempty"`
+	// DecidedAt is when the decision was made. The identity server's signature
+	// is verified using the keys which were valid at this time.
+	DecidedAt time.Time
+}
+
+// EffectiveOutcome returns the decision's outcome, mapping decisions
+// which were recorded before outcomes were introduced.
+func (d Decision) EffectiveOutcome() DecisionOutcome {
+	if d.Outcome != "" {
+		return d.Outcome
+	}
+	switch {
+	case d.RequireApproval:
+		return DecisionPendingApproval
+	case d.AutoAllow:
+		return DecisionAllow
+	default:
+		return DecisionDeny
+	}
 }
 
 type DecisionPayload struct {
+	Link
 	Decision    Decision      `json:"decision"`
 	PayloadType types.Payload `json:"type"`
+
+	// UpgradedFrom is the payload type which the decision was upgraded from
+	// when it was deserialized, or empty if the decision wasn't upgraded.
+	UpgradedFrom types.Payload `json:"-"`
 }
 
+// decisionV01 is the decision contained in v0.1 payloads.
+type decisionV01 struct {
+	AutoAllow       bool
+	RequireApproval bool
+}
+
+// NewDecisionPayload creates a decision payload. If the decision doesn't have an
+// outcome, it is derived from AutoAllow and RequireApproval. AutoAllow and
+// RequireApproval are then set to match the outcome.
 func NewDecisionPayload(d Decision) *DecisionPayload {
+	d.Outcome = d.EffectiveOutcome()
+	d.AutoAllow = d.Outcome == DecisionAllow
+	d.RequireApproval = d.Outcome == DecisionPendingApproval
 	return &DecisionPayload{
 		Decision:    d,
 		PayloadType: types.PayloadDecision,
@@ -31,6 +101,82 @@ func (m *DecisionPayload) MarshalJSON() ([]byte, error) {
 	return json.Marshal(*m)
 }
 
+// UnmarshalJSON unmarshals both v0.1 and v0.2 decision payloads. v0.1 payloads
+// are decoded using the v0.1 decision fields, and are rejected if they contain any
+// fields which were added in v0.2, so that a decision can't be signed as v0.1 to
+// avoid being validated against the v0.2 rules for those fields.
+func (m *DecisionPayload) UnmarshalJSON(data []byte) error {
+	type payload DecisionPayload
+	var p payload
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+
+	if p.PayloadType == types.PayloadDecisionV01 {
+		var v01 struct {
+			Link
+			Decision    decisionV01   `json:"decision"`
+			PayloadType types.Payload `json:"type"`
+		}
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		err = d.Decode(&v01)
+		if err != nil {
+			return &ErrInvalidPayloadContents{Msg: fmt.Sprintf("%s decision: %s", p.PayloadType, err)}
+		}
+		p = payload{
+			Link:        v01.Link,
+			Decision:    Decision{AutoAllow: v01.Decision.AutoAllow, RequireApproval: v01.Decision.RequireApproval},
+			PayloadType: v01.PayloadType,
+		}
+	}
+
+	*m = DecisionPayload(p)
+	return nil
+}
+
+// Upgrade upgrades a v0.1 decision to the current version, deriving
+// its outcome from AutoAllow and RequireApproval. v0.1 decisions didn't
+// attest to the time they were made, so DecidedAt is left empty.
+func (m *DecisionPayload) Upgrade() error {
+	if m.PayloadType == types.PayloadDecisionV01 {
+		m.UpgradedFrom = m.PayloadType
+		m.PayloadType = types.PayloadDecision
+		m.Decision.Outcome = m.Decision.EffectiveOutcome()
+	}
+	return nil
+}
+
+// ValidateContents validates the decision. Decisions upgraded
+// from v0.1 are validated against the same rules.
+//
+// Rules:
+// - the outcome must be set, and must be allow, deny or pending-approval
+// - AutoAllow and RequireApproval must agree with the outcome
+// - a denial must give a reason, unless it was upgraded from v0.1, which couldn't record one
+// - only decisions pending approval may name an approver group
 func (m *DecisionPayload) ValidateContents(f Facts) error {
+	d := m.Decision
+	if d.Outcome == "" {
+		return &ErrInvalidPayloadContents{Msg: "decision did not specify an outcome"}
+	}
+	outcome := d.Outcome
+	switch outcome {
+	case DecisionAllow, DecisionDeny, DecisionPendingApproval:
+	default:
+		return &ErrInvalidPayloadContents{Msg: fmt.Sprintf("unknown decision outcome %s", outcome)}
+	}
+
+	if d.AutoAllow != (outcome == DecisionAllow) || d.RequireApproval != (outcome == DecisionPendingApproval) {
+		return &ErrInvalidPayloadContents{Msg: fmt.Sprintf("decision flags do not match the %s outcome", outcome)}
+	}
+	if outcome == DecisionDeny && d.Reason == "" && m.UpgradedFrom == "" {
+		return &ErrInvalidPayloadContents{Msg: "denied decision did not give a reason"}
+	}
+	if d.ApproverGroup != "" && outcome != DecisionPendingApproval {
+		return &ErrInvalidPayloadContents{Msg: fmt.Sprintf("a decision with the %s outcome can't require approval from an approver group", outcome)}
+	}
+
 	return nil
 }
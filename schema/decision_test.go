@@ -0,0 +1,139 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/common-fate/attestations/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecisionEffectiveOutcome(t *testing.T) {
+	// decisions recorded before outcomes were introduced
+	assert.Equal(t, DecisionAllow, Decision{AutoAllow: true}.EffectiveOutcome())
+	assert.Equal(t, DecisionPendingApproval, Decision{RequireApproval: true}.EffectiveOutcome())
+	assert.Equal(t, DecisionDeny, Decision{}.EffectiveOutcome())
+
+	assert.Equal(t, DecisionDeny, Decision{Outcome: DecisionDeny, Reason: "test"}.EffectiveOutcome())
+}
+
+func TestNewDecisionPayloadSetsLegacyFlags(t *testing.T) {
+	d := NewDecisionPayload(Decision{Outcome: DecisionAllow}).Decision
+	assert.True(t, d.AutoAllow)
+	assert.False(t, d.RequireApproval)
+
+	d = NewDecisionPayload(Decision{Outcome: DecisionPendingApproval}).Decision
+	assert.False(t, d.AutoAllow)
+	assert.True(t, d.RequireApproval)
+
+	d = NewDecisionPayload(Decision{Outcome: DecisionDeny, AutoAllow: true}).Decision
+	assert.False(t, d.AutoAllow)
+	assert.False(t, d.RequireApproval)
+}
+
+func TestDecisionValidateContents(t *testing.T) {
+	tests := []struct {
+		name     string
+		decision Decision
+		wantErr  string
+	}{
+		{
+			name:     "legacy",
+			decision: Decision{AutoAllow: true},
+		},
+		{
+			name:     "denied",
+			decision: Decision{Outcome: DecisionDeny, Reason: "production access is not allowed", RuleID: "no-prod", Message: "ask your manager"},
+		},
+		{
+			name:     "denied without reason",
+			decision: Decision{Outcome: DecisionDeny},
+			wantErr:  "invalid payload contents: denied decision did not give a reason",
+		},
+		{
+			name:     "unknown outcome",
+			decision: Decision{Outcome: "maybe"},
+			wantErr:  "invalid payload contents: unknown decision outcome maybe",
+		},
+		{
+			name:     "approver group when allowed",
+			decision: Decision{Outcome: DecisionAllow, ApproverGroup: "admins"},
+			wantErr:  "invalid payload contents: a decision with the allow outcome can't require approval from an approver group",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDecisionPayload(tt.decision).ValidateContents(Facts{})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+
+	// flags which disagree with the outcome are rejected,
+	// so that the outcome is unambiguous
+	p := &DecisionPayload{Decision: Decision{Outcome: DecisionDeny, Reason: "test", AutoAllow: true}}
+	assert.EqualError(t, p.ValidateContents(Facts{}), "invalid payload contents: decision flags do not match the deny outcome")
+}
+
+func TestUpgradeDecisionV01(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    Decision
+	}{
+		{
+			payload: `{"decision":{"AutoAllow":true,"RequireApproval":false},"type":"granted.dev/Decision/v0.1"}`,
+			want:    Decision{AutoAllow: true, Outcome: DecisionAllow},
+		},
+		{
+			payload: `{"decision":{"AutoAllow":false,"RequireApproval":true},"type":"granted.dev/Decision/v0.1"}`,
+			want:    Decision{RequireApproval: true, Outcome: DecisionPendingApproval},
+		},
+		{
+			payload: `{"decision":{"AutoAllow":false,"RequireApproval":false},"type":"granted.dev/Decision/v0.1"}`,
+			want:    Decision{Outcome: DecisionDeny},
+		},
+	}
+
+	opts := DeserializeOptions{AcceptLegacy: true}
+	for _, tt := range tests {
+		// v0.1 decisions are only accepted if legacy payloads are enabled
+		_, err := DeserializePayload([]byte(tt.payload), types.PayloadDecision)
+		assert.ErrorAs(t, err, new(*ErrLegacyPayloadType))
+
+		p, err := DeserializePayloadWithOptions([]byte(tt.payload), types.PayloadDecision, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := p.(*DecisionPayload)
+		assert.Equal(t, types.PayloadDecision, d.Type())
+		assert.Equal(t, types.PayloadDecisionV01, d.UpgradedFrom)
+		assert.Equal(t, tt.want, d.Decision)
+		// v0.1 denials couldn't give a reason
+		assert.NoError(t, d.ValidateContents(Facts{}))
+	}
+
+	// a decision can't be signed as v0.1 to carry v0.2 fields past the v0.2 rules
+	for _, field := range []string{`"Outcome":"deny"`, `"Reason":"test"`, `"ApproverGroup":"admins"`, `"PolicyID":"test"`, `"DecidedAt":"2022-01-01T00:00:00Z"`} {
+		payload := []byte(`{"decision":{"AutoAllow":true,"RequireApproval":false,` + field + `},"type":"granted.dev/Decision/v0.1"}`)
+		_, err := DeserializePayloadWithOptions(payload, types.PayloadDecision, opts)
+		assert.ErrorAs(t, err, new(*ErrInvalidPayloadContents), field)
+	}
+}
+
+func TestDecisionV02RequiresOutcome(t *testing.T) {
+	p, err := DeserializePayload([]byte(`{"decision":{"AutoAllow":true,"RequireApproval":false},"type":"granted.dev/Decision/v0.2"}`), types.PayloadDecision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualError(t, p.ValidateContents(Facts{}), "invalid payload contents: decision did not specify an outcome")
+
+	// denials made since v0.2 must give a reason
+	p, err = DeserializePayload([]byte(`{"decision":{"AutoAllow":false,"RequireApproval":false,"Outcome":"deny"},"type":"granted.dev/Decision/v0.2"}`), types.PayloadDecision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualError(t, p.ValidateContents(Facts{}), "invalid payload contents: denied decision did not give a reason")
+}
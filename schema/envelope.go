@@ -2,13 +2,8 @@ package schema
 
 import (
 	"context"
-	"crypto"
-	"crypto/ecdsa"
-	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"math/big"
 	"strings"
 )
 
@@ -68,57 +63,170 @@ type DebugEnvelope struct {
 	Payload     string      `json:"payload"`
 }
 
-type Signature []byte
-
+// ErrMissingSignatures is returned when one or more expected signers
+// haven't produced a valid signature over an envelope.
 type ErrMissingSignatures struct {
-	MissingKeys []ecdsa.PublicKey
+	// Missing are signers with no signature in the envelope
+	Missing []ExpectedSigner
+	// Invalid are signers whose signature in the envelope failed verification
+	Invalid []ExpectedSigner
 }
 
 func (e *ErrMissingSignatures) Error() string {
-	encodedKeys := []string{}
-	for _, key := range e.MissingKeys {
-		var keyString string
-		enc, err := x509.MarshalPKIXPublicKey(&key)
+	var msgs []string
+	if len(e.Missing) > 0 {
+		msgs = append(msgs, fmt.Sprintf("missing signatures from %s", describeSigners(e.Missing)))
+	}
+	if len(e.Invalid) > 0 {
+		msgs = append(msgs, fmt.Sprintf("invalid signatures from %s", describeSigners(e.Invalid)))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func describeSigners(signers []ExpectedSigner) string {
+	descriptions := []string{}
+	for _, s := range signers {
+		keyID, err := s.KeyID()
 		if err != nil {
-			keyString = "error"
-		} else {
-			keyString = base64.StdEncoding.EncodeToString(enc)
+			keyID = "error"
 		}
-		encodedKeys = append(encodedKeys, keyString)
+		descriptions = append(descriptions, fmt.Sprintf("%s (keyid %s)", s.Name, keyID))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// SigningString returns the bytes covered by the envelope's signatures
+// for the given signing version.
+func (e *Envelope) SigningString(v SigningVersion) ([]byte, error) {
+	switch v {
+	case SigningVersionDSSEv1:
+		return PAE(e.PayloadType, e.Payload), nil
+	case SigningVersionLegacy:
+		return e.Payload, nil
+	default:
+		return nil, fmt.Errorf("unsupported signing version %s", v)
 	}
+}
 
-	return fmt.Sprintf("missing signatures from %s", strings.Join(encodedKeys, ", "))
+// VerifySignatures verifies that each of the expected signers has signed the envelope.
+// Signatures must be over the DSSE pre-authentication encoding of the envelope.
+func (e *Envelope) VerifySignatures(expected []ExpectedSigner) error {
+	return e.VerifySignaturesWithVersions(expected, SigningVersionDSSEv1)
 }
 
-func (e *Envelope) VerifySignatures(expected []ecdsa.PublicKey) error {
-	missingKeys := []ecdsa.PublicKey{}
+// VerifySignaturesWithVersions verifies that each of the expected signers has signed the envelope
+// using any of the accepted signing versions. Use this with SigningVersionLegacy to verify
+// bundles which were signed before DSSE signing was introduced.
+func (e *Envelope) VerifySignaturesWithVersions(expected []ExpectedSigner, versions ...SigningVersion) error {
+	var missing, invalid []ExpectedSigner
 
-	for _, key := range expected {
-		var matchedKey bool
-		var err error
-		for _, sig := range e.Signatures {
-			matchedKey, err = VerifyECDSA(e.Payload, sig, &key)
-			if err != nil {
-				return err
-			}
-			if matchedKey {
-				break
-			}
+	signingStrings, err := e.signingStrings(versions)
+	if err != nil {
+		return err
+	}
+
+	for _, signer := range expected {
+		status, err := e.verifySigner(signer, signingStrings)
+		if err != nil {
+			return err
 		}
-		if !matchedKey {
-			missingKeys = append(missingKeys, key)
+
+		switch status {
+		case SignatureInvalid:
+			invalid = append(invalid, signer)
+		case SignatureMissing:
+			missing = append(missing, signer)
 		}
 	}
 
-	if len(missingKeys) > 0 {
+	if len(missing) > 0 || len(invalid) > 0 {
 		return &ErrMissingSignatures{
-			MissingKeys: missingKeys,
+			Missing: missing,
+			Invalid: invalid,
 		}
 	}
 
 	return nil
 }
 
+// SignatureStatus is the outcome of verifying an expected signer's signature over an envelope.
+type SignatureStatus string
+
+const (
+	// SignatureValid means that the signer has validly signed the envelope
+	SignatureValid SignatureStatus = "valid"
+	// SignatureMissing means that the envelope has no signature from the signer
+	SignatureMissing SignatureStatus = "missing"
+	// SignatureInvalid means that the envelope has a signature with the signer's
+	// key ID, but the signature failed verification
+	SignatureInvalid SignatureStatus = "invalid"
+)
+
+// VerifySigner returns the status of an expected signer's signature over the envelope,
+// accepting signatures made using any of the provided signing versions.
+func (e *Envelope) VerifySigner(signer ExpectedSigner, versions ...SigningVersion) (SignatureStatus, error) {
+	signingStrings, err := e.signingStrings(versions)
+	if err != nil {
+		return "", err
+	}
+	return e.verifySigner(signer, signingStrings)
+}
+
+func (e *Envelope) signingStrings(versions []SigningVersion) ([][]byte, error) {
+	signingStrings := [][]byte{}
+	for _, v := range versions {
+		ss, err := e.SigningString(v)
+		if err != nil {
+			return nil, err
+		}
+		signingStrings = append(signingStrings, ss)
+	}
+	return signingStrings, nil
+}
+
+func (e *Envelope) verifySigner(signer ExpectedSigner, signingStrings [][]byte) (SignatureStatus, error) {
+	keyIDs, err := signer.KeyIDs()
+	if err != nil {
+		return "", err
+	}
+
+	var found bool
+	for _, sig := range e.Signatures {
+		// signatures without a key ID were made before key IDs were introduced,
+		// so they need to be tried against every expected signer.
+		if sig.KeyID != "" && !contains(keyIDs, sig.KeyID) {
+			continue
+		}
+		if sig.KeyID != "" {
+			found = true
+		}
+		// the algorithm recorded alongside the signature must match the key's,
+		// so that a signature can't be verified using a different algorithm.
+		// Signatures made before algorithms were recorded are always ES256.
+		alg := sig.Algorithm
+		if alg == "" {
+			alg = AlgorithmES256
+		}
+		if alg != signer.PublicKey.Algorithm() {
+			continue
+		}
+		for _, ss := range signingStrings {
+			valid, err := signer.PublicKey.Verify(ss, sig.Sig)
+			if err != nil {
+				return "", err
+			}
+			if valid {
+				return SignatureValid, nil
+			}
+		}
+	}
+
+	if found {
+		return SignatureInvalid, nil
+	}
+	return SignatureMissing, nil
+}
+
 func (e *Envelope) ToString() (string, error) {
 	payloadStr := string(e.Payload)
 
@@ -152,30 +260,39 @@ func EnvelopeFromPayload(p Payload) (Envelope, error) {
 	return e, nil
 }
 
+// Sign adds a signature over the DSSE pre-authentication encoding
+// of the envelope's payload type and payload. If the signer is a
+// CertificateChainSigner, its certificate chain is attached to the signature.
 func (e *Envelope) Sign(ctx context.Context, signer EnvelopeSigner) error {
-	sig, err := signer.Sign(ctx, e.Payload)
+	ss, err := e.SigningString(SigningVersionDSSEv1)
 	if err != nil {
 		return err
 	}
-
-	e.Signatures = append(e.Signatures, sig)
-	return nil
-}
-
-// VerifyECDSA verifies an ECDSA signature.
-// It first parses R and S coefficients from the signature.
-func VerifyECDSA(singingString, sig []byte, pubKey *ecdsa.PublicKey) (bool, error) {
-	keySize := 32
-	r := new(big.Int).SetBytes(sig[:keySize])
-	s := new(big.Int).SetBytes(sig[keySize:])
-
-	hasher := crypto.SHA256.New()
-	_, err := hasher.Write(singingString)
+	keyID, err := signer.KeyID()
+	if err != nil {
+		return err
+	}
+	alg, err := signer.Algorithm()
 	if err != nil {
-		return false, err
+		return err
+	}
+	var chain [][]byte
+	if cs, ok := signer.(CertificateChainSigner); ok {
+		chain, err = cs.CertificateChain()
+		if err != nil {
+			return err
+		}
+	}
+	sig, err := signer.Sign(ctx, ss)
+	if err != nil {
+		return err
 	}
-	hashedSigningString := hasher.Sum(nil)
 
-	valid := ecdsa.Verify(pubKey, hashedSigningString, r, s)
-	return valid, nil
+	e.Signatures = append(e.Signatures, Signature{
+		KeyID:        keyID,
+		Algorithm:    alg,
+		Sig:          sig,
+		Certificates: chain,
+	})
+	return nil
 }
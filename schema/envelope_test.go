@@ -5,6 +5,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,7 +17,7 @@ func TestVerifySignaturesWorks(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	pub := priv.PublicKey
+	pub := &ECDSAPublicKey{Key: &priv.PublicKey}
 
 	e := Envelope{
 		Payload: []byte("test"),
@@ -25,7 +26,7 @@ func TestVerifySignaturesWorks(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = e.VerifySignatures([]ecdsa.PublicKey{pub})
+	err = e.VerifySignatures([]ExpectedSigner{{Name: "test", PublicKey: pub}})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -36,13 +37,144 @@ func TestVerifySignaturesReturnsError(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	pub := priv.PublicKey
+	pub := &ECDSAPublicKey{Key: &priv.PublicKey}
 
 	e := Envelope{
 		Payload: []byte("test"),
 	}
-	err = e.VerifySignatures([]ecdsa.PublicKey{pub})
+	err = e.VerifySignatures([]ExpectedSigner{{Name: "test", PublicKey: pub}})
 	target := &ErrMissingSignatures{}
 	assert.ErrorAs(t, err, &target)
-	assert.Equal(t, target.MissingKeys, []ecdsa.PublicKey{pub})
+	assert.Equal(t, target.Missing, []ExpectedSigner{{Name: "test", PublicKey: pub}})
+}
+
+func TestPAE(t *testing.T) {
+	// test vector from the DSSE protocol specification
+	got := PAE("http://example.com/HelloWorld", []byte("hello world"))
+	assert.Equal(t, "DSSEv1 29 http://example.com/HelloWorld 11 hello world", string(got))
+}
+
+func TestVerifySignaturesCoversPayloadType(t *testing.T) {
+	ctx := context.Background()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &ECDSAPublicKey{Key: &priv.PublicKey}
+
+	e := Envelope{
+		PayloadType: "application/granted+json",
+		Payload:     []byte("test"),
+	}
+	err = e.Sign(ctx, &LocalSigner{PrivateKey: priv})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// swapping the payload type must invalidate the signature
+	e.PayloadType = "application/other+json"
+	err = e.VerifySignatures([]ExpectedSigner{{Name: "test", PublicKey: pub}})
+	target := &ErrMissingSignatures{}
+	assert.ErrorAs(t, err, &target)
+}
+
+func TestVerifyLegacySignatures(t *testing.T) {
+	ctx := context.Background()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &ECDSAPublicKey{Key: &priv.PublicKey}
+
+	e := Envelope{
+		PayloadType: "application/granted+json",
+		Payload:     []byte("test"),
+	}
+	// sign the raw payload, as envelopes were signed prior to DSSE
+	sig, err := (&LocalSigner{PrivateKey: priv}).Sign(ctx, e.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Signatures = append(e.Signatures, Signature{Sig: sig})
+
+	err = e.VerifySignatures([]ExpectedSigner{{Name: "test", PublicKey: pub}})
+	target := &ErrMissingSignatures{}
+	assert.ErrorAs(t, err, &target)
+
+	err = e.VerifySignaturesWithVersions([]ExpectedSigner{{Name: "test", PublicKey: pub}}, SigningVersionDSSEv1, SigningVersionLegacy)
+	assert.NoError(t, err)
+}
+
+func TestSignRecordsKeyID(t *testing.T) {
+	ctx := context.Background()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID, err := KeyID(&ECDSAPublicKey{Key: &priv.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := Envelope{
+		Payload: []byte("test"),
+	}
+	err = e.Sign(ctx, &LocalSigner{PrivateKey: priv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, keyID, e.Signatures[0].KeyID)
+	assert.Equal(t, AlgorithmES256, e.Signatures[0].Algorithm)
+}
+
+func TestVerifySignaturesReportsInvalidSigner(t *testing.T) {
+	ctx := context.Background()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := ExpectedSigner{Name: "user", PublicKey: &ECDSAPublicKey{Key: &priv.PublicKey}}
+
+	e := Envelope{
+		Payload: []byte("test"),
+	}
+	err = e.Sign(ctx, &LocalSigner{PrivateKey: priv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Payload = []byte("tampered")
+
+	err = e.VerifySignatures([]ExpectedSigner{signer})
+	target := &ErrMissingSignatures{}
+	assert.ErrorAs(t, err, &target)
+	assert.Empty(t, target.Missing)
+	assert.Equal(t, []ExpectedSigner{signer}, target.Invalid)
+}
+
+func TestUnmarshalLegacySignature(t *testing.T) {
+	var e Envelope
+	err := json.Unmarshal([]byte(`{"signatures":["dGVzdA=="],"payloadType":"application/granted+json","payload":"dGVzdA=="}`), &e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Signature{{Algorithm: AlgorithmES256, Sig: []byte("test")}}, e.Signatures)
+}
+
+func TestVerifyChain(t *testing.T) {
+	first, err := EnvelopeFromPayload(NewAccessRequestMessage(AccessRequest{Role: "first"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewChainedEnvelope(Bundle{first}, NewAccessRequestMessage(AccessRequest{Role: "second"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Bundle{first, second}.VerifyChain()
+	assert.NoError(t, err)
+
+	err = Bundle{second, first}.VerifyChain()
+	target := &ErrBrokenChain{}
+	assert.ErrorAs(t, err, &target)
+	assert.Equal(t, 0, target.Index)
 }
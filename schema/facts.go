@@ -1,98 +1,567 @@
 package schema
 
 import (
-	"crypto/ecdsa"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
-
-	cfcrypto "github.com/common-fate/cfcrypto"
 )
 
 type Actors struct {
 	User           User           `json:"user"`
 	IdentityServer IdentityServer `json:"identityServer"`
+	// IdentityServers are further trusted identity servers, such as servers in
+	// other regions or a new identity provider which is being migrated to.
+	// An authentication may be vouched for by any trusted identity server.
+	IdentityServers []IdentityServer `json:"identityServers"`
+	// Approvers are trusted to approve access requests which require approval
+	Approvers []Approver `json:"approvers"`
+	// ApproverGroups are named groups of approvers, of which a threshold
+	// must approve a request. Decisions may require approval from a group.
+	ApproverGroups []ApproverGroup `json:"approverGroups"`
+}
+
+// Approver returns the trusted approver with the given ID.
+func (a Actors) Approver(id string) (Approver, bool) {
+	for _, approver := range a.Approvers {
+		if approver.ID == id {
+			return approver, true
+		}
+	}
+	return Approver{}, false
 }
 
 type User struct {
 	ID        string
-	PublicKey ecdsa.PublicKey
+	PublicKey PublicKey
+}
+
+// ApproverGroup is a named group of approvers, of which at least
+// Threshold must approve a request.
+type ApproverGroup struct {
+	Name        string   `json:"name"`
+	ApproverIDs []string `json:"approverIds"`
+	Threshold   int      `json:"threshold"`
+}
+
+// ApproverGroup returns the approver group with the given name.
+func (a Actors) ApproverGroup(name string) (ApproverGroup, bool) {
+	for _, group := range a.ApproverGroups {
+		if group.Name == name {
+			return group, true
+		}
+	}
+	return ApproverGroup{}, false
+}
+
+// ApprovalPolicy returns the threshold policy for an approver group.
+// Each approver in the group must be a trusted approver.
+func (a Actors) ApprovalPolicy(group ApproverGroup) (ThresholdPolicy, error) {
+	policy := ThresholdPolicy{
+		Threshold: group.Threshold,
+	}
+	for _, id := range group.ApproverIDs {
+		approver, ok := a.Approver(id)
+		if !ok {
+			return ThresholdPolicy{}, fmt.Errorf("approver group %s contains unknown approver %s", group.Name, id)
+		}
+		policy.Candidates = append(policy.Candidates, approver.Signer())
+	}
+	return policy, nil
 }
 
 type IdentityServer struct {
-	PublicKey ecdsa.PublicKey
+	// ID identifies the identity server among the trusted identity servers
+	ID string
+	// Issuer, if set, is the OIDC issuer which the identity server authenticates
+	// users with. Authentications it vouches for must have a matching iss claim.
+	Issuer    string
+	PublicKey PublicKey
+	// PublicKeys are further keys which the identity server may sign with
+	PublicKeys []PublicKey
+	// CertificateAuthority, if set, certifies keys which the identity server may sign with.
+	// Signatures made with a certified key must carry the key's certificate chain,
+	// so the key can be rotated without distributing it to verifiers.
+	CertificateAuthority *CertificateAuthority
+}
+
+// Keys returns each of the identity server's public keys.
+func (i IdentityServer) Keys() []PublicKey {
+	var keys []PublicKey
+	if i.PublicKey != nil {
+		keys = append(keys, i.PublicKey)
+	}
+	return append(keys, i.PublicKeys...)
+}
+
+// TrustedIdentityServers returns each of the trusted identity servers which has at least
+// one key or a certificate authority, starting with the primary identity server.
+func (a Actors) TrustedIdentityServers() []IdentityServer {
+	return a.trustedIdentityServers(nil)
+}
+
+// trustedIdentityServers returns each of the identity servers which has at least one
+// key, either in the Facts or in the trust store, or which has a certificate authority.
+func (a Actors) trustedIdentityServers(store *TrustStore) []IdentityServer {
+	var servers []IdentityServer
+	for _, server := range append([]IdentityServer{a.IdentityServer}, a.IdentityServers...) {
+		if len(server.Keys()) > 0 || server.CertificateAuthority != nil || store.Has(server.signerName()) {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// TrustedIdentityServer returns the trusted identity server with the given ID.
+func (a Actors) TrustedIdentityServer(id string) (IdentityServer, bool) {
+	for _, server := range a.TrustedIdentityServers() {
+		if server.ID == id {
+			return server, true
+		}
+	}
+	return IdentityServer{}, false
+}
+
+type Approver struct {
+	ID        string
+	PublicKey PublicKey
+}
+
+// Actor names, used to report which actor failed to sign an envelope.
+const (
+	ActorUser           = "user"
+	ActorIdentityServer = "identityServer"
+	ActorApprover       = "approver"
+)
+
+// Signer returns the user as an expected signer of an envelope.
+func (u User) Signer() ExpectedSigner {
+	return ExpectedSigner{Name: ActorUser, PublicKey: u.PublicKey}
+}
+
+// Signer returns the identity server as an expected signer of an envelope.
+func (i IdentityServer) Signer() ExpectedSigner {
+	return ExpectedSigner{Name: i.signerName(), PublicKey: i.PublicKey}
+}
+
+// Signers returns the identity server as an expected signer of an envelope
+// for each of its keys. A signature made with any one of them is sufficient.
+func (i IdentityServer) Signers() []ExpectedSigner {
+	var signers []ExpectedSigner
+	for _, key := range i.Keys() {
+		signers = append(signers, ExpectedSigner{Name: i.signerName(), PublicKey: key})
+	}
+	return signers
+}
+
+func (i IdentityServer) signerName() string {
+	if i.ID == "" {
+		return ActorIdentityServer
+	}
+	return ActorIdentityServer + ":" + i.ID
+}
+
+// Signer returns the approver as an expected signer of an envelope.
+func (a Approver) Signer() ExpectedSigner {
+	return ExpectedSigner{Name: ActorApprover + ":" + a.ID, PublicKey: a.PublicKey}
 }
 
 // Facts are data which we *know* to be true
 // Facts MUST be sourced from the user's cloud infrastructure
 // we can't rely on Facts provided by any client (user, admin, nor Common Fate)
 type Facts struct {
-	Actors Actors    `json:"actors"`
-	Time   time.Time `json:"time"`
+	Actors Actors
+	Time   time.Time
+	// TrustStore, if set, holds the keys which actors sign with over time.
+	// Signatures are checked against the keys which were valid at the time
+	// attested to by the bundle, rather than the keys in Actors.
+	TrustStore *TrustStore
+	// RevokedKeys, if set, lists user keys which have been revoked.
+	// Bundles initiated with a revoked key are rejected at every stage.
+	RevokedKeys RevocationList
+}
+
+// TrustedIdentityServers returns each of the trusted identity servers which has at least one
+// key, either in the Actors or in the trust store, or which has a certificate authority.
+func (f Facts) TrustedIdentityServers() []IdentityServer {
+	return f.Actors.trustedIdentityServers(f.TrustStore)
+}
+
+// FactsVersion is the version of the serialised Facts format. Serialised Facts without
+// a version were written before the format was versioned, and are loaded as before.
+const FactsVersion = "granted.dev/Facts/v0.2"
+
+// KeyFormat is the format which public keys are serialised in.
+type KeyFormat string
+
+const (
+	// KeyFormatJWK serialises keys as JSON Web Keys, with their thumbprint as the key ID
+	KeyFormatJWK KeyFormat = "jwk"
+	// KeyFormatPEM serialises keys as PEM-encoded PKIX public keys
+	KeyFormatPEM KeyFormat = "pem"
+)
+
+// SerialisedPublicKey is a public key which is serialised as a JWK object or a PEM string.
+// Base64 DER strings, which keys were serialised as before PEM, are also accepted when loading.
+// Keys are validated when they're loaded, so keys on unsupported curves are rejected.
+type SerialisedPublicKey struct {
+	PublicKey PublicKey
+	Format    KeyFormat
+}
+
+func serialisePublicKey(key PublicKey, format KeyFormat) *SerialisedPublicKey {
+	if key == nil {
+		return nil
+	}
+	return &SerialisedPublicKey{PublicKey: key, Format: format}
+}
+
+func (k SerialisedPublicKey) MarshalJSON() ([]byte, error) {
+	switch k.Format {
+	case KeyFormatJWK, "":
+		return MarshalJWK(k.PublicKey)
+	case KeyFormatPEM:
+		data, err := MarshalPublicKeyPEM(k.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(string(data))
+	default:
+		return nil, fmt.Errorf("unsupported key format %s", k.Format)
+	}
+}
+
+func (k *SerialisedPublicKey) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		key, err := ParseJWK(data)
+		if err != nil {
+			return err
+		}
+		*k = SerialisedPublicKey{PublicKey: key, Format: KeyFormatJWK}
+		return nil
+	}
+
+	var encoded string
+	err := json.Unmarshal(data, &encoded)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(strings.TrimSpace(encoded), "-----BEGIN") {
+		key, err := ParsePublicKeyPEM([]byte(encoded))
+		if err != nil {
+			return err
+		}
+		*k = SerialisedPublicKey{PublicKey: key, Format: KeyFormatPEM}
+		return nil
+	}
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("public key must be a JWK, PEM or base64 DER: %w", err)
+	}
+	key, err := ParsePublicKey(der)
+	if err != nil {
+		return err
+	}
+	*k = SerialisedPublicKey{PublicKey: key}
+	return nil
+}
+
+// key returns the public key, or nil if the serialised key is nil.
+func (k *SerialisedPublicKey) key() PublicKey {
+	if k == nil {
+		return nil
+	}
+	return k.PublicKey
 }
 
+// SerialisedFacts is the portable JSON form of Facts, which is used to pass Facts between services.
+// Keys are serialised as JWKs or PEM, and times in RFC 3339 form.
 type SerialisedFacts struct {
-	Actors SerialisedActors
-	Time   time.Time `json:"time"`
+	Version string           `json:"version"`
+	Actors  SerialisedActors `json:"actors"`
+	Time    time.Time        `json:"time"`
+	// TrustStore holds each actor's trusted keys
+	TrustStore map[string][]SerialisedTrustedKey `json:"trustStore,omitempty"`
+	// RevokedKeys are the revocations in the revocation list at the time the Facts were serialised.
+	// They aren't signed, even if they were loaded from a signed FileRevocationList.
+	RevokedKeys []KeyRevocation `json:"revokedKeys,omitempty"`
 }
 
 type SerialisedActors struct {
-	User           SerialisedUser
-	IdentityServer SerialisedIdentityServer
+	User            SerialisedUser             `json:"user"`
+	IdentityServer  SerialisedIdentityServer   `json:"identityServer"`
+	IdentityServers []SerialisedIdentityServer `json:"identityServers,omitempty"`
+	Approvers       []SerialisedApprover       `json:"approvers"`
+	ApproverGroups  []ApproverGroup            `json:"approverGroups"`
 }
 
 type SerialisedUser struct {
-	ID        string
-	PublicKey []byte
+	ID        string               `json:"id"`
+	PublicKey *SerialisedPublicKey `json:"publicKey,omitempty"`
 }
 
 type SerialisedIdentityServer struct {
-	PublicKey []byte
+	ID                   string                          `json:"id,omitempty"`
+	Issuer               string                          `json:"issuer,omitempty"`
+	PublicKey            *SerialisedPublicKey            `json:"publicKey,omitempty"`
+	PublicKeys           []SerialisedPublicKey           `json:"publicKeys,omitempty"`
+	CertificateAuthority *SerialisedCertificateAuthority `json:"certificateAuthority,omitempty"`
+}
+
+// SerialisedCertificateAuthority holds a certificate authority's root certificates in PEM form,
+// and its extended key usages by their RFC 5280 names.
+type SerialisedCertificateAuthority struct {
+	Roots               []string `json:"roots"`
+	ExtKeyUsages        []string `json:"extKeyUsages,omitempty"`
+	PermittedDNSDomains []string `json:"permittedDnsDomains,omitempty"`
+}
+
+type SerialisedApprover struct {
+	ID        string               `json:"id"`
+	PublicKey *SerialisedPublicKey `json:"publicKey"`
+}
+
+type SerialisedTrustedKey struct {
+	PublicKey SerialisedPublicKey `json:"publicKey"`
+	NotBefore time.Time           `json:"notBefore"`
+	NotAfter  time.Time           `json:"notAfter"`
+	Revoked   bool                `json:"revoked,omitempty"`
+}
+
+func serialiseIdentityServer(i IdentityServer, format KeyFormat) (SerialisedIdentityServer, error) {
+	si := SerialisedIdentityServer{
+		ID:        i.ID,
+		Issuer:    i.Issuer,
+		PublicKey: serialisePublicKey(i.PublicKey, format),
+	}
+	for _, key := range i.PublicKeys {
+		si.PublicKeys = append(si.PublicKeys, *serialisePublicKey(key, format))
+	}
+	if ca := i.CertificateAuthority; ca != nil {
+		sca := SerialisedCertificateAuthority{
+			Roots:               []string{},
+			PermittedDNSDomains: ca.PermittedDNSDomains,
+		}
+		for _, cert := range ca.Roots {
+			sca.Roots = append(sca.Roots, string(MarshalCertificatePEM(cert)))
+		}
+		for _, u := range ca.ExtKeyUsages {
+			name, ok := extKeyUsageNames[u]
+			if !ok {
+				return SerialisedIdentityServer{}, fmt.Errorf("extended key usage %d of %s can't be serialised", u, i.signerName())
+			}
+			sca.ExtKeyUsages = append(sca.ExtKeyUsages, name)
+		}
+		si.CertificateAuthority = &sca
+	}
+	return si, nil
+}
+
+func (si SerialisedIdentityServer) deserialise() (IdentityServer, error) {
+	i := IdentityServer{
+		ID:        si.ID,
+		Issuer:    si.Issuer,
+		PublicKey: si.PublicKey.key(),
+	}
+	for _, key := range si.PublicKeys {
+		i.PublicKeys = append(i.PublicKeys, key.PublicKey)
+	}
+	if sca := si.CertificateAuthority; sca != nil {
+		ca := CertificateAuthority{PermittedDNSDomains: sca.PermittedDNSDomains}
+		for _, root := range sca.Roots {
+			certs, err := ParseCertificatesPEM([]byte(root))
+			if err != nil {
+				return IdentityServer{}, fmt.Errorf("loading root certificates for %s: %w", i.signerName(), err)
+			}
+			ca.Roots = append(ca.Roots, certs...)
+		}
+		for _, name := range sca.ExtKeyUsages {
+			u, err := ParseExtKeyUsage(name)
+			if err != nil {
+				return IdentityServer{}, fmt.Errorf("loading certificate authority for %s: %w", i.signerName(), err)
+			}
+			ca.ExtKeyUsages = append(ca.ExtKeyUsages, u)
+		}
+		i.CertificateAuthority = &ca
+	}
+	return i, nil
+}
+
+// Revocations is implemented by revocation lists which can list their revocations,
+// so that they can be serialised along with the Facts.
+type Revocations interface {
+	Revocations() []KeyRevocation
 }
 
+// Serialise returns the Facts in their portable form, with keys serialised as JWKs.
+// Revoked keys are serialised as an unsigned list, as described in SerialiseWithKeyFormat.
 func (f *Facts) Serialise() (*SerialisedFacts, error) {
-	userBytes, err := cfcrypto.MarshalECDSAPublicKey(&f.Actors.User.PublicKey)
-	if err != nil {
-		return nil, err
+	return f.SerialiseWithKeyFormat(KeyFormatJWK)
+}
+
+// SerialiseWithKeyFormat returns the Facts in their portable form, with keys serialised in the format.
+// If the Facts have revoked keys, the revocation list must be able to list its revocations.
+//
+// Revocations are flattened into an unsigned list. If they were loaded from a FileRevocationList,
+// the file's signature, issuer and path aren't serialised, so the deserialised Facts hold a
+// MemoryRevocationList which can't be checked against the issuer or reloaded. Only load serialised
+// Facts from a source which is trusted as much as the revocation list's issuer.
+func (f *Facts) SerialiseWithKeyFormat(format KeyFormat) (*SerialisedFacts, error) {
+	if format != KeyFormatJWK && format != KeyFormatPEM {
+		return nil, fmt.Errorf("unsupported key format %s", format)
 	}
-	idBytes, err := cfcrypto.MarshalECDSAPublicKey(&f.Actors.IdentityServer.PublicKey)
+
+	identityServer, err := serialiseIdentityServer(f.Actors.IdentityServer, format)
 	if err != nil {
 		return nil, err
 	}
 
+	identityServers := []SerialisedIdentityServer{}
+	for _, i := range f.Actors.IdentityServers {
+		si, err := serialiseIdentityServer(i, format)
+		if err != nil {
+			return nil, err
+		}
+		identityServers = append(identityServers, si)
+	}
+
+	approvers := []SerialisedApprover{}
+	for _, a := range f.Actors.Approvers {
+		approvers = append(approvers, SerialisedApprover{
+			ID:        a.ID,
+			PublicKey: serialisePublicKey(a.PublicKey, format),
+		})
+	}
+
 	sf := SerialisedFacts{
+		Version: FactsVersion,
 		Actors: SerialisedActors{
 			User: SerialisedUser{
 				ID:        f.Actors.User.ID,
-				PublicKey: userBytes,
-			},
-			IdentityServer: SerialisedIdentityServer{
-				PublicKey: idBytes,
+				PublicKey: serialisePublicKey(f.Actors.User.PublicKey, format),
 			},
+			IdentityServer:  identityServer,
+			IdentityServers: identityServers,
+			Approvers:       approvers,
+			ApproverGroups:  f.Actors.ApproverGroups,
 		},
+		Time: f.Time,
+	}
+
+	if f.TrustStore != nil {
+		sf.TrustStore = map[string][]SerialisedTrustedKey{}
+		for actor, keys := range f.TrustStore.Keys {
+			for _, k := range keys {
+				sf.TrustStore[actor] = append(sf.TrustStore[actor], SerialisedTrustedKey{
+					PublicKey: *serialisePublicKey(k.PublicKey, format),
+					NotBefore: k.NotBefore,
+					NotAfter:  k.NotAfter,
+					Revoked:   k.Revoked,
+				})
+			}
+		}
+	}
+
+	if f.RevokedKeys != nil {
+		l, ok := f.RevokedKeys.(Revocations)
+		if !ok {
+			return nil, fmt.Errorf("revoked keys can't be serialised, because the revocation list %T can't list its revocations", f.RevokedKeys)
+		}
+		sf.RevokedKeys = l.Revocations()
+		sort.Slice(sf.RevokedKeys, func(i, j int) bool {
+			return sf.RevokedKeys[i].KeyID < sf.RevokedKeys[j].KeyID
+		})
 	}
+
 	return &sf, nil
 }
 
+// Deserialise returns the Facts from their portable form.
 func (sf *SerialisedFacts) Deserialise() (*Facts, error) {
-	userKey, err := cfcrypto.ParseECDSAPublicKey(sf.Actors.User.PublicKey)
-	if err != nil {
-		return nil, err
+	if sf.Version != "" && sf.Version != FactsVersion {
+		return nil, fmt.Errorf("unsupported facts version %s, expected %s", sf.Version, FactsVersion)
 	}
-	idKey, err := cfcrypto.ParseECDSAPublicKey(sf.Actors.IdentityServer.PublicKey)
+
+	identityServer, err := sf.Actors.IdentityServer.deserialise()
 	if err != nil {
 		return nil, err
 	}
 
+	identityServers := []IdentityServer{}
+	for _, si := range sf.Actors.IdentityServers {
+		i, err := si.deserialise()
+		if err != nil {
+			return nil, err
+		}
+		identityServers = append(identityServers, i)
+	}
+
+	approvers := []Approver{}
+	for _, a := range sf.Actors.Approvers {
+		approvers = append(approvers, Approver{
+			ID:        a.ID,
+			PublicKey: a.PublicKey.key(),
+		})
+	}
+
 	f := Facts{
 		Actors: Actors{
 			User: User{
 				ID:        sf.Actors.User.ID,
-				PublicKey: *userKey,
-			},
-			IdentityServer: IdentityServer{
-				PublicKey: *idKey,
+				PublicKey: sf.Actors.User.PublicKey.key(),
 			},
+			IdentityServer:  identityServer,
+			IdentityServers: identityServers,
+			Approvers:       approvers,
+			ApproverGroups:  sf.Actors.ApproverGroups,
 		},
+		Time: sf.Time,
+	}
+
+	if sf.TrustStore != nil {
+		f.TrustStore = NewTrustStore()
+		for actor, keys := range sf.TrustStore {
+			for _, k := range keys {
+				f.TrustStore.Add(actor, TrustedKey{
+					PublicKey: k.PublicKey.PublicKey,
+					NotBefore: k.NotBefore,
+					NotAfter:  k.NotAfter,
+					Revoked:   k.Revoked,
+				})
+			}
+		}
+	}
+
+	if len(sf.RevokedKeys) > 0 {
+		f.RevokedKeys = NewMemoryRevocationList(sf.RevokedKeys...)
 	}
 
 	return &f, nil
 }
+
+// MarshalJSON encodes the Facts in their portable form, with keys serialised as JWKs.
+func (f Facts) MarshalJSON() ([]byte, error) {
+	sf, err := f.Serialise()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sf)
+}
+
+// UnmarshalJSON decodes Facts from their portable form.
+func (f *Facts) UnmarshalJSON(data []byte) error {
+	var sf SerialisedFacts
+	err := json.Unmarshal(data, &sf)
+	if err != nil {
+		return err
+	}
+	facts, err := sf.Deserialise()
+	if err != nil {
+		return err
+	}
+	*f = *facts
+	return nil
+}
@@ -0,0 +1,272 @@
+package schema
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/internal/certtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustGeneratePublicKey(t *testing.T, alg Algorithm) PublicKey {
+	priv, err := GenerateKey(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := NewPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func TestTrustedIdentityServers(t *testing.T) {
+	primary := mustGeneratePublicKey(t, AlgorithmES256)
+	rotated := mustGeneratePublicKey(t, AlgorithmES256)
+	eu := mustGeneratePublicKey(t, AlgorithmEdDSA)
+
+	a := Actors{
+		IdentityServer: IdentityServer{PublicKey: primary, PublicKeys: []PublicKey{rotated}},
+		IdentityServers: []IdentityServer{
+			{ID: "eu", Issuer: "https://eu.idp.example.com", PublicKey: eu},
+			// servers without keys can't vouch for anyone, so aren't trusted
+			{ID: "decommissioned"},
+		},
+	}
+
+	servers := a.TrustedIdentityServers()
+	assert.Len(t, servers, 2)
+	assert.Equal(t, []ExpectedSigner{
+		{Name: ActorIdentityServer, PublicKey: primary},
+		{Name: ActorIdentityServer, PublicKey: rotated},
+	}, servers[0].Signers())
+	assert.Equal(t, []ExpectedSigner{{Name: "identityServer:eu", PublicKey: eu}}, servers[1].Signers())
+
+	_, ok := a.TrustedIdentityServer("decommissioned")
+	assert.False(t, ok)
+}
+
+func TestSerialiseIdentityServers(t *testing.T) {
+	f := Facts{
+		Actors: Actors{
+			User: User{ID: "alice", PublicKey: mustGeneratePublicKey(t, AlgorithmES256)},
+			IdentityServers: []IdentityServer{
+				{ID: "us", PublicKey: mustGeneratePublicKey(t, AlgorithmES256)},
+				{
+					ID:         "eu",
+					Issuer:     "https://eu.idp.example.com",
+					PublicKey:  mustGeneratePublicKey(t, AlgorithmES384),
+					PublicKeys: []PublicKey{mustGeneratePublicKey(t, AlgorithmEdDSA)},
+				},
+			},
+		},
+	}
+
+	sf, err := f.Serialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := sf.Deserialise()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, IdentityServer{}, got.Actors.IdentityServer)
+	assert.Len(t, got.Actors.IdentityServers, 2)
+	for i, want := range f.Actors.IdentityServers {
+		server := got.Actors.IdentityServers[i]
+		assert.Equal(t, want.ID, server.ID)
+		assert.Equal(t, want.Issuer, server.Issuer)
+		assert.Len(t, server.Keys(), len(want.Keys()))
+		for j, key := range want.Keys() {
+			assert.True(t, PublicKeysEqual(key, server.Keys()[j]))
+		}
+	}
+}
+
+func TestFactsJSONRoundTrip(t *testing.T) {
+	root := certtest.Issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	user := mustGeneratePublicKey(t, AlgorithmES256)
+	rotated := mustGeneratePublicKey(t, AlgorithmEdDSA)
+	revokedAt := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	userKeyID, err := KeyID(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewTrustStore()
+	store.Add(ActorUser, TrustedKey{PublicKey: user, NotAfter: revokedAt})
+	store.Add(ActorUser, TrustedKey{PublicKey: rotated, NotBefore: revokedAt})
+
+	f := Facts{
+		Actors: Actors{
+			User: User{ID: "alice", PublicKey: user},
+			IdentityServer: IdentityServer{
+				Issuer:    "https://idp.example.com",
+				PublicKey: mustGeneratePublicKey(t, AlgorithmES384),
+				CertificateAuthority: &CertificateAuthority{
+					Roots:               []*x509.Certificate{root.Cert},
+					ExtKeyUsages:        []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning, x509.ExtKeyUsageClientAuth},
+					PermittedDNSDomains: []string{"idp.example.com"},
+				},
+			},
+			IdentityServers: []IdentityServer{
+				{ID: "eu", PublicKey: mustGeneratePublicKey(t, AlgorithmPS256)},
+			},
+			Approvers: []Approver{
+				{ID: "bob", PublicKey: mustGeneratePublicKey(t, AlgorithmEdDSA)},
+				{ID: "carol", PublicKey: mustGeneratePublicKey(t, AlgorithmES256)},
+			},
+			ApproverGroups: []ApproverGroup{
+				{Name: "security", ApproverIDs: []string{"bob", "carol"}, Threshold: 2},
+			},
+		},
+		Time:        time.Date(2022, 6, 2, 15, 4, 5, 123456789, time.FixedZone("AEST", 10*60*60)),
+		TrustStore:  store,
+		RevokedKeys: NewMemoryRevocationList(KeyRevocation{KeyID: userKeyID, RevokedAt: revokedAt, Reason: "device lost"}),
+	}
+
+	for _, format := range []KeyFormat{KeyFormatJWK, KeyFormatPEM} {
+		t.Run(string(format), func(t *testing.T) {
+			sf, err := f.SerialiseWithKeyFormat(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.Marshal(sf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var loaded SerialisedFacts
+			err = json.Unmarshal(data, &loaded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, FactsVersion, loaded.Version)
+			got, err := loaded.Deserialise()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.True(t, f.Time.Equal(got.Time), "time %s was loaded as %s", f.Time, got.Time)
+			assert.True(t, PublicKeysEqual(user, got.Actors.User.PublicKey))
+			assert.Equal(t, f.Actors.IdentityServer.CertificateAuthority, got.Actors.IdentityServer.CertificateAuthority)
+			assert.Equal(t, []PublicKey{rotated}, got.TrustStore.KeysAt(ActorUser, revokedAt.Add(time.Hour)))
+			assert.ErrorAs(t, CheckRevocation(got.RevokedKeys, user), new(*ErrKeyRevoked))
+
+			// serialising the loaded facts gives the same JSON, so nothing was lost
+			again, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := json.Marshal(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.JSONEq(t, string(want), string(again))
+		})
+	}
+}
+
+func TestFactsJSONTime(t *testing.T) {
+	f := Facts{Time: time.Date(2022, 6, 2, 15, 4, 5, 0, time.UTC)}
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "2022-06-02T15:04:05Z", fields["time"])
+}
+
+func TestDeserialiseUnversionedFacts(t *testing.T) {
+	user := mustGeneratePublicKey(t, AlgorithmES256)
+	approver := mustGeneratePublicKey(t, AlgorithmEdDSA)
+	encode := func(key PublicKey) string {
+		der, err := MarshalPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(der)
+	}
+
+	// facts serialised before the format was versioned have base64 DER keys and untagged fields
+	data := fmt.Sprintf(`{
+		"Actors": {
+			"User": {"ID": "alice", "PublicKey": %q},
+			"IdentityServer": {"ID": "", "Issuer": "", "PublicKey": null, "PublicKeys": null},
+			"IdentityServers": [],
+			"Approvers": [{"ID": "bob", "PublicKey": %q}],
+			"ApproverGroups": [{"Name": "security", "ApproverIDs": ["bob"], "Threshold": 1}]
+		},
+		"time": "0001-01-01T00:00:00Z"
+	}`, encode(user), encode(approver))
+
+	var f Facts
+	err := json.Unmarshal([]byte(data), &f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "alice", f.Actors.User.ID)
+	assert.True(t, PublicKeysEqual(user, f.Actors.User.PublicKey))
+	assert.True(t, PublicKeysEqual(approver, f.Actors.Approvers[0].PublicKey))
+	assert.Equal(t, []ApproverGroup{{Name: "security", ApproverIDs: []string{"bob"}, Threshold: 1}}, f.Actors.ApproverGroups)
+	assert.Equal(t, IdentityServer{}, f.Actors.IdentityServer)
+}
+
+func TestDeserialiseFactsRejectsUnsupportedKeys(t *testing.T) {
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&p224.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey, err := json.Marshal(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		key    string
+		target interface{}
+	}{
+		"PEM key on unsupported curve": {key: string(pemKey), target: new(*ErrUnsupportedAlgorithm)},
+		"JWK on unsupported curve":     {key: `{"kty": "EC", "crv": "P-224", "x": "AAAA", "y": "AAAA"}`, target: new(*ErrUnsupportedAlgorithm)},
+		// the point (1, 1) isn't on the P-256 curve
+		"JWK point not on curve": {
+			key:    `{"kty": "EC", "crv": "P-256", "x": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAE", "y": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAE"}`,
+			target: new(*ErrInvalidJWK),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := fmt.Sprintf(`{"version": %q, "actors": {"user": {"id": "alice", "publicKey": %s}}}`, FactsVersion, tc.key)
+			var f Facts
+			err := json.Unmarshal([]byte(data), &f)
+			assert.ErrorAs(t, err, tc.target)
+		})
+	}
+
+	var f Facts
+	err = json.Unmarshal([]byte(`{"version": "granted.dev/Facts/v9"}`), &f)
+	assert.EqualError(t, err, "unsupported facts version granted.dev/Facts/v9, expected "+FactsVersion)
+}
@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultClockSkew is the clock skew allowed by a freshness policy which doesn't set one.
+// It is also how far in the future an authentication may be dated, relative to Facts.Time,
+// when verifying it without a freshness policy.
+const DefaultClockSkew = 5 * time.Minute
+
+// FreshnessPolicy limits how long ago an authentication may have happened.
+type FreshnessPolicy struct {
+	// MaxAge is the maximum time since the user authenticated
+	MaxAge time.Duration `json:"maxAge" yaml:"maxAge"`
+	// ClockSkew is the tolerance for differences between the identity
+	// server's clock and the verifier's clock. If zero, DefaultClockSkew is used.
+	ClockSkew time.Duration `json:"clockSkew" yaml:"clockSkew"`
+}
+
+// clockSkew returns the policy's clock skew, or DefaultClockSkew if it isn't set.
+func (p FreshnessPolicy) clockSkew() time.Duration {
+	if p.ClockSkew == 0 {
+		return DefaultClockSkew
+	}
+	return p.ClockSkew
+}
+
+// ErrStaleAuthentication is returned when an authentication is older than the maximum age.
+type ErrStaleAuthentication struct {
+	AuthenticatedAt time.Time
+	// Age is how long before the verification time the user authenticated
+	Age    time.Duration
+	MaxAge time.Duration
+}
+
+func (e *ErrStaleAuthentication) Error() string {
+	return fmt.Sprintf("authentication is stale: user authenticated %s ago at %s, which exceeds the maximum age of %s by %s",
+		e.Age, e.AuthenticatedAt.Format(time.RFC3339), e.MaxAge, e.Age-e.MaxAge)
+}
+
+// ErrFutureAuthentication is returned when an authentication is dated in the future,
+// beyond the allowed clock skew.
+type ErrFutureAuthentication struct {
+	AuthenticatedAt time.Time
+	Now             time.Time
+	ClockSkew       time.Duration
+}
+
+func (e *ErrFutureAuthentication) Error() string {
+	return fmt.Sprintf("authentication at %s is %s in the future, which exceeds the allowed clock skew of %s",
+		e.AuthenticatedAt.Format(time.RFC3339), e.AuthenticatedAt.Sub(e.Now), e.ClockSkew)
+}
+
+// AuthenticatedAt returns the time that the user authenticated.
+func (m *AuthenticatedPayload) AuthenticatedAt() time.Time {
+	return time.Unix(0, m.Time)
+}
+
+// ValidateNotFuture checks that the authentication isn't dated more than the clock skew after now.
+// Authentications can never be dated in the future, regardless of their age.
+func (m *AuthenticatedPayload) ValidateNotFuture(now time.Time, clockSkew time.Duration) error {
+	authenticatedAt := m.AuthenticatedAt()
+	if authenticatedAt.Sub(now) > clockSkew {
+		return &ErrFutureAuthentication{
+			AuthenticatedAt: authenticatedAt,
+			Now:             now,
+			ClockSkew:       clockSkew,
+		}
+	}
+	return nil
+}
+
+// ValidateFreshness checks that the authentication happened no longer than the
+// policy's maximum age before now, and isn't dated in the future. Both checks
+// allow for the policy's clock skew.
+func (m *AuthenticatedPayload) ValidateFreshness(now time.Time, p FreshnessPolicy) error {
+	err := m.ValidateNotFuture(now, p.clockSkew())
+	if err != nil {
+		return err
+	}
+
+	authenticatedAt := m.AuthenticatedAt()
+	age := now.Sub(authenticatedAt)
+	if age > p.MaxAge+p.clockSkew() {
+		return &ErrStaleAuthentication{
+			AuthenticatedAt: authenticatedAt,
+			Age:             age,
+			MaxAge:          p.MaxAge,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFreshness(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := FreshnessPolicy{MaxAge: time.Hour, ClockSkew: time.Minute}
+
+	fresh := NewAuthenticatedMessage(AuthMessageOpts{Time: now.Add(-30 * time.Minute)})
+	assert.NoError(t, fresh.ValidateFreshness(now, policy))
+
+	stale := NewAuthenticatedMessage(AuthMessageOpts{Time: now.Add(-3 * time.Hour)})
+	err := stale.ValidateFreshness(now, policy)
+	staleErr := &ErrStaleAuthentication{}
+	assert.ErrorAs(t, err, &staleErr)
+	assert.Equal(t, 3*time.Hour, staleErr.Age)
+	assert.EqualError(t, err, "authentication is stale: user authenticated 3h0m0s ago at 2022-01-01T09:00:00Z, which exceeds the maximum age of 1h0m0s by 2h0m0s")
+
+	future := NewAuthenticatedMessage(AuthMessageOpts{Time: now.Add(10 * time.Minute)})
+	err = future.ValidateFreshness(now, policy)
+	futureErr := &ErrFutureAuthentication{}
+	assert.ErrorAs(t, err, &futureErr)
+
+	// within the clock skew tolerance
+	skewed := NewAuthenticatedMessage(AuthMessageOpts{Time: now.Add(30 * time.Second)})
+	assert.NoError(t, skewed.ValidateFreshness(now, policy))
+}
+
+func TestValidateFreshnessClockSkew(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := NewAuthenticatedMessage(AuthMessageOpts{Time: now.Add(7 * time.Minute)})
+
+	// the configured clock skew is used, rather than the default
+	assert.NoError(t, m.ValidateFreshness(now, FreshnessPolicy{MaxAge: time.Hour, ClockSkew: 10 * time.Minute}))
+
+	// policies without a clock skew use the default
+	err := m.ValidateFreshness(now, FreshnessPolicy{MaxAge: time.Hour})
+	futureErr := &ErrFutureAuthentication{}
+	assert.ErrorAs(t, err, &futureErr)
+	assert.Equal(t, DefaultClockSkew, futureErr.ClockSkew)
+}
+
+func TestValidateNotFuture(t *testing.T) {
+	now := time.Now()
+	m := NewAuthenticatedMessage(AuthMessageOpts{UserID: "alice", Time: now.Add(time.Hour)})
+	err := m.ValidateNotFuture(now, DefaultClockSkew)
+	futureErr := &ErrFutureAuthentication{}
+	assert.ErrorAs(t, err, &futureErr)
+
+	assert.NoError(t, m.ValidateNotFuture(now, 2*time.Hour))
+}
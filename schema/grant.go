@@ -0,0 +1,244 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GrantType identifies the provider which access was granted through.
+type GrantType string
+
+const (
+	GrantTypeAWS        GrantType = "aws"
+	GrantTypeGCP        GrantType = "gcp"
+	GrantTypeAzure      GrantType = "azure"
+	GrantTypeOkta       GrantType = "okta"
+	GrantTypeKubernetes GrantType = "kubernetes"
+)
+
+// Grant is the access which was granted to a user. It is a discriminated union:
+// Type selects the provider, and exactly the matching provider details must be set.
+type Grant struct {
+	Type GrantType `json:"type"`
+	// StartsAt is when access begins. If it isn't set, access is
+	// treated as beginning when the user authenticated.
+	StartsAt  time.Time `json:"startsAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// CreatedAt is when the grant was created. The signature on the grant
+	// is verified using the keys which were valid at this time.
+	CreatedAt time.Time `json:"createdAt"`
+
+	AWS        *AWSGrant        `json:"aws,omitempty"`
+	GCP        *GCPGrant        `json:"gcp,omitempty"`
+	Azure      *AzureGrant      `json:"azure,omitempty"`
+	Okta       *OktaGrant       `json:"okta,omitempty"`
+	Kubernetes *KubernetesGrant `json:"kubernetes,omitempty"`
+}
+
+// GrantDetails are the provider-specific details of a grant.
+type GrantDetails interface {
+	// GrantType is the type of grant which the details belong to
+	GrantType() GrantType
+	// GrantedRole is the role or group which was granted. It must match
+	// the role which was requested in the access request.
+	GrantedRole() string
+	// GrantedTarget is the account, project, scope or cluster which access
+	// was granted to, or empty if the provider doesn't have targets.
+	GrantedTarget() string
+	// Validate checks that the details are well formed
+	Validate() error
+}
+
+// Details returns the provider details which are set on the grant,
+// or nil if none are set.
+func (g Grant) Details() GrantDetails {
+	details := g.setDetails()
+	if len(details) != 1 {
+		return nil
+	}
+	return details[0]
+}
+
+func (g Grant) setDetails() []GrantDetails {
+	var details []GrantDetails
+	if g.AWS != nil {
+		details = append(details, g.AWS)
+	}
+	if g.GCP != nil {
+		details = append(details, g.GCP)
+	}
+	if g.Azure != nil {
+		details = append(details, g.Azure)
+	}
+	if g.Okta != nil {
+		details = append(details, g.Okta)
+	}
+	if g.Kubernetes != nil {
+		details = append(details, g.Kubernetes)
+	}
+	return details
+}
+
+// Validate checks that exactly one set of provider details is set,
+// that it matches the grant type, and that the details are valid.
+func (g Grant) Validate() error {
+	details := g.setDetails()
+	if len(details) != 1 {
+		return fmt.Errorf("grant must have exactly one set of provider details, but has %d", len(details))
+	}
+	d := details[0]
+	if d.GrantType() != g.Type {
+		return fmt.Errorf("grant type %s does not match %s provider details", g.Type, d.GrantType())
+	}
+	if !g.StartsAt.IsZero() && !g.StartsAt.Before(g.ExpiresAt) {
+		return fmt.Errorf("grant starts at %s, which is not before it expires at %s", g.StartsAt, g.ExpiresAt)
+	}
+	return d.Validate()
+}
+
+// AWSGrant is an IAM role which the user may assume.
+type AWSGrant struct {
+	RoleARN string `json:"roleArn"`
+}
+
+func (g *AWSGrant) GrantType() GrantType { return GrantTypeAWS }
+func (g *AWSGrant) GrantedRole() string  { return g.RoleARN }
+
+// GrantedTarget returns the ID of the AWS account which contains the role.
+func (g *AWSGrant) GrantedTarget() string {
+	parts := strings.SplitN(g.RoleARN, ":", 6)
+	if len(parts) != 6 {
+		return ""
+	}
+	return parts[4]
+}
+
+// Validate checks that the role ARN is an IAM role ARN,
+// such as arn:aws:iam::123456789012:role/example.
+func (g *AWSGrant) Validate() error {
+	parts := strings.SplitN(g.RoleARN, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[1] == "" || parts[2] != "iam" || parts[4] == "" || !strings.HasPrefix(parts[5], "role/") {
+		return fmt.Errorf("invalid AWS IAM role ARN %q", g.RoleARN)
+	}
+	return nil
+}
+
+// GCPGrant is an IAM role binding on a Google Cloud project.
+type GCPGrant struct {
+	Project string `json:"project"`
+	// Role is a predefined or custom role, such as roles/viewer
+	Role string `json:"role"`
+	// Member is the principal which the role is bound to, such as user:alice@example.com
+	Member string `json:"member"`
+}
+
+func (g *GCPGrant) GrantType() GrantType  { return GrantTypeGCP }
+func (g *GCPGrant) GrantedRole() string   { return g.Role }
+func (g *GCPGrant) GrantedTarget() string { return g.Project }
+
+func (g *GCPGrant) Validate() error {
+	if g.Project == "" {
+		return errors.New("GCP grant did not specify a project")
+	}
+	if !strings.HasPrefix(g.Role, "roles/") && !strings.HasPrefix(g.Role, "projects/") && !strings.HasPrefix(g.Role, "organizations/") {
+		return fmt.Errorf("invalid GCP IAM role %q", g.Role)
+	}
+	if !strings.Contains(g.Member, ":") {
+		return fmt.Errorf("invalid GCP IAM member %q", g.Member)
+	}
+	return nil
+}
+
+// AzureGrant is an Azure RBAC role assignment.
+type AzureGrant struct {
+	// Scope is the resource which the role is assigned at,
+	// such as /subscriptions/<id>/resourceGroups/<name>
+	Scope            string `json:"scope"`
+	RoleDefinitionID string `json:"roleDefinitionId"`
+	PrincipalID      string `json:"principalId"`
+}
+
+func (g *AzureGrant) GrantType() GrantType  { return GrantTypeAzure }
+func (g *AzureGrant) GrantedRole() string   { return g.RoleDefinitionID }
+func (g *AzureGrant) GrantedTarget() string { return g.Scope }
+
+func (g *AzureGrant) Validate() error {
+	if !strings.HasPrefix(g.Scope, "/") {
+		return fmt.Errorf("invalid Azure scope %q", g.Scope)
+	}
+	if g.RoleDefinitionID == "" {
+		return errors.New("Azure grant did not specify a role definition ID")
+	}
+	if g.PrincipalID == "" {
+		return errors.New("Azure grant did not specify a principal ID")
+	}
+	return nil
+}
+
+// OktaGrant is membership of an Okta group.
+type OktaGrant struct {
+	GroupID string `json:"groupId"`
+	UserID  string `json:"userId"`
+}
+
+func (g *OktaGrant) GrantType() GrantType  { return GrantTypeOkta }
+func (g *OktaGrant) GrantedRole() string   { return g.GroupID }
+func (g *OktaGrant) GrantedTarget() string { return "" }
+
+func (g *OktaGrant) Validate() error {
+	if g.GroupID == "" {
+		return errors.New("Okta grant did not specify a group ID")
+	}
+	if g.UserID == "" {
+		return errors.New("Okta grant did not specify a user ID")
+	}
+	return nil
+}
+
+// KubernetesRoleKind is the kind of role referenced by a Kubernetes RoleBinding.
+type KubernetesRoleKind string
+
+const (
+	KubernetesRole        KubernetesRoleKind = "Role"
+	KubernetesClusterRole KubernetesRoleKind = "ClusterRole"
+)
+
+// KubernetesGrant is a Kubernetes RoleBinding, or a ClusterRoleBinding
+// if no namespace is specified.
+type KubernetesGrant struct {
+	Cluster string `json:"cluster"`
+	// Namespace is empty for cluster-wide bindings
+	Namespace string             `json:"namespace,omitempty"`
+	RoleKind  KubernetesRoleKind `json:"roleKind"`
+	RoleName  string             `json:"roleName"`
+	// Subject is the user or group which the role is bound to
+	Subject string `json:"subject"`
+}
+
+func (g *KubernetesGrant) GrantType() GrantType  { return GrantTypeKubernetes }
+func (g *KubernetesGrant) GrantedRole() string   { return g.RoleName }
+func (g *KubernetesGrant) GrantedTarget() string { return g.Cluster }
+
+func (g *KubernetesGrant) Validate() error {
+	if g.Cluster == "" {
+		return errors.New("Kubernetes grant did not specify a cluster")
+	}
+	switch g.RoleKind {
+	case KubernetesRole:
+		if g.Namespace == "" {
+			return errors.New("Kubernetes grant of a Role must specify a namespace")
+		}
+	case KubernetesClusterRole:
+	default:
+		return fmt.Errorf("invalid Kubernetes role kind %q", g.RoleKind)
+	}
+	if g.RoleName == "" {
+		return errors.New("Kubernetes grant did not specify a role name")
+	}
+	if g.Subject == "" {
+		return errors.New("Kubernetes grant did not specify a subject")
+	}
+	return nil
+}
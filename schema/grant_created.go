@@ -2,23 +2,31 @@ package schema
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
 	"time"
 
 	"github.com/common-fate/attestations/types"
 )
 
-type Grant struct {
+type GrantCreatedPayload struct {
+	Link
+	Grant       Grant         `json:"grant"`
+	PayloadType types.Payload `json:"type"`
+
+	// UpgradedFrom is the payload type which the grant was upgraded from
+	// when it was deserialized, or empty if the grant wasn't upgraded.
+	UpgradedFrom types.Payload `json:"-"`
+}
+
+// grantV01 is the grant contained in v0.1 payloads, which
+// could only describe AWS grants.
+type grantV01 struct {
 	Type       string
+	StartsAt   time.Time
 	ExpiresAt  time.Time
 	AWSRoleARN string
 }
 
-type GrantCreatedPayload struct {
-	Grant       Grant         `json:"decision"`
-	PayloadType types.Payload `json:"type"`
-}
-
 func NewGrantCreatedPayload(g Grant) *GrantCreatedPayload {
 	return &GrantCreatedPayload{
 		Grant:       g,
@@ -34,9 +42,133 @@ func (m *GrantCreatedPayload) MarshalJSON() ([]byte, error) {
 	return json.Marshal(*m)
 }
 
+// UnmarshalJSON unmarshals both v0.1 and v0.2 grant payloads. v0.1 payloads
+// stored the grant under the "decision" key, using the v0.1 grant fields.
+func (m *GrantCreatedPayload) UnmarshalJSON(data []byte) error {
+	type payload GrantCreatedPayload
+	var p payload
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return err
+	}
+
+	if p.PayloadType == types.PayloadGrantCreatedV01 {
+		var v01 struct {
+			Grant grantV01 `json:"decision"`
+		}
+		err = json.Unmarshal(data, &v01)
+		if err != nil {
+			return err
+		}
+		p.Grant = Grant{
+			Type:      GrantType(v01.Grant.Type),
+			StartsAt:  v01.Grant.StartsAt,
+			ExpiresAt: v01.Grant.ExpiresAt,
+			AWS:       &AWSGrant{RoleARN: v01.Grant.AWSRoleARN},
+		}
+	}
+
+	*m = GrantCreatedPayload(p)
+	return nil
+}
+
+// Upgrade upgrades a v0.1 grant to the current version.
+func (m *GrantCreatedPayload) Upgrade() error {
+	if m.PayloadType == types.PayloadGrantCreatedV01 {
+		m.UpgradedFrom = m.PayloadType
+		m.PayloadType = types.PayloadGrantCreated
+	}
+	return nil
+}
+
+// ValidateContents checks that the grant's provider details are valid. Grants upgraded
+// from v0.1 didn't have provider details which could be validated when they were signed,
+// so only their expiry is checked.
+//
+// Whether the grant has expired depends on any extensions which follow it in the bundle,
+// so it is checked using the bundle's GrantState rather than here.
 func (m *GrantCreatedPayload) ValidateContents(f Facts) error {
-	if f.Time.After(m.Grant.ExpiresAt) {
-		return errors.New("grant is expired")
+	if m.UpgradedFrom == types.PayloadGrantCreatedV01 {
+		if m.Grant.ExpiresAt.IsZero() {
+			return &ErrInvalidPayloadContents{Msg: "grant did not specify when it expires"}
+		}
+		if !m.Grant.StartsAt.Before(m.Grant.ExpiresAt) {
+			return &ErrInvalidPayloadContents{
+				Msg: fmt.Sprintf("grant starts at %s, which is not before it expires at %s", m.Grant.StartsAt, m.Grant.ExpiresAt),
+			}
+		}
+		return nil
+	}
+
+	err := m.Grant.Validate()
+	if err != nil {
+		return &ErrInvalidPayloadContents{Msg: err.Error()}
+	}
+
+	return nil
+}
+
+// grantStart returns when access begins for a grant. Grants which don't specify
+// a start time begin when the user authenticated.
+func grantStart(g Grant, previous []Payload) (time.Time, error) {
+	if !g.StartsAt.IsZero() {
+		return g.StartsAt, nil
+	}
+	p, ok := FindPayload(previous, types.PayloadAuthenticated)
+	if !ok {
+		return time.Time{}, &ErrInconsistentBundle{Msg: "grant does not specify a start time and the user did not authenticate"}
+	}
+	return p.(*AuthenticatedPayload).AuthenticatedAt(), nil
+}
+
+// ValidateBundle checks that the grant is for the requested role, target and duration,
+// and that the decision allowed access to be granted.
+func (m *GrantCreatedPayload) ValidateBundle(f Facts, previous []Payload) error {
+	p, ok := FindPayload(previous, types.PayloadAccessRequest)
+	if !ok {
+		return &ErrInconsistentBundle{Msg: "grant was created without an access request"}
+	}
+	req := p.(*AccessRequestPayload).Request
+	details := m.Grant.Details()
+	if details == nil {
+		return &ErrInconsistentBundle{Msg: "grant does not have provider details"}
+	}
+	if details.GrantedRole() != req.Role {
+		return &ErrInconsistentBundle{
+			Msg: fmt.Sprintf("grant role %s does not match requested role %s", details.GrantedRole(), req.Role),
+		}
+	}
+	if req.Target != "" && details.GrantedTarget() != req.Target {
+		return &ErrInconsistentBundle{
+			Msg: fmt.Sprintf("grant target %s does not match requested target %s", details.GrantedTarget(), req.Target),
+		}
+	}
+
+	start, err := grantStart(m.Grant, previous)
+	if err != nil {
+		return err
+	}
+	if d := m.Grant.ExpiresAt.Sub(start); d > req.Duration {
+		return &ErrInconsistentBundle{
+			Msg: fmt.Sprintf("grant duration %s exceeds requested duration %s", d, req.Duration),
+		}
+	}
+
+	p, ok = FindPayload(previous, types.PayloadDecision)
+	if !ok {
+		return &ErrInconsistentBundle{Msg: "grant was created without a decision"}
+	}
+	d := p.(*DecisionPayload).Decision
+	_, approved := FindPayload(previous, types.PayloadApproval)
+
+	switch d.EffectiveOutcome() {
+	case DecisionAllow:
+	case DecisionPendingApproval:
+		if !approved {
+			return &ErrInconsistentBundle{Msg: "grant was created without the required approval"}
+		}
+	default:
+		return &ErrInconsistentBundle{Msg: "grant was created but the decision did not allow access"}
 	}
 
 	return nil
@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRoleARN = "arn:aws:iam::123456789012:role/test-role"
+
+func TestGrantValidateBundle(t *testing.T) {
+	request := NewAccessRequestMessage(AccessRequest{Role: testRoleARN, Duration: time.Hour})
+	now := time.Now()
+	grant := NewGrantCreatedPayload(Grant{
+		Type:      GrantTypeAWS,
+		StartsAt:  now,
+		ExpiresAt: now.Add(time.Hour),
+		AWS:       &AWSGrant{RoleARN: testRoleARN},
+	})
+
+	tests := []struct {
+		name     string
+		previous []Payload
+		wantErr  string
+	}{
+		{
+			name:     "auto allowed",
+			previous: []Payload{request, NewDecisionPayload(Decision{AutoAllow: true})},
+		},
+		{
+			name: "approved",
+			previous: []Payload{
+				request,
+				NewDecisionPayload(Decision{RequireApproval: true}),
+				NewApprovalPayload(Approval{ApproverID: "bob", RequestDigest: "sha256:test"}),
+			},
+		},
+		{
+			name:     "denied",
+			previous: []Payload{request, NewDecisionPayload(Decision{})},
+			wantErr:  "inconsistent bundle: grant was created but the decision did not allow access",
+		},
+		{
+			name:     "not approved",
+			previous: []Payload{request, NewDecisionPayload(Decision{RequireApproval: true})},
+			wantErr:  "inconsistent bundle: grant was created without the required approval",
+		},
+		{
+			name:     "no decision",
+			previous: []Payload{request},
+			wantErr:  "inconsistent bundle: grant was created without a decision",
+		},
+		{
+			name:     "different target",
+			previous: []Payload{NewAccessRequestMessage(AccessRequest{Role: testRoleARN, Duration: time.Hour, Target: "210987654321"}), NewDecisionPayload(Decision{AutoAllow: true})},
+			wantErr:  "inconsistent bundle: grant target 123456789012 does not match requested target 210987654321",
+		},
+		{
+			name:     "no request",
+			previous: []Payload{NewDecisionPayload(Decision{AutoAllow: true})},
+			wantErr:  "inconsistent bundle: grant was created without an access request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := grant.ValidateBundle(Facts{}, tt.previous)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestGrantValidateBundleDuration(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	request := NewAccessRequestMessage(AccessRequest{Role: testRoleARN, Reason: "test", Duration: time.Hour})
+	auth := NewAuthenticatedMessage(AuthMessageOpts{UserID: "alice", Time: start})
+	decision := NewDecisionPayload(Decision{AutoAllow: true})
+
+	tests := []struct {
+		name    string
+		grant   Grant
+		wantErr string
+	}{
+		{
+			name:  "within requested duration",
+			grant: Grant{ExpiresAt: start.Add(time.Hour), AWS: &AWSGrant{RoleARN: testRoleARN}},
+		},
+		{
+			name:    "exceeds requested duration",
+			grant:   Grant{ExpiresAt: start.Add(2 * time.Hour), AWS: &AWSGrant{RoleARN: testRoleARN}},
+			wantErr: "inconsistent bundle: grant duration 2h0m0s exceeds requested duration 1h0m0s",
+		},
+		{
+			name:  "starts after authentication",
+			grant: Grant{StartsAt: start.Add(time.Hour), ExpiresAt: start.Add(2 * time.Hour), AWS: &AWSGrant{RoleARN: testRoleARN}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewGrantCreatedPayload(tt.grant).ValidateBundle(Facts{}, []Payload{auth, request, decision})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
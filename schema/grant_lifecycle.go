@@ -0,0 +1,258 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/common-fate/attestations/types"
+)
+
+// GrantStatus is the lifecycle status of a grant.
+type GrantStatus string
+
+const (
+	// GrantStatusActive means the grant hasn't been revoked or marked as expired.
+	// The grant may still have passed its expiry time.
+	GrantStatusActive GrantStatus = "active"
+	// GrantStatusRevoked means the grant was revoked before it expired
+	GrantStatusRevoked GrantStatus = "revoked"
+	// GrantStatusExpired means the grant was observed to have expired
+	GrantStatusExpired GrantStatus = "expired"
+)
+
+// GrantState is the state of a grant after each lifecycle payload in a bundle is applied.
+type GrantState struct {
+	Grant Grant
+	// ExpiresAt is the grant's current expiry, including any extensions
+	ExpiresAt time.Time
+	Status    GrantStatus
+	// EndedAt is when the grant was revoked or observed to have expired
+	EndedAt time.Time
+}
+
+// Active reports whether the grant gives access at the time t.
+func (s GrantState) Active(t time.Time) bool {
+	return s.Status == GrantStatusActive && !t.After(s.ExpiresAt)
+}
+
+// CurrentGrant returns the state of the grant in a bundle's payloads,
+// applying each revocation, extension and expiry in bundle order.
+func CurrentGrant(payloads []Payload) (GrantState, error) {
+	var state GrantState
+	var found bool
+
+	for _, p := range payloads {
+		switch p := p.(type) {
+		case *GrantCreatedPayload:
+			state = GrantState{
+				Grant:     p.Grant,
+				ExpiresAt: p.Grant.ExpiresAt,
+				Status:    GrantStatusActive,
+			}
+			found = true
+		case *GrantExtendedPayload:
+			state.ExpiresAt = p.Extension.ExpiresAt
+		case *GrantRevokedPayload:
+			state.Status = GrantStatusRevoked
+			state.EndedAt = p.Revocation.RevokedAt
+		case *GrantExpiredPayload:
+			state.Status = GrantStatusExpired
+			state.EndedAt = p.Expiry.ExpiredAt
+		}
+	}
+
+	if !found {
+		return GrantState{}, fmt.Errorf("bundle did not contain a %s envelope", types.PayloadGrantCreated)
+	}
+	return state, nil
+}
+
+// activeGrant returns the current state of the grant in previous, which a lifecycle payload
+// is being applied to. The grant must not already have been revoked or marked as expired.
+func activeGrant(previous []Payload) (GrantState, error) {
+	state, err := CurrentGrant(previous)
+	if err != nil {
+		return GrantState{}, &ErrInconsistentBundle{Msg: err.Error()}
+	}
+	if state.Status != GrantStatusActive {
+		return GrantState{}, &ErrInconsistentBundle{Msg: fmt.Sprintf("grant was already %s", state.Status)}
+	}
+	return state, nil
+}
+
+// GrantRevocation records that a grant was revoked before it expired.
+type GrantRevocation struct {
+	RevokedAt time.Time `json:"revokedAt"`
+	// RevokedBy is the ID of the user or system which revoked the grant
+	RevokedBy string `json:"revokedBy"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type GrantRevokedPayload struct {
+	Link
+	Revocation  GrantRevocation `json:"revocation"`
+	PayloadType types.Payload   `json:"type"`
+}
+
+func NewGrantRevokedPayload(r GrantRevocation) *GrantRevokedPayload {
+	return &GrantRevokedPayload{
+		Revocation:  r,
+		PayloadType: types.PayloadGrantRevoked,
+	}
+}
+
+func (m *GrantRevokedPayload) Type() types.Payload {
+	return m.PayloadType
+}
+
+func (m *GrantRevokedPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(*m)
+}
+
+func (m *GrantRevokedPayload) ValidateContents(f Facts) error {
+	if m.Revocation.RevokedAt.IsZero() {
+		return &ErrInvalidPayloadContents{Msg: "grant revocation did not specify when the grant was revoked"}
+	}
+	if m.Revocation.RevokedBy == "" {
+		return &ErrInvalidPayloadContents{Msg: "grant revocation did not specify who revoked the grant"}
+	}
+	return nil
+}
+
+// ValidateBundle checks that an active grant was revoked before it expired.
+func (m *GrantRevokedPayload) ValidateBundle(f Facts, previous []Payload) error {
+	state, err := activeGrant(previous)
+	if err != nil {
+		return err
+	}
+	if m.Revocation.RevokedAt.After(state.ExpiresAt) {
+		return &ErrInconsistentBundle{
+			Msg: fmt.Sprintf("grant was revoked at %s, after it expired at %s", m.Revocation.RevokedAt, state.ExpiresAt),
+		}
+	}
+	return nil
+}
+
+// GrantExtension records that a grant's expiry was extended.
+type GrantExtension struct {
+	// ExtendedAt is when the grant was extended
+	ExtendedAt time.Time `json:"extendedAt"`
+	// ExpiresAt is the grant's new expiry
+	ExpiresAt time.Time `json:"expiresAt"`
+	// ExtendedBy is the ID of the user or system which extended the grant
+	ExtendedBy string `json:"extendedBy"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+type GrantExtendedPayload struct {
+	Link
+	Extension   GrantExtension `json:"extension"`
+	PayloadType types.Payload  `json:"type"`
+}
+
+func NewGrantExtendedPayload(e GrantExtension) *GrantExtendedPayload {
+	return &GrantExtendedPayload{
+		Extension:   e,
+		PayloadType: types.PayloadGrantExtended,
+	}
+}
+
+func (m *GrantExtendedPayload) Type() types.Payload {
+	return m.PayloadType
+}
+
+func (m *GrantExtendedPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(*m)
+}
+
+func (m *GrantExtendedPayload) ValidateContents(f Facts) error {
+	if m.Extension.ExtendedAt.IsZero() {
+		return &ErrInvalidPayloadContents{Msg: "grant extension did not specify when the grant was extended"}
+	}
+	if m.Extension.ExpiresAt.IsZero() {
+		return &ErrInvalidPayloadContents{Msg: "grant extension did not specify a new expiry"}
+	}
+	if m.Extension.ExtendedBy == "" {
+		return &ErrInvalidPayloadContents{Msg: "grant extension did not specify who extended the grant"}
+	}
+	return nil
+}
+
+// ValidateBundle checks that an active grant was extended before it expired, that its
+// expiry was moved later, and that the grant doesn't last longer than
+// MaxAccessRequestDuration after it was extended.
+func (m *GrantExtendedPayload) ValidateBundle(f Facts, previous []Payload) error {
+	state, err := activeGrant(previous)
+	if err != nil {
+		return err
+	}
+	if m.Extension.ExtendedAt.After(state.ExpiresAt) {
+		return &ErrInconsistentBundle{
+			Msg: fmt.Sprintf("grant was extended at %s, after it expired at %s", m.Extension.ExtendedAt, state.ExpiresAt),
+		}
+	}
+	if !m.Extension.ExpiresAt.After(state.ExpiresAt) {
+		return &ErrInconsistentBundle{
+			Msg: fmt.Sprintf("grant extension to %s is not after the current expiry of %s", m.Extension.ExpiresAt, state.ExpiresAt),
+		}
+	}
+	start, err := grantStart(state.Grant, previous)
+	if err != nil {
+		return err
+	}
+	if d := m.Extension.ExpiresAt.Sub(start); d > MaxAccessRequestDuration {
+		return &ErrInconsistentBundle{
+			Msg: fmt.Sprintf("extended grant duration %s exceeds the maximum of %s", d, MaxAccessRequestDuration),
+		}
+	}
+	return nil
+}
+
+// GrantExpiry records that a grant was observed to have expired.
+type GrantExpiry struct {
+	ExpiredAt time.Time `json:"expiredAt"`
+}
+
+type GrantExpiredPayload struct {
+	Link
+	Expiry      GrantExpiry   `json:"expiry"`
+	PayloadType types.Payload `json:"type"`
+}
+
+func NewGrantExpiredPayload(e GrantExpiry) *GrantExpiredPayload {
+	return &GrantExpiredPayload{
+		Expiry:      e,
+		PayloadType: types.PayloadGrantExpired,
+	}
+}
+
+func (m *GrantExpiredPayload) Type() types.Payload {
+	return m.PayloadType
+}
+
+func (m *GrantExpiredPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(*m)
+}
+
+func (m *GrantExpiredPayload) ValidateContents(f Facts) error {
+	if m.Expiry.ExpiredAt.IsZero() {
+		return &ErrInvalidPayloadContents{Msg: "grant expiry did not specify when the grant expired"}
+	}
+	return nil
+}
+
+// ValidateBundle checks that an active grant was marked as expired
+// no earlier than its current expiry.
+func (m *GrantExpiredPayload) ValidateBundle(f Facts, previous []Payload) error {
+	state, err := activeGrant(previous)
+	if err != nil {
+		return err
+	}
+	if m.Expiry.ExpiredAt.Before(state.ExpiresAt) {
+		return &ErrInconsistentBundle{
+			Msg: fmt.Sprintf("grant was marked as expired at %s, before its expiry at %s", m.Expiry.ExpiredAt, state.ExpiresAt),
+		}
+	}
+	return nil
+}
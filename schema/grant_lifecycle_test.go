@@ -0,0 +1,166 @@
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentGrant(t *testing.T) {
+	expires := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	grant := Grant{
+		Type:      GrantTypeAWS,
+		StartsAt:  expires.Add(-time.Hour),
+		ExpiresAt: expires,
+		AWS:       &AWSGrant{RoleARN: testRoleARN},
+	}
+	payloads := []Payload{NewGrantCreatedPayload(grant)}
+
+	state, err := CurrentGrant(payloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, GrantStatusActive, state.Status)
+	assert.True(t, state.Active(expires))
+	assert.False(t, state.Active(expires.Add(time.Second)))
+
+	extension := NewGrantExtendedPayload(GrantExtension{ExtendedAt: expires.Add(-time.Minute), ExpiresAt: expires.Add(time.Hour), ExtendedBy: "bob"})
+	assert.NoError(t, extension.ValidateBundle(Facts{}, payloads))
+	payloads = append(payloads, extension)
+
+	state, err = CurrentGrant(payloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expires.Add(time.Hour), state.ExpiresAt)
+	assert.True(t, state.Active(expires.Add(time.Second)))
+
+	expiry := NewGrantExpiredPayload(GrantExpiry{ExpiredAt: expires.Add(time.Hour)})
+	assert.NoError(t, expiry.ValidateBundle(Facts{}, payloads))
+	payloads = append(payloads, expiry)
+
+	state, err = CurrentGrant(payloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, GrantStatusExpired, state.Status)
+	assert.Equal(t, expires.Add(time.Hour), state.EndedAt)
+	assert.False(t, state.Active(expires))
+}
+
+func TestGrantLifecycleValidateBundle(t *testing.T) {
+	expires := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	created := NewGrantCreatedPayload(Grant{
+		Type:      GrantTypeAWS,
+		StartsAt:  expires.Add(-time.Hour),
+		ExpiresAt: expires,
+		AWS:       &AWSGrant{RoleARN: testRoleARN},
+	})
+	revoked := NewGrantRevokedPayload(GrantRevocation{RevokedAt: expires.Add(-time.Hour), RevokedBy: "bob"})
+
+	tests := []struct {
+		name     string
+		payload  BundleValidator
+		previous []Payload
+		wantErr  string
+	}{
+		{
+			name:     "revoked without a grant",
+			payload:  revoked,
+			previous: []Payload{NewDecisionPayload(Decision{AutoAllow: true})},
+			wantErr:  "inconsistent bundle: bundle did not contain a granted.dev/GrantCreated/v0.2 envelope",
+		},
+		{
+			name:     "revoked after expiry",
+			payload:  NewGrantRevokedPayload(GrantRevocation{RevokedAt: expires.Add(time.Hour), RevokedBy: "bob"}),
+			previous: []Payload{created},
+			wantErr:  "inconsistent bundle: grant was revoked at 2022-01-01 13:00:00 +0000 UTC, after it expired at 2022-01-01 12:00:00 +0000 UTC",
+		},
+		{
+			name:     "extended to an earlier expiry",
+			payload:  NewGrantExtendedPayload(GrantExtension{ExtendedAt: expires.Add(-time.Minute), ExpiresAt: expires.Add(-time.Hour), ExtendedBy: "bob"}),
+			previous: []Payload{created},
+			wantErr:  "inconsistent bundle: grant extension to 2022-01-01 11:00:00 +0000 UTC is not after the current expiry of 2022-01-01 12:00:00 +0000 UTC",
+		},
+		{
+			name:     "extended after expiry",
+			payload:  NewGrantExtendedPayload(GrantExtension{ExtendedAt: expires.Add(time.Minute), ExpiresAt: expires.Add(time.Hour), ExtendedBy: "bob"}),
+			previous: []Payload{created},
+			wantErr:  "inconsistent bundle: grant was extended at 2022-01-01 12:01:00 +0000 UTC, after it expired at 2022-01-01 12:00:00 +0000 UTC",
+		},
+		{
+			name:     "extended past the maximum duration",
+			payload:  NewGrantExtendedPayload(GrantExtension{ExtendedAt: expires.Add(-time.Minute), ExpiresAt: expires.Add(MaxAccessRequestDuration), ExtendedBy: "bob"}),
+			previous: []Payload{created},
+			wantErr:  "inconsistent bundle: extended grant duration 13h0m0s exceeds the maximum of 12h0m0s",
+		},
+		{
+			name:     "expired early",
+			payload:  NewGrantExpiredPayload(GrantExpiry{ExpiredAt: expires.Add(-time.Hour)}),
+			previous: []Payload{created},
+			wantErr:  "inconsistent bundle: grant was marked as expired at 2022-01-01 11:00:00 +0000 UTC, before its expiry at 2022-01-01 12:00:00 +0000 UTC",
+		},
+		{
+			name:     "extended after revocation",
+			payload:  NewGrantExtendedPayload(GrantExtension{ExtendedAt: expires.Add(-time.Minute), ExpiresAt: expires.Add(time.Hour), ExtendedBy: "bob"}),
+			previous: []Payload{created, revoked},
+			wantErr:  "inconsistent bundle: grant was already revoked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.payload.ValidateBundle(Facts{}, tt.previous)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestGrantLifecycleValidateContents(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload Payload
+		wantErr string
+	}{
+		{
+			name:    "revocation without a time",
+			payload: NewGrantRevokedPayload(GrantRevocation{RevokedBy: "bob"}),
+			wantErr: "grant revocation did not specify when the grant was revoked",
+		},
+		{
+			name:    "revocation without a revoker",
+			payload: NewGrantRevokedPayload(GrantRevocation{RevokedAt: time.Now()}),
+			wantErr: "grant revocation did not specify who revoked the grant",
+		},
+		{
+			name:    "extension without a time",
+			payload: NewGrantExtendedPayload(GrantExtension{ExpiresAt: time.Now(), ExtendedBy: "bob"}),
+			wantErr: "grant extension did not specify when the grant was extended",
+		},
+		{
+			name:    "extension without an expiry",
+			payload: NewGrantExtendedPayload(GrantExtension{ExtendedAt: time.Now(), ExtendedBy: "bob"}),
+			wantErr: "grant extension did not specify a new expiry",
+		},
+		{
+			name:    "extension without an extender",
+			payload: NewGrantExtendedPayload(GrantExtension{ExtendedAt: time.Now(), ExpiresAt: time.Now()}),
+			wantErr: "grant extension did not specify who extended the grant",
+		},
+		{
+			name:    "expiry without a time",
+			payload: NewGrantExpiredPayload(GrantExpiry{}),
+			wantErr: "grant expiry did not specify when the grant expired",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.payload.ValidateContents(Facts{})
+			targetErr := &ErrInvalidPayloadContents{}
+			assert.ErrorAs(t, err, &targetErr)
+			assert.Equal(t, tt.wantErr, targetErr.Msg)
+		})
+	}
+}
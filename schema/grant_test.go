@@ -0,0 +1,174 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantValidate(t *testing.T) {
+	expires := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		grant   Grant
+		wantErr string
+	}{
+		{
+			name:  "aws",
+			grant: Grant{Type: GrantTypeAWS, AWS: &AWSGrant{RoleARN: testRoleARN}},
+		},
+		{
+			name:    "aws invalid role ARN",
+			grant:   Grant{Type: GrantTypeAWS, AWS: &AWSGrant{RoleARN: "arn:aws:iam::123456789012:user/alice"}},
+			wantErr: `invalid AWS IAM role ARN "arn:aws:iam::123456789012:user/alice"`,
+		},
+		{
+			name:  "gcp",
+			grant: Grant{Type: GrantTypeGCP, GCP: &GCPGrant{Project: "example", Role: "roles/viewer", Member: "user:alice@example.com"}},
+		},
+		{
+			name:    "gcp invalid role",
+			grant:   Grant{Type: GrantTypeGCP, GCP: &GCPGrant{Project: "example", Role: "viewer", Member: "user:alice@example.com"}},
+			wantErr: `invalid GCP IAM role "viewer"`,
+		},
+		{
+			name:  "azure",
+			grant: Grant{Type: GrantTypeAzure, Azure: &AzureGrant{Scope: "/subscriptions/example", RoleDefinitionID: "reader", PrincipalID: "alice"}},
+		},
+		{
+			name:    "azure invalid scope",
+			grant:   Grant{Type: GrantTypeAzure, Azure: &AzureGrant{Scope: "subscriptions/example", RoleDefinitionID: "reader", PrincipalID: "alice"}},
+			wantErr: `invalid Azure scope "subscriptions/example"`,
+		},
+		{
+			name:  "okta",
+			grant: Grant{Type: GrantTypeOkta, Okta: &OktaGrant{GroupID: "admins", UserID: "alice"}},
+		},
+		{
+			name:    "okta without user",
+			grant:   Grant{Type: GrantTypeOkta, Okta: &OktaGrant{GroupID: "admins"}},
+			wantErr: "Okta grant did not specify a user ID",
+		},
+		{
+			name:  "kubernetes cluster role",
+			grant: Grant{Type: GrantTypeKubernetes, Kubernetes: &KubernetesGrant{Cluster: "prod", RoleKind: KubernetesClusterRole, RoleName: "view", Subject: "alice"}},
+		},
+		{
+			name:    "kubernetes role without namespace",
+			grant:   Grant{Type: GrantTypeKubernetes, Kubernetes: &KubernetesGrant{Cluster: "prod", RoleKind: KubernetesRole, RoleName: "view", Subject: "alice"}},
+			wantErr: "Kubernetes grant of a Role must specify a namespace",
+		},
+		{
+			name:    "no details",
+			grant:   Grant{Type: GrantTypeAWS},
+			wantErr: "grant must have exactly one set of provider details, but has 0",
+		},
+		{
+			name: "multiple details",
+			grant: Grant{
+				Type: GrantTypeAWS,
+				AWS:  &AWSGrant{RoleARN: testRoleARN},
+				Okta: &OktaGrant{GroupID: "admins", UserID: "alice"},
+			},
+			wantErr: "grant must have exactly one set of provider details, but has 2",
+		},
+		{
+			name:    "type does not match details",
+			grant:   Grant{Type: GrantTypeGCP, AWS: &AWSGrant{RoleARN: testRoleARN}},
+			wantErr: "grant type gcp does not match aws provider details",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.grant.ExpiresAt = expires
+			err := tt.grant.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestGrantCreatedPayloadRoundTrip(t *testing.T) {
+	g := Grant{
+		Type:      GrantTypeKubernetes,
+		ExpiresAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedAt: time.Date(2021, 12, 31, 23, 0, 0, 0, time.UTC),
+		Kubernetes: &KubernetesGrant{
+			Cluster:   "prod",
+			Namespace: "default",
+			RoleKind:  KubernetesRole,
+			RoleName:  "view",
+			Subject:   "alice",
+		},
+	}
+	serialized, err := NewGrantCreatedPayload(g).MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.JSONEq(t, `{
+		"grant": {
+			"type": "kubernetes",
+			"startsAt": "0001-01-01T00:00:00Z",
+			"expiresAt": "2022-01-01T00:00:00Z",
+			"createdAt": "2021-12-31T23:00:00Z",
+			"kubernetes": {"cluster": "prod", "namespace": "default", "roleKind": "Role", "roleName": "view", "subject": "alice"}
+		},
+		"type": "granted.dev/GrantCreated/v0.2"
+	}`, string(serialized))
+
+	p, err := DeserializePayload(serialized, types.PayloadGrantCreated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, g, p.(*GrantCreatedPayload).Grant)
+}
+
+func TestUpgradeGrantCreatedV01(t *testing.T) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"decision": map[string]interface{}{
+			"Type":       "aws",
+			"ExpiresAt":  "2022-01-01T00:00:00Z",
+			"AWSRoleARN": "test-role",
+		},
+		"type": "granted.dev/GrantCreated/v0.1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// v0.1 grants are only accepted if legacy payloads are enabled
+	_, err = DeserializePayload(payload, types.PayloadGrantCreated)
+	assert.ErrorAs(t, err, new(*ErrLegacyPayloadType))
+
+	p, err := DeserializePayloadWithOptions(payload, types.PayloadGrantCreated, DeserializeOptions{AcceptLegacy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grant := p.(*GrantCreatedPayload)
+	assert.Equal(t, types.PayloadGrantCreated, grant.Type())
+	assert.Equal(t, types.PayloadGrantCreatedV01, grant.UpgradedFrom)
+	assert.Equal(t, Grant{
+		Type:      GrantTypeAWS,
+		ExpiresAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		AWS:       &AWSGrant{RoleARN: "test-role"},
+	}, grant.Grant)
+
+	// v0.1 grants only had their expiry validated
+	assert.NoError(t, grant.ValidateContents(Facts{}))
+
+	noExpiry := *grant
+	noExpiry.Grant.ExpiresAt = time.Time{}
+	assert.EqualError(t, noExpiry.ValidateContents(Facts{}), "invalid payload contents: grant did not specify when it expires")
+
+	backwards := *grant
+	backwards.Grant.StartsAt = grant.Grant.ExpiresAt.Add(time.Hour)
+	assert.EqualError(t, backwards.ValidateContents(Facts{}), "invalid payload contents: grant starts at 2022-01-01 01:00:00 +0000 UTC, which is not before it expires at 2022-01-01 00:00:00 +0000 UTC")
+}
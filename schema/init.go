@@ -1,26 +1,62 @@
 package schema
 
 import (
-	"crypto/x509"
+	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"time"
 
 	"github.com/common-fate/attestations/types"
 )
 
 type InitPayload struct {
-	PublicKey   string        `json:"publicKey"`
+	Link
+	// PublicKey is the user's base64-encoded PKIX, ASN.1 DER public key
+	PublicKey string `json:"publicKey,omitempty"`
+	// PublicKeyJWK is the user's public key as a JWK, such as a key generated with
+	// WebCrypto. It is set instead of PublicKey.
+	PublicKeyJWK *JWK `json:"publicKeyJwk,omitempty"`
+	// Nonce is a random value, either generated by the client or issued by the
+	// server, which allows verifiers to reject INIT envelopes which are replayed.
+	Nonce string `json:"nonce"`
+	// Time is when the INIT envelope was created, in Unix nanoseconds
+	Time        int64         `json:"time"`
 	PayloadType types.Payload `json:"type"`
 }
 
-func NewInitMessage(publicKey []byte) *InitPayload {
+func NewInitMessage(publicKey []byte, nonce string, t time.Time) *InitPayload {
 	data := base64.StdEncoding.EncodeToString(publicKey)
 	return &InitPayload{
 		PayloadType: types.PayloadInit,
 		PublicKey:   data,
+		Nonce:       nonce,
+		Time:        t.UnixNano(),
 	}
 }
 
+// NewInitMessageWithJWK returns an INIT payload containing the user's public key as a JWK.
+func NewInitMessageWithJWK(publicKey JWK, nonce string, t time.Time) *InitPayload {
+	return &InitPayload{
+		PayloadType:  types.PayloadInit,
+		PublicKeyJWK: &publicKey,
+		Nonce:        nonce,
+		Time:         t.UnixNano(),
+	}
+}
+
+// nonceBytes is the number of random bytes in a generated nonce.
+const nonceBytes = 32
+
+// GenerateNonce returns a random nonce for an INIT envelope.
+func GenerateNonce() (string, error) {
+	b := make([]byte, nonceBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 func (m *InitPayload) Type() types.Payload {
 	return m.PayloadType
 }
@@ -29,8 +65,53 @@ func (m *InitPayload) MarshalJSON() ([]byte, error) {
 	return json.Marshal(*m)
 }
 
+// UserPublicKey returns the user's public key from the payload.
+func (m *InitPayload) UserPublicKey() (PublicKey, error) {
+	if m.PublicKeyJWK != nil {
+		if m.PublicKey != "" {
+			return nil, &ErrInvalidPayloadContents{Msg: "INIT payload must contain either a PKIX or a JWK public key, not both"}
+		}
+		return m.PublicKeyJWK.PublicKey()
+	}
+	der, err := base64.StdEncoding.DecodeString(m.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePublicKey(der)
+}
+
 func (m *InitPayload) ValidateContents(f Facts) error {
-	publicDerBytes, err := x509.MarshalPKIXPublicKey(&f.Actors.User.PublicKey)
+	// the user's key must not have been revoked, such as when their device was lost.
+	// Every stage begins with the INIT envelope, so revoked keys are rejected at every stage.
+	if f.RevokedKeys != nil {
+		key, err := m.UserPublicKey()
+		if err != nil {
+			return err
+		}
+		err = CheckRevocation(f.RevokedKeys, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.TrustStore.Has(ActorUser) {
+		return m.validateTrustedKey(f.TrustStore)
+	}
+
+	if m.PublicKeyJWK != nil {
+		key, err := m.UserPublicKey()
+		if err != nil {
+			return err
+		}
+		if !PublicKeysEqual(key, f.Actors.User.PublicKey) {
+			return &ErrInvalidPayloadContents{
+				Msg: "user public key didn't match",
+			}
+		}
+		return nil
+	}
+
+	publicDerBytes, err := MarshalPublicKey(f.Actors.User.PublicKey)
 	if err != nil {
 		return err
 	}
@@ -44,3 +125,20 @@ func (m *InitPayload) ValidateContents(f Facts) error {
 
 	return nil
 }
+
+// validateTrustedKey checks that the public key is one of the user's keys in
+// the trust store which was valid at the time the INIT envelope was created.
+func (m *InitPayload) validateTrustedKey(store *TrustStore) error {
+	key, err := m.UserPublicKey()
+	if err != nil {
+		return err
+	}
+	for _, trusted := range store.KeysAt(ActorUser, m.AttestedAt()) {
+		if PublicKeysEqual(key, trusted) {
+			return nil
+		}
+	}
+	return &ErrInvalidPayloadContents{
+		Msg: "user public key wasn't trusted at the time of the INIT envelope",
+	}
+}
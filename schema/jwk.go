@@ -0,0 +1,399 @@
+package schema
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+)
+
+// JWK key types and curves, as defined in RFC 7518 and RFC 8037.
+const (
+	KeyTypeEC  = "EC"
+	KeyTypeOKP = "OKP"
+	KeyTypeRSA = "RSA"
+
+	CurveP256    = "P-256"
+	CurveP384    = "P-384"
+	CurveEd25519 = "Ed25519"
+)
+
+// JWK is a public key in JSON Web Key form (RFC 7517), such as a key
+// exported from WebCrypto or published in an identity provider's JWKS.
+// Binary members are base64url-encoded without padding.
+type JWK struct {
+	KeyType   string    `json:"kty"`
+	KeyID     string    `json:"kid,omitempty"`
+	Algorithm Algorithm `json:"alg,omitempty"`
+	Use       string    `json:"use,omitempty"`
+	// Curve, X and Y are set for EC and OKP keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+	// N and E are set for RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// ErrInvalidJWK is returned when a JWK doesn't describe a supported public key.
+type ErrInvalidJWK struct {
+	Msg string
+}
+
+func (e *ErrInvalidJWK) Error() string {
+	return fmt.Sprintf("invalid JWK: %s", e.Msg)
+}
+
+var b64url = base64.RawURLEncoding
+
+// NewJWK encodes a public key as a JWK. The key's algorithm is included in the JWK.
+func NewJWK(key PublicKey) (JWK, error) {
+	if key == nil {
+		return JWK{}, errors.New("public key is not set")
+	}
+	switch k := key.Public().(type) {
+	case *ecdsa.PublicKey:
+		crv, err := jwkCurveName(k.Curve)
+		if err != nil {
+			return JWK{}, err
+		}
+		size := curveBytes(k.Curve)
+		return JWK{
+			KeyType:   KeyTypeEC,
+			Algorithm: key.Algorithm(),
+			Curve:     crv,
+			X:         b64url.EncodeToString(k.X.FillBytes(make([]byte, size))),
+			Y:         b64url.EncodeToString(k.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType:   KeyTypeOKP,
+			Algorithm: key.Algorithm(),
+			Curve:     CurveEd25519,
+			X:         b64url.EncodeToString(k),
+		}, nil
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   KeyTypeRSA,
+			Algorithm: key.Algorithm(),
+			N:         b64url.EncodeToString(k.N.Bytes()),
+			E:         b64url.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	default:
+		return JWK{}, &ErrUnsupportedAlgorithm{Msg: fmt.Sprintf("key type %T", k)}
+	}
+}
+
+// PublicKey decodes the public key from the JWK. EC keys must be on the P-256 or P-384
+// curves and OKP keys must be Ed25519 keys, otherwise ErrUnsupportedAlgorithm is returned.
+// If the JWK specifies an algorithm or use, they must be the algorithm used with the key, and "sig".
+// ErrUnsupportedAlgorithm is also returned if the JWK specifies an algorithm which isn't supported,
+// such as an RSA key for use with RS256.
+func (j JWK) PublicKey() (PublicKey, error) {
+	if j.Use != "" && j.Use != "sig" {
+		return nil, &ErrInvalidJWK{Msg: fmt.Sprintf("key use %q is not sig", j.Use)}
+	}
+
+	var key PublicKey
+	var err error
+	switch j.KeyType {
+	case KeyTypeEC:
+		key, err = j.ecdsaPublicKey()
+	case KeyTypeOKP:
+		key, err = j.ed25519PublicKey()
+	case KeyTypeRSA:
+		key, err = j.rsaPublicKey()
+	default:
+		return nil, &ErrUnsupportedAlgorithm{Msg: fmt.Sprintf("JWK key type %q", j.KeyType)}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if j.Algorithm != "" && j.Algorithm != key.Algorithm() {
+		if !j.Algorithm.supported() {
+			return nil, &ErrUnsupportedAlgorithm{Msg: fmt.Sprintf("JWK algorithm %s", j.Algorithm)}
+		}
+		return nil, &ErrInvalidJWK{Msg: fmt.Sprintf("algorithm %s can't be used with a %s key", j.Algorithm, key.Algorithm())}
+	}
+	return key, nil
+}
+
+func (j JWK) ecdsaPublicKey() (PublicKey, error) {
+	var curve elliptic.Curve
+	switch j.Curve {
+	case CurveP256:
+		curve = elliptic.P256()
+	case CurveP384:
+		curve = elliptic.P384()
+	default:
+		return nil, &ErrUnsupportedAlgorithm{Msg: fmt.Sprintf("JWK EC curve %q", j.Curve)}
+	}
+	x, err := decodeCoordinate(j.X, "x", curveBytes(curve))
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeCoordinate(j.Y, "y", curveBytes(curve))
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, &ErrInvalidJWK{Msg: fmt.Sprintf("point is not on the %s curve", j.Curve)}
+	}
+	return NewPublicKey(&ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+}
+
+func (j JWK) ed25519PublicKey() (PublicKey, error) {
+	if j.Curve != CurveEd25519 {
+		return nil, &ErrUnsupportedAlgorithm{Msg: fmt.Sprintf("JWK OKP curve %q", j.Curve)}
+	}
+	x, err := b64url.DecodeString(j.X)
+	if err != nil {
+		return nil, &ErrInvalidJWK{Msg: fmt.Sprintf("decoding x: %s", err)}
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, &ErrInvalidJWK{Msg: fmt.Sprintf("x must be %d bytes", ed25519.PublicKeySize)}
+	}
+	return NewPublicKey(ed25519.PublicKey(x))
+}
+
+func (j JWK) rsaPublicKey() (PublicKey, error) {
+	n, err := b64url.DecodeString(j.N)
+	if err != nil || len(n) == 0 {
+		return nil, &ErrInvalidJWK{Msg: "invalid modulus"}
+	}
+	e, err := b64url.DecodeString(j.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, &ErrInvalidJWK{Msg: "invalid exponent"}
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if exponent.Cmp(big.NewInt(1)) <= 0 {
+		return nil, &ErrInvalidJWK{Msg: "invalid exponent"}
+	}
+	return NewPublicKey(&rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())})
+}
+
+func decodeCoordinate(encoded string, name string, size int) (*big.Int, error) {
+	b, err := b64url.DecodeString(encoded)
+	if err != nil {
+		return nil, &ErrInvalidJWK{Msg: fmt.Sprintf("decoding %s: %s", name, err)}
+	}
+	if len(b) != size {
+		return nil, &ErrInvalidJWK{Msg: fmt.Sprintf("%s must be %d bytes", name, size)}
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func jwkCurveName(curve elliptic.Curve) (string, error) {
+	switch curve {
+	case elliptic.P256():
+		return CurveP256, nil
+	case elliptic.P384():
+		return CurveP384, nil
+	default:
+		return "", &ErrUnsupportedAlgorithm{Msg: fmt.Sprintf("ECDSA curve %s", curve.Params().Name)}
+	}
+}
+
+func curveBytes(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
+
+// Thumbprint returns the JWK thumbprint of the key (RFC 7638), which is the base64url-encoded
+// SHA-256 digest of the key's required members. Thumbprints may be used as key IDs in signatures.
+func (j JWK) Thumbprint() (string, error) {
+	// the required members are serialised in lexicographic order, without whitespace
+	var members interface{}
+	switch j.KeyType {
+	case KeyTypeEC:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{j.Curve, j.KeyType, j.X, j.Y}
+	case KeyTypeOKP:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Curve, j.KeyType, j.X}
+	case KeyTypeRSA:
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.KeyType, j.N}
+	default:
+		return "", &ErrInvalidJWK{Msg: fmt.Sprintf("unsupported key type %q", j.KeyType)}
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(data)
+	return b64url.EncodeToString(digest[:]), nil
+}
+
+// JWKThumbprint returns the JWK thumbprint of a public key (RFC 7638).
+func JWKThumbprint(key PublicKey) (string, error) {
+	j, err := NewJWK(key)
+	if err != nil {
+		return "", err
+	}
+	return j.Thumbprint()
+}
+
+// MarshalJWK encodes a public key as a JWK, with its thumbprint as the key ID.
+func MarshalJWK(key PublicKey) ([]byte, error) {
+	j, err := NewJWK(key)
+	if err != nil {
+		return nil, err
+	}
+	j.KeyID, err = j.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// ParseJWK parses a public key from a JWK.
+func ParseJWK(data []byte) (PublicKey, error) {
+	var j JWK
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return nil, err
+	}
+	return j.PublicKey()
+}
+
+// JWKS is a JSON Web Key Set (RFC 7517), such as the keys published by an identity provider.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// ParseJWKS parses a JWKS document.
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var s JWKS
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Key returns the key with the given key ID.
+func (s JWKS) Key(kid string) (JWK, bool) {
+	for _, k := range s.Keys {
+		if k.KeyID == kid {
+			return k, true
+		}
+	}
+	return JWK{}, false
+}
+
+// PublicKeys returns the signing keys in the set. Keys which are for encryption, or which
+// use an unsupported algorithm, are skipped as permitted by RFC 7517, but a key which is
+// malformed returns an error.
+func (s JWKS) PublicKeys() ([]PublicKey, error) {
+	var keys []PublicKey
+	for i, j := range s.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		key, err := j.PublicKey()
+		var unsupported *ErrUnsupportedAlgorithm
+		if errors.As(err, &unsupported) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %d (kid %q): %w", i, j.KeyID, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// KeySource provides the public keys which an actor signs with.
+type KeySource interface {
+	PublicKeys(ctx context.Context) ([]PublicKey, error)
+}
+
+// JWKSKeySource provides keys from a JWKS document served over HTTP,
+// such as the jwks_uri of an identity provider.
+type JWKSKeySource struct {
+	URL string
+	// Client is used to fetch the JWKS. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// maxJWKSBytes limits the size of a JWKS document which will be read.
+const maxJWKSBytes = 1 << 20
+
+// JWKS fetches the JWKS document.
+func (s *JWKSKeySource) JWKS(ctx context.Context) (*JWKS, error) {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS from %s: unexpected status %s", s.URL, res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxJWKSBytes))
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+func (s *JWKSKeySource) PublicKeys(ctx context.Context) ([]PublicKey, error) {
+	set, err := s.JWKS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return set.PublicKeys()
+}
+
+// WithKeysFrom returns the identity server with the keys from the key source
+// in place of its keys, such as the keys published in a JWKS.
+func (i IdentityServer) WithKeysFrom(ctx context.Context, src KeySource) (IdentityServer, error) {
+	keys, err := src.PublicKeys(ctx)
+	if err != nil {
+		return IdentityServer{}, err
+	}
+	if len(keys) == 0 {
+		return IdentityServer{}, errors.New("key source has no signing keys")
+	}
+	i.PublicKey = keys[0]
+	i.PublicKeys = keys[1:]
+	return i, nil
+}
+
+// ThumbprintSigner identifies its key by the key's JWK thumbprint,
+// rather than by the digest of the key's DER encoding.
+type ThumbprintSigner struct {
+	EnvelopeSigner
+	PublicKey PublicKey
+}
+
+func (s *ThumbprintSigner) KeyID() (string, error) {
+	return JWKThumbprint(s.PublicKey)
+}
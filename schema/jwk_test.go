@@ -0,0 +1,189 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWKRoundTrip(t *testing.T) {
+	for _, alg := range []Algorithm{AlgorithmES256, AlgorithmES384, AlgorithmEdDSA, AlgorithmPS256} {
+		t.Run(string(alg), func(t *testing.T) {
+			key := mustGeneratePublicKey(t, alg)
+			data, err := MarshalJWK(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseJWK(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.True(t, PublicKeysEqual(key, got))
+			assert.Equal(t, alg, got.Algorithm())
+
+			var j JWK
+			err = json.Unmarshal(data, &j)
+			if err != nil {
+				t.Fatal(err)
+			}
+			thumbprint, err := JWKThumbprint(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, thumbprint, j.KeyID)
+		})
+	}
+}
+
+// The example from RFC 7638, section 3.1.
+func TestJWKThumbprint(t *testing.T) {
+	j := JWK{
+		KeyType:   KeyTypeRSA,
+		N:         "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:         "AQAB",
+		Algorithm: "RS256",
+		KeyID:     "2011-04-29",
+	}
+	thumbprint, err := j.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+}
+
+func TestParseJWKRejectsInvalidKeys(t *testing.T) {
+	j, err := NewJWK(mustGeneratePublicKey(t, AlgorithmES256))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offCurve := j
+	offCurve.Y = offCurve.X
+	_, err = offCurve.PublicKey()
+	assert.EqualError(t, err, "invalid JWK: point is not on the P-256 curve")
+
+	wrongAlg := j
+	wrongAlg.Algorithm = AlgorithmES384
+	_, err = wrongAlg.PublicKey()
+	assert.EqualError(t, err, "invalid JWK: algorithm ES384 can't be used with a ES256 key")
+
+	encryption := j
+	encryption.Use = "enc"
+	_, err = encryption.PublicKey()
+	assert.EqualError(t, err, `invalid JWK: key use "enc" is not sig`)
+
+	_, err = ParseJWK([]byte(`{"kty": "EC", "crv": "P-521", "x": "", "y": ""}`))
+	assert.ErrorAs(t, err, new(*ErrUnsupportedAlgorithm))
+
+	rs256, err := NewJWK(mustGeneratePublicKey(t, AlgorithmPS256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs256.Algorithm = "RS256"
+	_, err = rs256.PublicKey()
+	assert.EqualError(t, err, "unsupported algorithm: JWK algorithm RS256")
+}
+
+// Identity providers commonly publish RS256 keys alongside the keys they sign
+// attestations with. Keys for algorithms which aren't supported are skipped.
+func TestJWKSPublicKeysSkipsUnsupportedAlgorithms(t *testing.T) {
+	rs256, err := NewJWK(mustGeneratePublicKey(t, AlgorithmPS256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs256.Algorithm = "RS256"
+	rs256.KeyID = "rs256"
+
+	key := mustGeneratePublicKey(t, AlgorithmES256)
+	es256, err := NewJWK(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	es256.KeyID = "es256"
+
+	keys, err := JWKS{Keys: []JWK{rs256, es256}}.PublicKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, keys, 1)
+	assert.True(t, PublicKeysEqual(key, keys[0]))
+}
+
+func TestJWKSKeySource(t *testing.T) {
+	key := mustGeneratePublicKey(t, AlgorithmES256)
+	j, err := NewJWK(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := JWKS{Keys: []JWK{
+		{KeyType: "oct", KeyID: "symmetric"},
+		{KeyType: KeyTypeRSA, Use: "enc", KeyID: "encryption"},
+		j,
+	}}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewEncoder(w).Encode(set)
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer srv.Close()
+
+	server, err := IdentityServer{ID: "idp"}.WithKeysFrom(context.Background(), &JWKSKeySource{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "idp", server.ID)
+	assert.True(t, PublicKeysEqual(key, server.PublicKey))
+	assert.Empty(t, server.PublicKeys)
+}
+
+func TestVerifySignatureWithThumbprintKeyID(t *testing.T) {
+	ctx := context.Background()
+	priv, err := GenerateKey(AlgorithmEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &LocalSigner{PrivateKey: priv}
+	pub, err := signer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := EnvelopeFromPayload(NewInitMessageWithJWK(JWK{}, "nonce", time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.Sign(ctx, &ThumbprintSigner{EnvelopeSigner: signer, PublicKey: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbprint, err := JWKThumbprint(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, thumbprint, e.Signatures[0].KeyID)
+
+	err = e.VerifySignatures([]ExpectedSigner{{Name: ActorUser, PublicKey: pub}})
+	assert.NoError(t, err)
+}
+
+func TestInitPayloadWithJWK(t *testing.T) {
+	key := mustGeneratePublicKey(t, AlgorithmES256)
+	j, err := NewJWK(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewInitMessageWithJWK(j, "nonce", time.Now())
+
+	f := Facts{Actors: Actors{User: User{ID: "alice", PublicKey: key}}}
+	assert.NoError(t, p.ValidateContents(f))
+
+	f.Actors.User.PublicKey = mustGeneratePublicKey(t, AlgorithmES256)
+	assert.EqualError(t, p.ValidateContents(f), "invalid payload contents: user public key didn't match")
+}
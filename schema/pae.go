@@ -0,0 +1,42 @@
+package schema
+
+import (
+	"fmt"
+)
+
+// SigningVersion identifies how the bytes covered by an envelope signature
+// are constructed from the envelope.
+type SigningVersion int
+
+const (
+	// SigningVersionDSSEv1 signs the DSSE pre-authentication encoding (PAE)
+	// of the payload type and the payload. This is the default for all newly
+	// signed envelopes.
+	SigningVersionDSSEv1 SigningVersion = iota + 1
+	// SigningVersionLegacy signs the raw payload bytes only. The payload type
+	// isn't covered by the signature, so this should only be accepted while
+	// migrating bundles which were signed before DSSE signing was introduced.
+	SigningVersionLegacy
+)
+
+func (v SigningVersion) String() string {
+	switch v {
+	case SigningVersionDSSEv1:
+		return "DSSEv1"
+	case SigningVersionLegacy:
+		return "legacy"
+	default:
+		return fmt.Sprintf("SigningVersion(%d)", int(v))
+	}
+}
+
+// PAE returns the DSSE v1 pre-authentication encoding of a payload.
+//
+//	PAE(type, body) = "DSSEv1" + SP + LEN(type) + SP + type + SP + LEN(body) + SP + body
+//
+// where LEN is the ASCII decimal length in bytes.
+// See https://github.com/secure-systems-lab/dsse/blob/master/protocol.md
+func PAE(payloadType string, payload []byte) []byte {
+	header := fmt.Sprintf("DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	return append([]byte(header), payload...)
+}
@@ -1,7 +1,6 @@
 package schema
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/common-fate/attestations/types"
@@ -11,6 +10,38 @@ type Payload interface {
 	MarshalJSON() ([]byte, error)
 	Type() types.Payload
 	ValidateContents(f Facts) error
+	// PreviousDigest and SetPreviousDigest link the payload to the
+	// preceding envelope in a bundle. Payloads implement these by embedding Link.
+	PreviousDigest() string
+	SetPreviousDigest(digest string)
+}
+
+// BundleValidator is implemented by payloads whose contents must be consistent
+// with the payloads before them in a bundle. For example, a grant must be for
+// the role which was requested. Verification stages call ValidateBundle with
+// the payloads of every envelope before the payload, in bundle order.
+type BundleValidator interface {
+	ValidateBundle(f Facts, previous []Payload) error
+}
+
+// ErrInconsistentBundle is returned when a payload is inconsistent
+// with the payloads before it in a bundle.
+type ErrInconsistentBundle struct {
+	Msg string
+}
+
+func (e *ErrInconsistentBundle) Error() string {
+	return fmt.Sprintf("inconsistent bundle: %s", e.Msg)
+}
+
+// FindPayload returns the last payload of the given type.
+func FindPayload(payloads []Payload, t types.Payload) (Payload, bool) {
+	for i := len(payloads) - 1; i >= 0; i-- {
+		if payloads[i] != nil && payloads[i].Type() == t {
+			return payloads[i], true
+		}
+	}
+	return nil, false
 }
 
 type ErrInvalidPayloadType struct {
@@ -30,52 +61,14 @@ func (e *ErrInvalidPayloadContents) Error() string {
 	return fmt.Sprintf("invalid payload contents: %s", e.Msg)
 }
 
+// DeserializePayload unmarshals a payload using the default registry,
+// checking that its type matches the expected type.
 func DeserializePayload(payload []byte, expected types.Payload) (Payload, error) {
-	var pt struct {
-		PayloadType types.Payload `json:"type"`
-	}
-
-	err := json.Unmarshal(payload, &pt)
-	if err != nil {
-		return nil, err
-	}
-
-	var p Payload
-
-	switch pt.PayloadType {
-	case types.PayloadInit:
-		var m InitPayload
-		err = json.Unmarshal(payload, &m)
-		p = &m
-	case types.PayloadAuthenticated:
-		var m AuthenticatedPayload
-		err = json.Unmarshal(payload, &m)
-		p = &m
-	case types.PayloadAccessRequest:
-		var m AccessRequestPayload
-		err = json.Unmarshal(payload, &m)
-		p = &m
-	case types.PayloadDecision:
-		var m DecisionPayload
-		err = json.Unmarshal(payload, &m)
-		p = &m
-	case types.PayloadGrantCreated:
-		var m GrantCreatedPayload
-		err = json.Unmarshal(payload, &m)
-		p = &m
-	default:
-		return nil, fmt.Errorf("unhandled payload type %s", pt.PayloadType)
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	if p.Type() != expected {
-		return nil, &ErrInvalidPayloadType{
-			Expected: expected,
-			Actual:   p.Type(),
-		}
-	}
+	return DefaultRegistry.Deserialize(payload, expected)
+}
 
-	return p, nil
+// DeserializePayloadWithOptions is the same as DeserializePayload, but accepts
+// legacy payload types if the options allow them.
+func DeserializePayloadWithOptions(payload []byte, expected types.Payload, opts DeserializeOptions) (Payload, error) {
+	return DefaultRegistry.DeserializeWithOptions(payload, expected, opts)
 }
@@ -0,0 +1,165 @@
+package schema
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// PublicKey is an actor's public key. Each supported algorithm has its own
+// implementation which knows how to verify signatures made with the algorithm.
+type PublicKey interface {
+	// Algorithm returns the signature algorithm used with the key.
+	Algorithm() Algorithm
+	// Verify reports whether sig is a valid signature over message.
+	Verify(message, sig []byte) (bool, error)
+	// Public returns the underlying key, such as an *ecdsa.PublicKey.
+	Public() crypto.PublicKey
+}
+
+// NewPublicKey wraps a crypto.PublicKey in the PublicKey implementation for its algorithm.
+// Supported keys are ECDSA keys on the P-256 or P-384 curves, Ed25519 keys and
+// RSA keys of at least 2048 bits, which are used with RSASSA-PSS.
+func NewPublicKey(key crypto.PublicKey) (PublicKey, error) {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		_, err := ecdsaAlgorithm(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		return &ECDSAPublicKey{Key: k}, nil
+	case ecdsa.PublicKey:
+		return NewPublicKey(&k)
+	case ed25519.PublicKey:
+		if len(k) != ed25519.PublicKeySize {
+			return nil, &ErrUnsupportedAlgorithm{Msg: "invalid Ed25519 public key size"}
+		}
+		return Ed25519PublicKey{Key: k}, nil
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, &ErrUnsupportedAlgorithm{Msg: fmt.Sprintf("RSA keys must be at least %d bits", minRSAKeyBits)}
+		}
+		return &RSAPSSPublicKey{Key: k}, nil
+	case PublicKey:
+		return k, nil
+	default:
+		return nil, &ErrUnsupportedAlgorithm{Msg: fmt.Sprintf("key type %T", key)}
+	}
+}
+
+// ParsePublicKey parses a DER-encoded PKIX public key.
+func ParsePublicKey(der []byte) (PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	return NewPublicKey(key)
+}
+
+// MarshalPublicKey encodes a public key in PKIX, ASN.1 DER form.
+func MarshalPublicKey(key PublicKey) ([]byte, error) {
+	if key == nil {
+		return nil, errors.New("public key is not set")
+	}
+	return x509.MarshalPKIXPublicKey(key.Public())
+}
+
+// PublicKeysEqual reports whether two public keys are the same key.
+func PublicKeysEqual(a, b PublicKey) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	k, ok := a.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return false
+	}
+	return k.Equal(b.Public())
+}
+
+// ECDSAPublicKey verifies ES256 and ES384 signatures. Signatures are the
+// big-endian R and S values, each padded to the size of the curve.
+type ECDSAPublicKey struct {
+	Key *ecdsa.PublicKey
+}
+
+func (k *ECDSAPublicKey) Algorithm() Algorithm {
+	alg, _ := ecdsaAlgorithm(k.Key.Curve)
+	return alg
+}
+
+func (k *ECDSAPublicKey) Public() crypto.PublicKey {
+	return k.Key
+}
+
+func (k *ECDSAPublicKey) Verify(message, sig []byte) (bool, error) {
+	alg, err := ecdsaAlgorithm(k.Key.Curve)
+	if err != nil {
+		return false, err
+	}
+	keySize := (k.Key.Curve.Params().BitSize + 7) / 8
+	if len(sig) != 2*keySize {
+		return false, nil
+	}
+	r := new(big.Int).SetBytes(sig[:keySize])
+	s := new(big.Int).SetBytes(sig[keySize:])
+
+	digest, err := alg.digest(message)
+	if err != nil {
+		return false, err
+	}
+
+	return ecdsa.Verify(k.Key, digest, r, s), nil
+}
+
+// Ed25519PublicKey verifies EdDSA signatures.
+type Ed25519PublicKey struct {
+	Key ed25519.PublicKey
+}
+
+func (k Ed25519PublicKey) Algorithm() Algorithm {
+	return AlgorithmEdDSA
+}
+
+func (k Ed25519PublicKey) Public() crypto.PublicKey {
+	return k.Key
+}
+
+func (k Ed25519PublicKey) Verify(message, sig []byte) (bool, error) {
+	if len(k.Key) != ed25519.PublicKeySize {
+		return false, &ErrUnsupportedAlgorithm{Msg: "invalid Ed25519 public key size"}
+	}
+	return ed25519.Verify(k.Key, message, sig), nil
+}
+
+// RSAPSSPublicKey verifies PS256 signatures.
+type RSAPSSPublicKey struct {
+	Key *rsa.PublicKey
+}
+
+func (k *RSAPSSPublicKey) Algorithm() Algorithm {
+	return AlgorithmPS256
+}
+
+func (k *RSAPSSPublicKey) Public() crypto.PublicKey {
+	return k.Key
+}
+
+func (k *RSAPSSPublicKey) Verify(message, sig []byte) (bool, error) {
+	digest, err := AlgorithmPS256.digest(message)
+	if err != nil {
+		return false, err
+	}
+	err = rsa.VerifyPSS(k.Key, AlgorithmPS256.hash(), digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	return err == nil, nil
+}
+
+// VerifyECDSA verifies an ECDSA signature.
+// It first parses R and S coefficients from the signature.
+func VerifyECDSA(singingString, sig []byte, pubKey *ecdsa.PublicKey) (bool, error) {
+	return (&ECDSAPublicKey{Key: pubKey}).Verify(singingString, sig)
+}
@@ -0,0 +1,201 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/common-fate/attestations/types"
+)
+
+// PayloadFactory returns a new, empty payload which a serialized
+// payload can be unmarshalled into.
+type PayloadFactory func() Payload
+
+// ErrPayloadAlreadyRegistered is returned when registering a payload type
+// which has already been registered.
+type ErrPayloadAlreadyRegistered struct {
+	Type types.Payload
+}
+
+func (e *ErrPayloadAlreadyRegistered) Error() string {
+	return fmt.Sprintf("payload type %s is already registered", e.Type)
+}
+
+// ErrUnknownPayloadType is returned when deserializing a payload
+// whose type hasn't been registered.
+type ErrUnknownPayloadType struct {
+	Type types.Payload
+}
+
+func (e *ErrUnknownPayloadType) Error() string {
+	return fmt.Sprintf("unhandled payload type %s", e.Type)
+}
+
+// ErrLegacyPayloadType is returned when deserializing a payload whose type is a legacy
+// version, without legacy payloads having been accepted.
+type ErrLegacyPayloadType struct {
+	Type types.Payload
+}
+
+func (e *ErrLegacyPayloadType) Error() string {
+	return fmt.Sprintf("payload type %s is a legacy version which is only accepted when legacy payloads are enabled", e.Type)
+}
+
+// Upgrader is implemented by payloads which can be deserialized from earlier
+// versions of their payload type. Upgrade is called after a payload is
+// unmarshalled and should update the payload's type to the current version.
+// The envelope's signatures still cover the original serialized payload.
+type Upgrader interface {
+	Upgrade() error
+}
+
+// Registry maps payload type URIs to factories for the payloads.
+// It allows applications to add their own attestation types, which can
+// then be deserialized and verified in the same way as the built-in types.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[types.Payload]PayloadFactory
+	legacy    map[types.Payload]bool
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[types.Payload]PayloadFactory),
+		legacy:    make(map[types.Payload]bool),
+	}
+}
+
+// Register adds a payload type to the registry.
+func (r *Registry) Register(t types.Payload, factory PayloadFactory) error {
+	return r.register(t, factory, false)
+}
+
+// RegisterLegacy adds a legacy payload type to the registry. Legacy payloads are
+// upgraded when they are deserialized like any other earlier version, but are only
+// accepted if the caller opts in with DeserializeOptions.AcceptLegacy. A payload type
+// should be registered as legacy if its payloads could be signed without meeting
+// the rules which the current version enforces.
+func (r *Registry) RegisterLegacy(t types.Payload, factory PayloadFactory) error {
+	return r.register(t, factory, true)
+}
+
+// register adds a payload type to the registry while holding the lock, so that a legacy
+// payload type can't be deserialized before it has been marked as legacy.
+func (r *Registry) register(t types.Payload, factory PayloadFactory, legacy bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.factories[t]; ok {
+		return &ErrPayloadAlreadyRegistered{Type: t}
+	}
+	r.factories[t] = factory
+	if legacy {
+		r.legacy[t] = true
+	}
+	return nil
+}
+
+// DeserializeOptions control how payloads are deserialized.
+type DeserializeOptions struct {
+	// AcceptLegacy allows payloads with legacy payload types to be deserialized.
+	// This should only be enabled while migrating existing bundles.
+	AcceptLegacy bool
+}
+
+// Deserialize unmarshals a payload, checking that its type matches the expected type.
+// Legacy payload types aren't accepted.
+func (r *Registry) Deserialize(payload []byte, expected types.Payload) (Payload, error) {
+	return r.DeserializeWithOptions(payload, expected, DeserializeOptions{})
+}
+
+// DeserializeWithOptions is the same as Deserialize, but accepts legacy payload types if the options allow them.
+func (r *Registry) DeserializeWithOptions(payload []byte, expected types.Payload, opts DeserializeOptions) (Payload, error) {
+	var pt struct {
+		PayloadType types.Payload `json:"type"`
+	}
+
+	err := json.Unmarshal(payload, &pt)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	factory, ok := r.factories[pt.PayloadType]
+	legacy := r.legacy[pt.PayloadType]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, &ErrUnknownPayloadType{Type: pt.PayloadType}
+	}
+	if legacy && !opts.AcceptLegacy {
+		return nil, &ErrLegacyPayloadType{Type: pt.PayloadType}
+	}
+
+	p := factory()
+	err = json.Unmarshal(payload, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if u, ok := p.(Upgrader); ok {
+		err = u.Upgrade()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Type() != expected {
+		return nil, &ErrInvalidPayloadType{
+			Expected: expected,
+			Actual:   p.Type(),
+		}
+	}
+
+	return p, nil
+}
+
+// DefaultRegistry is the registry used by DeserializePayload.
+// It contains the built-in granted.dev payload types.
+var DefaultRegistry = NewRegistry()
+
+// RegisterPayload adds a payload type to the default registry.
+func RegisterPayload(t types.Payload, factory PayloadFactory) error {
+	return DefaultRegistry.Register(t, factory)
+}
+
+// MustRegisterPayload is like RegisterPayload but panics if the payload type
+// is already registered. It is intended to be called from init functions.
+func MustRegisterPayload(t types.Payload, factory PayloadFactory) {
+	err := RegisterPayload(t, factory)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MustRegisterLegacyPayload adds a legacy payload type to the default registry,
+// panicking if the payload type is already registered. See Registry.RegisterLegacy.
+func MustRegisterLegacyPayload(t types.Payload, factory PayloadFactory) {
+	err := DefaultRegistry.RegisterLegacy(t, factory)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func init() {
+	MustRegisterPayload(types.PayloadInit, func() Payload { return &InitPayload{} })
+	MustRegisterPayload(types.PayloadAuthenticated, func() Payload { return &AuthenticatedPayload{} })
+	MustRegisterPayload(types.PayloadAccessRequest, func() Payload { return &AccessRequestPayload{} })
+	// v0.1 access requests weren't limited in duration and didn't require a reason
+	MustRegisterLegacyPayload(types.PayloadAccessRequestV01, func() Payload { return &AccessRequestPayload{} })
+	MustRegisterPayload(types.PayloadDecision, func() Payload { return &DecisionPayload{} })
+	// v0.1 decisions didn't have an outcome, and denials didn't require a reason
+	MustRegisterLegacyPayload(types.PayloadDecisionV01, func() Payload { return &DecisionPayload{} })
+	MustRegisterPayload(types.PayloadGrantCreated, func() Payload { return &GrantCreatedPayload{} })
+	// v0.1 grants could only describe AWS grants, and their role ARNs weren't validated
+	MustRegisterLegacyPayload(types.PayloadGrantCreatedV01, func() Payload { return &GrantCreatedPayload{} })
+	MustRegisterPayload(types.PayloadApproval, func() Payload { return &ApprovalPayload{} })
+	MustRegisterPayload(types.PayloadGrantRevoked, func() Payload { return &GrantRevokedPayload{} })
+	MustRegisterPayload(types.PayloadGrantExtended, func() Payload { return &GrantExtendedPayload{} })
+	MustRegisterPayload(types.PayloadGrantExpired, func() Payload { return &GrantExpiredPayload{} })
+}
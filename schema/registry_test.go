@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/common-fate/attestations/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const payloadTicketLinked types.Payload = "example.com/TicketLinked/v1"
+
+// ticketLinkedPayload is an example of a payload defined outside of this module.
+type ticketLinkedPayload struct {
+	Link
+	TicketID    string        `json:"ticketId"`
+	PayloadType types.Payload `json:"type"`
+}
+
+func (m *ticketLinkedPayload) Type() types.Payload {
+	return m.PayloadType
+}
+
+func (m *ticketLinkedPayload) MarshalJSON() ([]byte, error) {
+	type payload ticketLinkedPayload
+	return json.Marshal(payload(*m))
+}
+
+func (m *ticketLinkedPayload) ValidateContents(f Facts) error {
+	if m.TicketID == "" {
+		return &ErrInvalidPayloadContents{Msg: "ticket ID must be provided"}
+	}
+	return nil
+}
+
+func TestRegistryDeserializesCustomPayload(t *testing.T) {
+	r := NewRegistry()
+	err := r.Register(payloadTicketLinked, func() Payload { return &ticketLinkedPayload{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serialized, err := json.Marshal(&ticketLinkedPayload{
+		TicketID:    "TICKET-123",
+		PayloadType: payloadTicketLinked,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := r.Deserialize(serialized, payloadTicketLinked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "TICKET-123", p.(*ticketLinkedPayload).TicketID)
+	assert.NoError(t, p.ValidateContents(Facts{}))
+
+	_, err = r.Deserialize(serialized, types.PayloadInit)
+	typeErr := &ErrInvalidPayloadType{}
+	assert.ErrorAs(t, err, &typeErr)
+}
+
+func TestRegistryRejectsUnknownAndDuplicateTypes(t *testing.T) {
+	r := NewRegistry()
+
+	_, err := r.Deserialize([]byte(`{"type":"example.com/TicketLinked/v1"}`), payloadTicketLinked)
+	unknownErr := &ErrUnknownPayloadType{}
+	assert.ErrorAs(t, err, &unknownErr)
+
+	factory := func() Payload { return &ticketLinkedPayload{} }
+	err = r.Register(payloadTicketLinked, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Register(payloadTicketLinked, factory)
+	dupErr := &ErrPayloadAlreadyRegistered{}
+	assert.ErrorAs(t, err, &dupErr)
+}
@@ -0,0 +1,233 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/common-fate/attestations/types"
+)
+
+// KeyRevocation records that a key was revoked, such as when the device
+// holding a user's private key is lost.
+type KeyRevocation struct {
+	// KeyID is the key ID of the revoked key, as returned by KeyID
+	KeyID     string    `json:"keyId"`
+	RevokedAt time.Time `json:"revokedAt"`
+	Reason    string    `json:"reason"`
+}
+
+// RevocationList is a list of revoked keys.
+type RevocationList interface {
+	// Revoked returns the revocation of the key with the given key ID,
+	// or false if the key hasn't been revoked.
+	Revoked(keyID string) (KeyRevocation, bool, error)
+}
+
+// ErrKeyRevoked is returned when a payload refers to a key which has been revoked.
+// Revoked keys are rejected regardless of when the bundle was signed, because
+// a bundle signed with a stolen key may claim to have been signed at any time.
+type ErrKeyRevoked struct {
+	KeyID     string
+	RevokedAt time.Time
+	Reason    string
+}
+
+func (e *ErrKeyRevoked) Error() string {
+	return fmt.Sprintf("key %s was revoked at %s: %s", e.KeyID, e.RevokedAt.Format(time.RFC3339), e.Reason)
+}
+
+// CheckRevocation returns ErrKeyRevoked if the key is in the revocation list.
+// A nil revocation list has no revoked keys.
+func CheckRevocation(l RevocationList, key PublicKey) error {
+	if l == nil {
+		return nil
+	}
+	keyID, err := KeyID(key)
+	if err != nil {
+		return err
+	}
+	r, revoked, err := l.Revoked(keyID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return &ErrKeyRevoked{KeyID: keyID, RevokedAt: r.RevokedAt, Reason: r.Reason}
+	}
+	return nil
+}
+
+// MemoryRevocationList is a revocation list held in memory.
+// It is safe for concurrent use.
+type MemoryRevocationList struct {
+	mu          sync.RWMutex
+	revocations map[string]KeyRevocation
+}
+
+// NewMemoryRevocationList returns a revocation list containing the revocations.
+func NewMemoryRevocationList(revocations ...KeyRevocation) *MemoryRevocationList {
+	l := &MemoryRevocationList{revocations: map[string]KeyRevocation{}}
+	for _, r := range revocations {
+		l.Revoke(r)
+	}
+	return l
+}
+
+// Revoke adds a revocation to the list. If the key is already revoked,
+// the earliest revocation is kept.
+func (l *MemoryRevocationList) Revoke(r KeyRevocation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if existing, ok := l.revocations[r.KeyID]; ok && existing.RevokedAt.Before(r.RevokedAt) {
+		return
+	}
+	l.revocations[r.KeyID] = r
+}
+
+func (l *MemoryRevocationList) Revoked(keyID string) (KeyRevocation, bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	r, ok := l.revocations[keyID]
+	return r, ok, nil
+}
+
+// Revocations returns each of the revocations in the list.
+func (l *MemoryRevocationList) Revocations() []KeyRevocation {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	revocations := []KeyRevocation{}
+	for _, r := range l.revocations {
+		revocations = append(revocations, r)
+	}
+	return revocations
+}
+
+// RevocationListPayload is the signed contents of a revocation list file.
+type RevocationListPayload struct {
+	PayloadType types.Payload   `json:"type"`
+	IssuedAt    time.Time       `json:"issuedAt"`
+	Revocations []KeyRevocation `json:"revocations"`
+}
+
+// SignRevocationList returns an envelope containing the revocations, signed by the
+// issuer of the revocation list. The envelope is the contents of a revocation list file.
+func SignRevocationList(ctx context.Context, issuer EnvelopeSigner, issuedAt time.Time, revocations []KeyRevocation) (Envelope, error) {
+	payload, err := json.Marshal(RevocationListPayload{
+		PayloadType: types.PayloadKeyRevocationList,
+		IssuedAt:    issuedAt,
+		Revocations: revocations,
+	})
+	if err != nil {
+		return Envelope{}, err
+	}
+	e := Envelope{
+		PayloadType: "application/granted+json",
+		Payload:     payload,
+	}
+	err = e.Sign(ctx, issuer)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return e, nil
+}
+
+// ParseRevocationList verifies that a revocation list envelope was signed by the issuer,
+// and returns the revocation list which it contains.
+func ParseRevocationList(e Envelope, issuer ExpectedSigner) (*RevocationListPayload, error) {
+	err := e.VerifySignatures([]ExpectedSigner{issuer})
+	if err != nil {
+		return nil, err
+	}
+	var p RevocationListPayload
+	err = json.Unmarshal(e.Payload, &p)
+	if err != nil {
+		return nil, err
+	}
+	if p.PayloadType != types.PayloadKeyRevocationList {
+		return nil, &ErrInvalidPayloadType{Expected: types.PayloadKeyRevocationList, Actual: p.PayloadType}
+	}
+	return &p, nil
+}
+
+// FileRevocationList is a revocation list which is loaded from a signed file,
+// so that it can be distributed to verifiers without them trusting the file's source.
+type FileRevocationList struct {
+	// Path is the path to the revocation list file
+	Path string
+	// Issuer is the signer which the revocation list file must be signed by
+	Issuer ExpectedSigner
+
+	mu       sync.RWMutex
+	list     *MemoryRevocationList
+	issuedAt time.Time
+}
+
+// NewFileRevocationList loads a revocation list from a signed file.
+func NewFileRevocationList(path string, issuer ExpectedSigner) (*FileRevocationList, error) {
+	l := &FileRevocationList{Path: path, Issuer: issuer}
+	err := l.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload reloads the revocation list from its file. A file which was issued before
+// the currently loaded list is rejected, so that revocations can't be rolled back
+// by replacing the file with an earlier version.
+func (l *FileRevocationList) Reload() error {
+	data, err := os.ReadFile(l.Path)
+	if err != nil {
+		return err
+	}
+	var e Envelope
+	err = json.Unmarshal(data, &e)
+	if err != nil {
+		return err
+	}
+	p, err := ParseRevocationList(e, l.Issuer)
+	if err != nil {
+		return fmt.Errorf("loading revocation list %s: %w", l.Path, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if p.IssuedAt.Before(l.issuedAt) {
+		return fmt.Errorf("revocation list %s was issued at %s, before the loaded list which was issued at %s",
+			l.Path, p.IssuedAt.Format(time.RFC3339), l.issuedAt.Format(time.RFC3339))
+	}
+	l.list = NewMemoryRevocationList(p.Revocations...)
+	l.issuedAt = p.IssuedAt
+	return nil
+}
+
+func (l *FileRevocationList) Revoked(keyID string) (KeyRevocation, bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.list == nil {
+		return KeyRevocation{}, false, fmt.Errorf("revocation list %s has not been loaded", l.Path)
+	}
+	return l.list.Revoked(keyID)
+}
+
+// Revocations returns each of the revocations in the loaded revocation list.
+func (l *FileRevocationList) Revocations() []KeyRevocation {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.list == nil {
+		return []KeyRevocation{}
+	}
+	return l.list.Revocations()
+}
+
+// WriteRevocationListFile writes a signed revocation list envelope to a file.
+func WriteRevocationListFile(path string, e Envelope) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
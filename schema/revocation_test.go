@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryRevocationList(t *testing.T) {
+	key := mustGeneratePublicKey(t, AlgorithmES256)
+	keyID, err := KeyID(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	l := NewMemoryRevocationList()
+	assert.NoError(t, CheckRevocation(l, key))
+
+	l.Revoke(KeyRevocation{KeyID: keyID, RevokedAt: revokedAt, Reason: "laptop lost"})
+	// a later revocation of the same key doesn't change when it was revoked
+	l.Revoke(KeyRevocation{KeyID: keyID, RevokedAt: revokedAt.Add(time.Hour), Reason: "duplicate"})
+
+	err = CheckRevocation(l, key)
+	assert.Equal(t, &ErrKeyRevoked{KeyID: keyID, RevokedAt: revokedAt, Reason: "laptop lost"}, err)
+	assert.EqualError(t, err, "key "+keyID+" was revoked at 2022-01-01T00:00:00Z: laptop lost")
+}
+
+func TestFileRevocationList(t *testing.T) {
+	ctx := context.Background()
+	priv, err := GenerateKey(AlgorithmES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &LocalSigner{PrivateKey: priv}
+	issuerKey, err := issuer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedIssuer := ExpectedSigner{Name: "revocationIssuer", PublicKey: issuerKey}
+
+	key := mustGeneratePublicKey(t, AlgorithmES256)
+	keyID, err := KeyID(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuedAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "revocations.json")
+
+	e, err := SignRevocationList(ctx, issuer, issuedAt, []KeyRevocation{{KeyID: keyID, RevokedAt: issuedAt, Reason: "laptop lost"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteRevocationListFile(path, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := NewFileRevocationList(path, expectedIssuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = CheckRevocation(l, key)
+	assert.ErrorAs(t, err, new(*ErrKeyRevoked))
+
+	// an earlier revocation list can't replace the loaded one
+	earlier, err := SignRevocationList(ctx, issuer, issuedAt.Add(-time.Hour), []KeyRevocation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteRevocationListFile(path, earlier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Reload()
+	assert.Error(t, err)
+	assert.ErrorAs(t, CheckRevocation(l, key), new(*ErrKeyRevoked))
+
+	// revocation lists must be signed by the issuer
+	_, err = NewFileRevocationList(path, ExpectedSigner{Name: "revocationIssuer", PublicKey: key})
+	assert.ErrorAs(t, err, new(*ErrMissingSignatures))
+}
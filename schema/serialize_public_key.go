@@ -4,6 +4,9 @@ import (
 	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
 )
 
 func SerializeECDSAPublicKey(key ecdsa.PublicKey) (string, error) {
@@ -14,3 +17,34 @@ func SerializeECDSAPublicKey(key ecdsa.PublicKey) (string, error) {
 	encoded := base64.StdEncoding.EncodeToString(bytes)
 	return encoded, nil
 }
+
+// SerializePublicKey encodes a public key as base64 PKIX, ASN.1 DER form.
+func SerializePublicKey(key PublicKey) (string, error) {
+	bytes, err := MarshalPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.StdEncoding.EncodeToString(bytes)
+	return encoded, nil
+}
+
+// MarshalPublicKeyPEM encodes a public key in PEM form.
+func MarshalPublicKeyPEM(key PublicKey) ([]byte, error) {
+	der, err := MarshalPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePublicKeyPEM parses a PEM-encoded PKIX public key.
+func ParsePublicKeyPEM(data []byte) (PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("expected a PUBLIC KEY PEM block, but got %s", block.Type)
+	}
+	return ParsePublicKey(block.Bytes)
+}
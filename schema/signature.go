@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Signature is a signature over an envelope, in the same form as
+// a DSSE signature. KeyID identifies the key that made the signature
+// so that verifiers can find the signature for an expected signer
+// without trying every signature in the envelope.
+type Signature struct {
+	KeyID     string    `json:"keyid"`
+	Algorithm Algorithm `json:"alg"`
+	Sig       []byte    `json:"sig"`
+	// Certificates, if set, is the DER-encoded X.509 certificate chain for the signing key,
+	// starting with the key's certificate. See CertificateChainSigner.
+	Certificates [][]byte `json:"certificates,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. In addition to signature objects,
+// it accepts the bare base64 strings which signatures were serialised as prior
+// to key IDs being introduced. Legacy signatures have an empty KeyID.
+func (s *Signature) UnmarshalJSON(data []byte) error {
+	var legacy []byte
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		*s = Signature{Algorithm: AlgorithmES256, Sig: legacy}
+		return nil
+	}
+
+	// use an alias type to avoid recursing into this method
+	type signature Signature
+	var sig signature
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return err
+	}
+	*s = Signature(sig)
+	return nil
+}
+
+// KeyID returns the identifier for a public key, which is the hex-encoded
+// SHA-256 digest of the key's DER-encoded SubjectPublicKeyInfo. Signatures may
+// also identify their key by its JWK thumbprint, as returned by JWKThumbprint.
+func KeyID(key PublicKey) (string, error) {
+	der, err := MarshalPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(der)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// ExpectedSigner is a named actor which is expected to have signed an envelope.
+type ExpectedSigner struct {
+	// Name of the actor, such as "user" or "identityServer"
+	Name      string
+	PublicKey PublicKey
+}
+
+// KeyID returns the key ID of the signer's public key.
+func (s ExpectedSigner) KeyID() (string, error) {
+	return KeyID(s.PublicKey)
+}
+
+// KeyIDs returns each of the identifiers which a signature made by the signer may use
+// to identify its key: the key ID returned by KeyID, and the key's JWK thumbprint.
+func (s ExpectedSigner) KeyIDs() ([]string, error) {
+	keyID, err := s.KeyID()
+	if err != nil {
+		return nil, err
+	}
+	thumbprint, err := JWKThumbprint(s.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return []string{keyID, thumbprint}, nil
+}
@@ -4,40 +4,84 @@ import (
 	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
-	"errors"
+	"crypto/rsa"
+	"fmt"
 )
 
 // EnvelopeSigner signs payloads with a cryptographic signature
 type EnvelopeSigner interface {
 	// Sign a byte array payload. Returns the signature if successful
 	Sign(ctx context.Context, payload []byte) ([]byte, error)
+	// KeyID returns the identifier of the signing key, as returned by KeyID
+	KeyID() (string, error)
+	// Algorithm returns the algorithm used to produce signatures
+	Algorithm() (Algorithm, error)
 }
 
+// LocalSigner signs payloads with a private key held in memory.
+// The private key must be an *ecdsa.PrivateKey on the P-256 or P-384 curve,
+// an ed25519.PrivateKey, or an *rsa.PrivateKey.
 type LocalSigner struct {
-	PrivateKey *ecdsa.PrivateKey
+	PrivateKey crypto.Signer
+}
+
+// PublicKey returns the public key corresponding to the signer's private key.
+func (l *LocalSigner) PublicKey() (PublicKey, error) {
+	return NewPublicKey(l.PrivateKey.Public())
+}
+
+func (l *LocalSigner) KeyID() (string, error) {
+	pub, err := l.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	return KeyID(pub)
+}
+
+func (l *LocalSigner) Algorithm() (Algorithm, error) {
+	pub, err := l.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	return pub.Algorithm(), nil
 }
 
 func (l *LocalSigner) Sign(ctx context.Context, payload []byte) ([]byte, error) {
-	hash := crypto.SHA256
-	hasher := hash.New()
-	_, err := hasher.Write(payload)
+	alg, err := l.Algorithm()
 	if err != nil {
 		return nil, err
 	}
-	hashedSigningString := hasher.Sum(nil)
 
-	r, s, err := ecdsa.Sign(rand.Reader, l.PrivateKey, hashedSigningString)
+	switch key := l.PrivateKey.(type) {
+	case *ecdsa.PrivateKey:
+		return signECDSA(key, alg, payload)
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, payload), nil
+	case *rsa.PrivateKey:
+		digest, err := alg.digest(payload)
+		if err != nil {
+			return nil, err
+		}
+		return rsa.SignPSS(rand.Reader, key, alg.hash(), digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	default:
+		return nil, &ErrUnsupportedAlgorithm{Msg: fmt.Sprintf("private key type %T", l.PrivateKey)}
+	}
+}
+
+func signECDSA(key *ecdsa.PrivateKey, alg Algorithm, payload []byte) ([]byte, error) {
+	hashedSigningString, err := alg.digest(payload)
 	if err != nil {
 		return nil, err
 	}
 
-	curveBits := l.PrivateKey.Curve.Params().BitSize
-	expectedCurveBits := 256
-	if expectedCurveBits != curveBits {
-		return nil, errors.New("invalid curve bits on signing key")
+	r, s, err := ecdsa.Sign(rand.Reader, key, hashedSigningString)
+	if err != nil {
+		return nil, err
 	}
 
+	curveBits := key.Curve.Params().BitSize
 	keyBytes := curveBits / 8
 	if curveBits%8 > 0 {
 		keyBytes += 1
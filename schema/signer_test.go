@@ -0,0 +1,81 @@
+package schema
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalSignerAlgorithms(t *testing.T) {
+	ctx := context.Background()
+	algs := []Algorithm{AlgorithmES256, AlgorithmES384, AlgorithmEdDSA, AlgorithmPS256}
+
+	for _, alg := range algs {
+		t.Run(string(alg), func(t *testing.T) {
+			priv, err := GenerateKey(alg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signer := &LocalSigner{PrivateKey: priv}
+			pub, err := signer.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, alg, pub.Algorithm())
+
+			e := Envelope{
+				PayloadType: "application/granted+json",
+				Payload:     []byte("test"),
+			}
+			err = e.Sign(ctx, signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, alg, e.Signatures[0].Algorithm)
+
+			err = e.VerifySignatures([]ExpectedSigner{{Name: "test", PublicKey: pub}})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewPublicKeyRejectsUnsupportedCurve(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewPublicKey(&priv.PublicKey)
+	target := &ErrUnsupportedAlgorithm{}
+	assert.ErrorAs(t, err, &target)
+}
+
+// A signature must only verify with the algorithm it was recorded with.
+func TestVerifySignaturesRejectsAlgorithmMismatch(t *testing.T) {
+	ctx := context.Background()
+	priv, err := GenerateKey(AlgorithmES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &LocalSigner{PrivateKey: priv}
+	pub, err := signer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := Envelope{
+		Payload: []byte("test"),
+	}
+	err = e.Sign(ctx, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Signatures[0].Algorithm = AlgorithmES384
+
+	err = e.VerifySignatures([]ExpectedSigner{{Name: "test", PublicKey: pub}})
+	target := &ErrMissingSignatures{}
+	assert.ErrorAs(t, err, &target)
+}
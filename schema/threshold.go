@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"fmt"
+)
+
+// ThresholdPolicy requires an envelope to be signed by at least
+// Threshold of the Candidates, such as two of a group of five approvers.
+type ThresholdPolicy struct {
+	Candidates []ExpectedSigner
+	Threshold  int
+}
+
+// ThresholdResult reports which of the candidate signers
+// have signed an envelope.
+type ThresholdResult struct {
+	Signed []ExpectedSigner
+	// Unsigned are candidates with a missing or invalid signature
+	Unsigned []ExpectedSigner
+}
+
+// ErrThresholdNotMet is returned when fewer than the threshold of
+// candidate signers have signed an envelope.
+type ErrThresholdNotMet struct {
+	Threshold int
+	Result    ThresholdResult
+}
+
+func (e *ErrThresholdNotMet) Error() string {
+	return fmt.Sprintf("%d of %d required signatures were present", len(e.Result.Signed), e.Threshold)
+}
+
+// VerifyThreshold verifies that the envelope has been signed by at least the threshold
+// of candidate signers. Signatures must be over the DSSE pre-authentication encoding of the envelope.
+func (e *Envelope) VerifyThreshold(policy ThresholdPolicy) (*ThresholdResult, error) {
+	return e.VerifyThresholdWithVersions(policy, SigningVersionDSSEv1)
+}
+
+// VerifyThresholdWithVersions verifies that the envelope has been signed by at least the threshold
+// of candidate signers, using any of the accepted signing versions. The result reports exactly which
+// candidates signed, and is returned along with ErrThresholdNotMet if the threshold isn't met.
+// Candidates sharing the same key or the same name are only counted once, so that an
+// actor with several keys can be a candidate for each of them.
+func (e *Envelope) VerifyThresholdWithVersions(policy ThresholdPolicy, versions ...SigningVersion) (*ThresholdResult, error) {
+	if policy.Threshold < 1 || policy.Threshold > len(policy.Candidates) {
+		return nil, fmt.Errorf("invalid threshold policy: threshold %d for %d candidates", policy.Threshold, len(policy.Candidates))
+	}
+
+	signingStrings, err := e.signingStrings(versions)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ThresholdResult{}
+	counted := map[string]bool{}
+	signedNames := map[string]bool{}
+	var unsigned []ExpectedSigner
+
+	for _, signer := range policy.Candidates {
+		status, err := e.verifySigner(signer, signingStrings)
+		if err != nil {
+			return nil, err
+		}
+		if status != SignatureValid {
+			unsigned = append(unsigned, signer)
+			continue
+		}
+		keyID, err := signer.KeyID()
+		if err != nil {
+			return nil, err
+		}
+		if counted[keyID] || signedNames[signer.Name] {
+			continue
+		}
+		counted[keyID] = true
+		signedNames[signer.Name] = true
+		result.Signed = append(result.Signed, signer)
+	}
+
+	// a candidate with several keys has signed if it signed with any one of them
+	for _, signer := range unsigned {
+		if !signedNames[signer.Name] {
+			result.Unsigned = append(result.Unsigned, signer)
+		}
+	}
+
+	if len(result.Signed) < policy.Threshold {
+		return &result, &ErrThresholdNotMet{
+			Threshold: policy.Threshold,
+			Result:    result,
+		}
+	}
+
+	return &result, nil
+}
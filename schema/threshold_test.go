@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyThreshold(t *testing.T) {
+	ctx := context.Background()
+
+	var candidates []ExpectedSigner
+	var signers []*LocalSigner
+	for i := 0; i < 5; i++ {
+		priv, err := GenerateKey(AlgorithmES256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer := &LocalSigner{PrivateKey: priv}
+		pub, err := signer.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers = append(signers, signer)
+		candidates = append(candidates, ExpectedSigner{Name: fmt.Sprintf("approver-%d", i), PublicKey: pub})
+	}
+	policy := ThresholdPolicy{Candidates: candidates, Threshold: 2}
+
+	e := Envelope{
+		Payload: []byte("test"),
+	}
+	err := e.Sign(ctx, signers[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := e.VerifyThreshold(policy)
+	target := &ErrThresholdNotMet{}
+	assert.ErrorAs(t, err, &target)
+	assert.Equal(t, []ExpectedSigner{candidates[1]}, result.Signed)
+
+	err = e.Sign(ctx, signers[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = e.VerifyThreshold(policy)
+	assert.NoError(t, err)
+	assert.Equal(t, []ExpectedSigner{candidates[1], candidates[3]}, result.Signed)
+	assert.Equal(t, []ExpectedSigner{candidates[0], candidates[2], candidates[4]}, result.Unsigned)
+}
+
+// The same key listed twice as a candidate must only count once towards the threshold.
+func TestVerifyThresholdCountsKeysOnce(t *testing.T) {
+	ctx := context.Background()
+	priv, err := GenerateKey(AlgorithmES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &LocalSigner{PrivateKey: priv}
+	pub, err := signer.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := Envelope{
+		Payload: []byte("test"),
+	}
+	err = e.Sign(ctx, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = e.VerifyThreshold(ThresholdPolicy{
+		Candidates: []ExpectedSigner{{Name: "a", PublicKey: pub}, {Name: "b", PublicKey: pub}},
+		Threshold:  2,
+	})
+	target := &ErrThresholdNotMet{}
+	assert.ErrorAs(t, err, &target)
+}
@@ -0,0 +1,185 @@
+package schema
+
+import (
+	"fmt"
+	"time"
+)
+
+// TrustedKey is a public key which an actor signs with during a validity window.
+type TrustedKey struct {
+	PublicKey PublicKey
+	// NotBefore, if set, is the time from which the key is valid
+	NotBefore time.Time
+	// NotAfter, if set, is the time after which the key is no longer valid
+	NotAfter time.Time
+	// Revoked keys aren't valid at any time. Keys which may have been compromised
+	// must be revoked rather than expired, because the time which a signature is
+	// checked at is attested to by the bundle being verified.
+	Revoked bool
+}
+
+// ValidAt reports whether the key was valid at t.
+func (k TrustedKey) ValidAt(t time.Time) bool {
+	if k.Revoked {
+		return false
+	}
+	if !k.NotBefore.IsZero() && t.Before(k.NotBefore) {
+		return false
+	}
+	if !k.NotAfter.IsZero() && t.After(k.NotAfter) {
+		return false
+	}
+	return true
+}
+
+// TrustStore holds the keys which each actor signs with over time, so that keys can be
+// rotated while bundles signed with earlier keys can still be verified.
+// Actors are identified by their signer names, such as "user", "identityServer:eu" or "approver:bob".
+// An actor which has no keys in the trust store is verified using its key in the Facts.
+type TrustStore struct {
+	Keys map[string][]TrustedKey
+}
+
+// NewTrustStore returns an empty trust store.
+func NewTrustStore() *TrustStore {
+	return &TrustStore{Keys: map[string][]TrustedKey{}}
+}
+
+// Add adds a key for an actor.
+func (s *TrustStore) Add(actor string, k TrustedKey) {
+	if s.Keys == nil {
+		s.Keys = map[string][]TrustedKey{}
+	}
+	s.Keys[actor] = append(s.Keys[actor], k)
+}
+
+// Revoke revokes an actor's key. It returns false if the key isn't in the trust store.
+func (s *TrustStore) Revoke(actor string, key PublicKey) bool {
+	var found bool
+	for i, k := range s.Keys[actor] {
+		if PublicKeysEqual(k.PublicKey, key) {
+			s.Keys[actor][i].Revoked = true
+			found = true
+		}
+	}
+	return found
+}
+
+// Has reports whether the trust store holds any keys for an actor.
+func (s *TrustStore) Has(actor string) bool {
+	return s != nil && len(s.Keys[actor]) > 0
+}
+
+// KeysAt returns the actor's keys which were valid at t.
+func (s *TrustStore) KeysAt(actor string, t time.Time) []PublicKey {
+	if s == nil {
+		return nil
+	}
+	var keys []PublicKey
+	for _, k := range s.Keys[actor] {
+		if k.ValidAt(t) {
+			keys = append(keys, k.PublicKey)
+		}
+	}
+	return keys
+}
+
+// SignersAt returns the signers which the expected signer may have signed an envelope as at t.
+// If the trust store holds keys for the signer, each of its keys which were valid at t is returned,
+// and otherwise the signer is returned unchanged.
+func (s *TrustStore) SignersAt(signer ExpectedSigner, t time.Time) ([]ExpectedSigner, error) {
+	if !s.Has(signer.Name) {
+		return []ExpectedSigner{signer}, nil
+	}
+	if t.IsZero() {
+		return nil, fmt.Errorf("the time which %s signed at is unknown, so its trusted keys can't be found", signer.Name)
+	}
+	keys := s.KeysAt(signer.Name, t)
+	if len(keys) == 0 {
+		return nil, &ErrNoValidKeys{Actor: signer.Name, At: t}
+	}
+	var signers []ExpectedSigner
+	for _, key := range keys {
+		signers = append(signers, ExpectedSigner{Name: signer.Name, PublicKey: key})
+	}
+	return signers, nil
+}
+
+// ErrNoValidKeys is returned when none of an actor's keys in the trust store
+// were valid at the time which an envelope was signed at.
+type ErrNoValidKeys struct {
+	Actor string
+	At    time.Time
+}
+
+func (e *ErrNoValidKeys) Error() string {
+	return fmt.Sprintf("%s has no trusted keys which were valid at %s", e.Actor, e.At.Format(time.RFC3339))
+}
+
+// Attested is implemented by payloads which attest to the time that they were signed at.
+type Attested interface {
+	AttestedAt() time.Time
+}
+
+// AttestedAt returns the time attested to by the last of the payloads which attests to a time.
+// Payloads which don't attest to a time are taken to have been signed at the time of the payload
+// before them, for example an access request is signed at the time the user authenticated.
+//
+// Payloads which were recorded before they attested to a time, such as v0.1 decisions, have a
+// zero attested time. The time they were signed at is unknown, so false is returned for them
+// rather than the time of an earlier payload, which may have been signed with other keys.
+func AttestedAt(payloads ...Payload) (time.Time, bool) {
+	for i := len(payloads) - 1; i >= 0; i-- {
+		a, ok := payloads[i].(Attested)
+		if !ok {
+			continue
+		}
+		t := a.AttestedAt()
+		return t, !t.IsZero()
+	}
+	return time.Time{}, false
+}
+
+// AttestedAt returns the time that the INIT envelope was created.
+// INIT envelopes created before the time was recorded attest to no time.
+func (m *InitPayload) AttestedAt() time.Time {
+	if m.Time == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, m.Time)
+}
+
+// AttestedAt returns the time that the user authenticated.
+func (m *AuthenticatedPayload) AttestedAt() time.Time {
+	return m.AuthenticatedAt()
+}
+
+// AttestedAt returns the time that the decision was made.
+func (m *DecisionPayload) AttestedAt() time.Time {
+	return m.Decision.DecidedAt
+}
+
+// AttestedAt returns the time that the approver approved the request.
+func (m *ApprovalPayload) AttestedAt() time.Time {
+	return m.Approval.ApprovedAt
+}
+
+// AttestedAt returns the time that the grant was created.
+func (m *GrantCreatedPayload) AttestedAt() time.Time {
+	return m.Grant.CreatedAt
+}
+
+// AttestedAt returns the time that the grant was extended.
+func (m *GrantExtendedPayload) AttestedAt() time.Time {
+	return m.Extension.ExtendedAt
+}
+
+// AttestedAt returns the time that the grant was revoked.
+func (m *GrantRevokedPayload) AttestedAt() time.Time {
+	return m.Revocation.RevokedAt
+}
+
+// AttestedAt returns the time that the grant expired.
+func (m *GrantExpiredPayload) AttestedAt() time.Time {
+	return m.Expiry.ExpiredAt
+}
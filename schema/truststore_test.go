@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedKeyValidAt(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 3, 0)
+	k := TrustedKey{NotBefore: start, NotAfter: end}
+
+	assert.False(t, k.ValidAt(start.Add(-time.Second)))
+	assert.True(t, k.ValidAt(start))
+	assert.True(t, k.ValidAt(end))
+	assert.False(t, k.ValidAt(end.Add(time.Second)))
+
+	k.Revoked = true
+	assert.False(t, k.ValidAt(start))
+}
+
+func TestTrustStoreSignersAt(t *testing.T) {
+	old := mustGeneratePublicKey(t, AlgorithmES256)
+	current := mustGeneratePublicKey(t, AlgorithmES256)
+	rotatedAt := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	store := NewTrustStore()
+	store.Add(ActorIdentityServer, TrustedKey{PublicKey: old, NotAfter: rotatedAt})
+	store.Add(ActorIdentityServer, TrustedKey{PublicKey: current, NotBefore: rotatedAt})
+
+	server := IdentityServer{}
+	signers, err := store.SignersAt(server.Signer(), rotatedAt.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []ExpectedSigner{{Name: ActorIdentityServer, PublicKey: old}}, signers)
+
+	// both keys are valid at the moment of rotation
+	signers, err = store.SignersAt(server.Signer(), rotatedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, signers, 2)
+
+	// actors without keys in the trust store use their key in the facts
+	user := User{ID: "alice", PublicKey: old}
+	signers, err = store.SignersAt(user.Signer(), rotatedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []ExpectedSigner{user.Signer()}, signers)
+
+	assert.True(t, store.Revoke(ActorIdentityServer, old))
+	_, err = store.SignersAt(server.Signer(), rotatedAt.Add(-time.Hour))
+	assert.EqualError(t, err, "identityServer has no trusted keys which were valid at 2022-03-31T23:00:00Z")
+}
+
+func TestAttestedAt(t *testing.T) {
+	loginTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	revokedAt := loginTime.Add(time.Hour)
+
+	payloads := []Payload{
+		NewInitMessage(nil, "nonce", loginTime.Add(-time.Minute)),
+		NewAuthenticatedMessage(AuthMessageOpts{Time: loginTime}),
+		NewAccessRequestMessage(AccessRequest{Role: "test"}),
+	}
+	at, ok := AttestedAt(payloads...)
+	assert.True(t, ok)
+	assert.True(t, loginTime.Equal(at))
+
+	payloads = append(payloads, NewGrantRevokedPayload(GrantRevocation{RevokedAt: revokedAt}))
+	at, ok = AttestedAt(payloads...)
+	assert.True(t, ok)
+	assert.True(t, revokedAt.Equal(at))
+
+	_, ok = AttestedAt()
+	assert.False(t, ok)
+
+	// a decision which doesn't attest to a time wasn't necessarily signed at the
+	// time of the payloads before it
+	payloads = append(payloads[:3], NewDecisionPayload(Decision{Outcome: DecisionAllow}))
+	_, ok = AttestedAt(payloads...)
+	assert.False(t, ok)
+
+	decidedAt := loginTime.Add(time.Minute)
+	payloads[3] = NewDecisionPayload(Decision{Outcome: DecisionAllow, DecidedAt: decidedAt})
+	at, ok = AttestedAt(payloads...)
+	assert.True(t, ok)
+	assert.True(t, decidedAt.Equal(at))
+}
@@ -12,7 +12,7 @@ func (a *ServerActor) Authenticate(ctx context.Context, initEnv schema.Envelope,
 		return nil, err
 	}
 	authMsg := schema.NewAuthenticatedMessage(opts)
-	authEnvelope, err := schema.EnvelopeFromPayload(authMsg)
+	authEnvelope, err := schema.NewChainedEnvelope(schema.Bundle{initEnv}, authMsg)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package serveractions
 
 import (
 	"context"
+	"time"
 
 	"github.com/common-fate/attestations/schema"
 )
@@ -12,11 +13,14 @@ func (a *ServerActor) CreateGrant(ctx context.Context, b schema.Bundle, g schema
 	// 2 - AUTHENTICATED
 	// 3 - ACCESS_REQUEST
 	// 4 - DECISION
-	// ... TODO: handle case where additional APPROVAL envelope may be here
-	// 5 - CREATE_GRANT
+	// 5 - APPROVAL (only if the decision required approval)
+	// 5 or 6 - CREATE_GRANT
 
+	if g.CreatedAt.IsZero() {
+		g.CreatedAt = time.Now()
+	}
 	payload := schema.NewGrantCreatedPayload(g)
-	env, err := schema.EnvelopeFromPayload(payload)
+	env, err := schema.NewChainedEnvelope(b, payload)
 	if err != nil {
 		return nil, err
 	}
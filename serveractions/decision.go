@@ -2,16 +2,69 @@ package serveractions
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/common-fate/attestations/policy"
 	"github.com/common-fate/attestations/schema"
 	"github.com/common-fate/attestations/types"
 )
 
+// ErrNoPolicy is returned by Decide when the server actor wasn't created with a policy.
+var ErrNoPolicy = errors.New("server actor does not have a policy")
+
+// ErrHasPolicy is returned by Decision when the server actor was created with a policy,
+// so that decisions can't be signed without evaluating it.
+var ErrHasPolicy = errors.New("server actor has a policy, so decisions must be made with Decide")
+
+// Decide verifies the bundle, evaluates the server's policy against it, and signs the
+// decision which the policy made. The policy's ID and digest are recorded in the decision.
+func (a *ServerActor) Decide(ctx context.Context, f schema.Facts, b schema.Bundle) (schema.Bundle, error) {
+	if a.policy == nil || a.verifier == nil {
+		return nil, ErrNoPolicy
+	}
+
+	err := a.verifier.Verify(f, b)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := policy.NewInput(f, b)
+	if err != nil {
+		return nil, err
+	}
+	d, err := a.policy.Evaluate(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	d.PolicyID = a.policy.ID()
+	d.PolicyDigest = a.policy.Digest()
+
+	return a.signDecision(ctx, b, d)
+}
+
+// Decision signs the access request and the decision which the caller made.
+//
+// Decision is unsafe: the bundle isn't verified and the decision is signed as given,
+// so the caller alone is responsible for deciding whether access should be allowed.
+// Prefer NewWithPolicy and Decide, which verify the bundle and sign the decision made
+// by the policy. Server actors created with NewWithPolicy return ErrHasPolicy.
 func (a *ServerActor) Decision(ctx context.Context, b schema.Bundle, d schema.Decision) (schema.Bundle, error) {
+	if a.policy != nil {
+		return nil, ErrHasPolicy
+	}
+	return a.signDecision(ctx, b, d)
+}
+
+// signDecision signs the access request and the decision.
+func (a *ServerActor) signDecision(ctx context.Context, b schema.Bundle, d schema.Decision) (schema.Bundle, error) {
 	// the signature bundle should be as follows
 	// 1 - INIT
 	// 2 - AUTHENTICATED
 	// 3 - ACCESS_REQUEST
+	if len(b) != 3 {
+		return nil, errors.New("bundle did not contain 3 envelopes")
+	}
 
 	requestEnv := b[2]
 	// double check that we're signing an access request payload
@@ -25,8 +78,11 @@ func (a *ServerActor) Decision(ctx context.Context, b schema.Bundle, d schema.De
 		return nil, err
 	}
 
+	if d.DecidedAt.IsZero() {
+		d.DecidedAt = time.Now()
+	}
 	decisionPayload := schema.NewDecisionPayload(d)
-	decisionEnv, err := schema.EnvelopeFromPayload(decisionPayload)
+	decisionEnv, err := schema.NewChainedEnvelope(b, decisionPayload)
 	if err != nil {
 		return nil, err
 	}
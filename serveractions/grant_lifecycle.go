@@ -0,0 +1,114 @@
+package serveractions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+)
+
+// RevokeGrant appends a record that the grant in the bundle was revoked.
+// The bundle must end with the grant or an earlier lifecycle record.
+func (a *ServerActor) RevokeGrant(ctx context.Context, b schema.Bundle, r schema.GrantRevocation) (schema.Bundle, error) {
+	return a.appendLifecycle(ctx, b, schema.NewGrantRevokedPayload(r))
+}
+
+// ExtendGrant appends a record that the expiry of the grant in the bundle was extended.
+// The bundle must end with the grant or an earlier lifecycle record. If the extension
+// doesn't specify when it was made, the current time is used.
+func (a *ServerActor) ExtendGrant(ctx context.Context, b schema.Bundle, e schema.GrantExtension) (schema.Bundle, error) {
+	if e.ExtendedAt.IsZero() {
+		e.ExtendedAt = time.Now()
+	}
+	return a.appendLifecycle(ctx, b, schema.NewGrantExtendedPayload(e))
+}
+
+// ExpireGrant appends a record that the grant in the bundle was observed to have expired.
+// The bundle must end with the grant or an earlier lifecycle record.
+func (a *ServerActor) ExpireGrant(ctx context.Context, b schema.Bundle, e schema.GrantExpiry) (schema.Bundle, error) {
+	return a.appendLifecycle(ctx, b, schema.NewGrantExpiredPayload(e))
+}
+
+// appendLifecycle checks that the lifecycle payload can be applied to the grant at the
+// end of the bundle, such as that an extension doesn't exceed the maximum grant duration,
+// and then signs and appends it.
+func (a *ServerActor) appendLifecycle(ctx context.Context, b schema.Bundle, payload schema.Payload) (schema.Bundle, error) {
+	previous, err := grantPayloads(b)
+	if err != nil {
+		return nil, err
+	}
+	err = payload.ValidateContents(schema.Facts{})
+	if err != nil {
+		return nil, err
+	}
+	err = payload.(schema.BundleValidator).ValidateBundle(schema.Facts{}, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := schema.NewChainedEnvelope(b, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.Sign(ctx, a.signer)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := append(b, env)
+	return bundle, nil
+}
+
+// grantPayloads returns the AUTHENTICATED payload, which the grant may start from, followed
+// by the grant and the lifecycle records after it. The bundle must end with the grant or a
+// lifecycle record.
+func grantPayloads(b schema.Bundle) ([]schema.Payload, error) {
+	if len(b) < 2 {
+		return nil, errors.New("bundle did not contain a grant")
+	}
+	auth, err := schema.DeserializePayload(b[1].Payload, types.PayloadAuthenticated)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []schema.Payload
+	for i := len(b) - 1; i > 1; i-- {
+		t, err := payloadType(b[i])
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case types.PayloadGrantRevoked, types.PayloadGrantExtended, types.PayloadGrantExpired:
+			p, err := schema.DeserializePayload(b[i].Payload, t)
+			if err != nil {
+				return nil, err
+			}
+			records = append([]schema.Payload{p}, records...)
+		case types.PayloadGrantCreated, types.PayloadGrantCreatedV01:
+			// grants created before v0.2 can still be revoked or extended. Whether the
+			// bundle is accepted is decided by the verifier's legacy payload options.
+			p, err := schema.DeserializePayloadWithOptions(b[i].Payload, types.PayloadGrantCreated, schema.DeserializeOptions{AcceptLegacy: true})
+			if err != nil {
+				return nil, err
+			}
+			return append([]schema.Payload{auth, p}, records...), nil
+		default:
+			return nil, fmt.Errorf("bundle must end with a grant or a grant lifecycle record, but contained a %s envelope", t)
+		}
+	}
+	return nil, errors.New("bundle did not contain a grant")
+}
+
+// payloadType returns the type of the payload in an envelope.
+func payloadType(e schema.Envelope) (types.Payload, error) {
+	var p struct {
+		PayloadType types.Payload `json:"type"`
+	}
+	err := json.Unmarshal(e.Payload, &p)
+	return p.PayloadType, err
+}
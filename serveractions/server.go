@@ -1,11 +1,21 @@
 package serveractions
 
 import (
+	"github.com/common-fate/attestations/policy"
 	"github.com/common-fate/attestations/schema"
 )
 
+// BundleVerifier verifies a bundle against the facts,
+// such as verification.AccessRequestStageSpec.
+type BundleVerifier interface {
+	Verify(f schema.Facts, b schema.Bundle) error
+}
+
 type ServerActor struct {
 	signer schema.EnvelopeSigner
+	// policy and verifier are only set when decisions are made by a policy
+	policy   policy.Policy
+	verifier BundleVerifier
 }
 
 func New(signer schema.EnvelopeSigner) *ServerActor {
@@ -13,3 +23,13 @@ func New(signer schema.EnvelopeSigner) *ServerActor {
 		signer: signer,
 	}
 }
+
+// NewWithPolicy returns a server actor which makes decisions by evaluating a policy.
+// Bundles are verified using the verifier before the policy is evaluated.
+func NewWithPolicy(signer schema.EnvelopeSigner, p policy.Policy, verifier BundleVerifier) *ServerActor {
+	return &ServerActor{
+		signer:   signer,
+		policy:   p,
+		verifier: verifier,
+	}
+}
@@ -1,12 +1,40 @@
 package types
 
-type Payload int
+// Payload is the type URI of an attestation payload, such as granted.dev/Init/v0.1.
+// Applications may define their own payload types and register them with
+// schema.RegisterPayload.
+type Payload string
 
-//go:generate go run github.com/alvaroloes/enumer -type=Payload -linecomment -json
 const (
-	PayloadInit          Payload = iota + 1 // granted.dev/Init/v0.1
-	PayloadAuthenticated                    // granted.dev/Authenticated/v0.1
-	PayloadAccessRequest                    // granted.dev/AccessRequest/v0.1
-	PayloadDecision                         // granted.dev/Decision/v0.1
-	PayloadGrantCreated                     // granted.dev/GrantCreated/v0.1
+	PayloadInit          Payload = "granted.dev/Init/v0.1"
+	PayloadAuthenticated Payload = "granted.dev/Authenticated/v0.1"
+	PayloadAccessRequest Payload = "granted.dev/AccessRequest/v0.2"
+	PayloadDecision      Payload = "granted.dev/Decision/v0.2"
+	PayloadGrantCreated  Payload = "granted.dev/GrantCreated/v0.2"
+	PayloadApproval      Payload = "granted.dev/Approval/v0.1"
+	PayloadGrantRevoked  Payload = "granted.dev/GrantRevoked/v0.1"
+	PayloadGrantExtended Payload = "granted.dev/GrantExtended/v0.1"
+	PayloadGrantExpired  Payload = "granted.dev/GrantExpired/v0.1"
 )
+
+// PayloadKeyRevocationList is the type of a signed list of revoked keys.
+// Revocation lists are distributed to verifiers, rather than appended to bundles.
+const PayloadKeyRevocationList Payload = "granted.dev/KeyRevocationList/v0.1"
+
+// Earlier versions of payload types, which are upgraded to the
+// current version when they are deserialized.
+const (
+	// PayloadAccessRequestV01 is the original access request payload type,
+	// which only contained a role and reason.
+	PayloadAccessRequestV01 Payload = "granted.dev/AccessRequest/v0.1"
+	// PayloadGrantCreatedV01 is the original grant payload type,
+	// which could only describe AWS role grants.
+	PayloadGrantCreatedV01 Payload = "granted.dev/GrantCreated/v0.1"
+	// PayloadDecisionV01 is the original decision payload type, which
+	// recorded the outcome using the AutoAllow and RequireApproval flags.
+	PayloadDecisionV01 Payload = "granted.dev/Decision/v0.1"
+)
+
+func (p Payload) String() string {
+	return string(p)
+}
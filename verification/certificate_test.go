@@ -0,0 +1,90 @@
+package verification
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/clientactions"
+	"github.com/common-fate/attestations/internal/certtest"
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/serveractions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentityServerCertificateChain(t *testing.T) {
+	ctx := context.Background()
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := certtest.Issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		PermittedDNSDomains:   []string{"idp.example.com"},
+	}, nil)
+	roots := []*x509.Certificate{root.Cert}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				CertificateAuthority: &schema.CertificateAuthority{Roots: roots},
+			},
+		},
+	}
+
+	// authenticate signs the bundle with a newly certified identity server key
+	authenticate := func(name string, extKeyUsage x509.ExtKeyUsage) schema.Bundle {
+		cert := certtest.Issue(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: name},
+			DNSNames:    []string{name},
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{extKeyUsage},
+		}, &root)
+		user := clientactions.New(&schema.LocalSigner{PrivateKey: kp["user"].Private})
+		server := serveractions.New(&schema.CertificateSigner{
+			EnvelopeSigner: &schema.LocalSigner{PrivateKey: cert.Key},
+			Chain:          [][]byte{cert.Cert.Raw},
+		})
+
+		bundle, err := user.Init(ctx, kp["user"].Public)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle, err = server.Authenticate(ctx, bundle[0], schema.AuthMessageOpts{
+			Time:   time.Now(),
+			UserID: userID,
+			Claims: map[string]interface{}{},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bundle
+	}
+
+	a := AuthenticationStage{}
+	err = a.Verify(facts, authenticate("idp.example.com", x509.ExtKeyUsageCodeSigning))
+	assert.NoError(t, err)
+
+	// the identity server's key can be rotated without changing the facts
+	err = a.Verify(facts, authenticate("idp.example.com", x509.ExtKeyUsageCodeSigning))
+	assert.NoError(t, err)
+
+	// certificates must be within the root's name constraints
+	err = a.Verify(facts, authenticate("mallory.example.com", x509.ExtKeyUsageCodeSigning))
+	assert.ErrorAs(t, err, new(x509.CertificateInvalidError))
+
+	// other certificates issued by the root, such as the identity server's TLS certificate, can't sign
+	err = a.Verify(facts, authenticate("idp.example.com", x509.ExtKeyUsageServerAuth))
+	assert.ErrorAs(t, err, new(x509.CertificateInvalidError))
+}
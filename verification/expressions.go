@@ -0,0 +1,93 @@
+package verification
+
+import (
+	"fmt"
+
+	"github.com/common-fate/attestations/expression"
+	"github.com/common-fate/attestations/schema"
+)
+
+// ExpressionRule is a CEL expression which must evaluate to true for a bundle to
+// be valid. Expressions can refer to the facts and to each payload in the bundle,
+// for example `request.role.startsWith("prod-") ? decision.requireApproval : true`.
+// See the expression package for the available variables.
+type ExpressionRule struct {
+	Name       string `json:"name" yaml:"name"`
+	Expression string `json:"expression" yaml:"expression"`
+}
+
+// ErrExpressionFailed is returned when an expression rule evaluates to false,
+// or can't be evaluated against the bundle.
+type ErrExpressionFailed struct {
+	Name       string
+	Expression string
+	// Err is set if the expression couldn't be evaluated
+	Err error
+}
+
+func (e *ErrExpressionFailed) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("expression rule %s could not be evaluated: %s", e.Name, e.Err)
+	}
+	return fmt.Sprintf("expression rule %s failed: %s", e.Name, e.Expression)
+}
+
+func (e *ErrExpressionFailed) Unwrap() error {
+	return e.Err
+}
+
+// ExpressionEvaluator evaluates compiled expression rules against bundles.
+type ExpressionEvaluator struct {
+	rules       []ExpressionRule
+	expressions []*expression.Expression
+}
+
+// NewExpressionEvaluator compiles the expression rules.
+func NewExpressionEvaluator(rules []ExpressionRule) (*ExpressionEvaluator, error) {
+	ev := ExpressionEvaluator{rules: rules}
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("expression rule %d must have a name", i)
+		}
+		expr, err := expression.Compile(r.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("expression rule %s: %w", r.Name, err)
+		}
+		ev.expressions = append(ev.expressions, expr)
+	}
+	return &ev, nil
+}
+
+// Evaluate evaluates each rule against the facts and decoded payloads of a bundle,
+// returning the result of each rule in order. A nil result means that the rule passed.
+func (ev *ExpressionEvaluator) Evaluate(f schema.Facts, payloads []schema.Payload) ([]error, error) {
+	vars, err := expression.NewVariables(f, payloads)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]error, len(ev.rules))
+	for i, r := range ev.rules {
+		ok, err := ev.expressions[i].Eval(vars)
+		if err != nil {
+			results[i] = &ErrExpressionFailed{Name: r.Name, Expression: r.Expression, Err: err}
+		} else if !ok {
+			results[i] = &ErrExpressionFailed{Name: r.Name, Expression: r.Expression}
+		}
+	}
+	return results, nil
+}
+
+// Verify evaluates each rule, returning an error for the first rule which fails.
+func (ev *ExpressionEvaluator) Verify(f schema.Facts, payloads []schema.Payload) error {
+	results, err := ev.Evaluate(f, payloads)
+	if err != nil {
+		return err
+	}
+	for _, err := range results {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package verification
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/serveractions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStageExpressions(t *testing.T) {
+	ctx := context.Background()
+	userID := "alice"
+
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := serveractions.New(&schema.LocalSigner{PrivateKey: kp["server"].Private})
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+
+	bundle := makeDecisionBundle(t, kp, userID, schema.Decision{AutoAllow: true})
+	bundle, err = server.CreateGrant(ctx, bundle, schema.Grant{
+		Type:      schema.GrantTypeAWS,
+		ExpiresAt: time.Now().Add(time.Hour),
+		AWS:       &schema.AWSGrant{RoleARN: testRoleARN},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stage, err := ParseStage([]byte(`
+name: autoApproveDecision
+envelopes:
+  - type: granted.dev/Init/v0.1
+    signers: [user, identityServer]
+  - type: granted.dev/Authenticated/v0.1
+    signers: [user, identityServer]
+  - type: granted.dev/AccessRequest/v0.2
+    signers: [user, identityServer]
+  - type: granted.dev/Decision/v0.2
+    signers: [identityServer]
+  - type: granted.dev/GrantCreated/v0.2
+    signers: [identityServer]
+expressions:
+  - name: prodRequiresApproval
+    expression: 'request.role.contains(":role/prod-") ? decision.requireApproval : true'
+  - name: shortRequests
+    expression: 'request.duration <= duration("2h")'
+  - name: oncall
+    expression: 'auth.claims.groups.exists(g, g == "oncall")'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the user doesn't have a groups claim
+	err = stage.Verify(facts, bundle)
+	target := &ErrExpressionFailed{}
+	assert.ErrorAs(t, err, &target)
+	assert.Equal(t, "oncall", target.Name)
+	assert.Error(t, target.Err)
+
+	report := stage.VerifyReport(facts, bundle)
+	assert.False(t, report.Valid)
+	assert.Len(t, report.Rules, 3)
+	assert.Equal(t, CheckPassed, report.Rules[0].Result.Status)
+	assert.Equal(t, CheckPassed, report.Rules[1].Result.Status)
+	assert.Equal(t, CheckFailed, report.Rules[2].Result.Status)
+	assert.Equal(t, `auth.claims.groups.exists(g, g == "oncall")`, report.Rules[2].Expression)
+
+	stage.Expressions = stage.Expressions[:2]
+	err = stage.Verify(facts, bundle)
+	assert.NoError(t, err)
+
+	stage.Expressions = append(stage.Expressions, ExpressionRule{
+		Name:       "oneHour",
+		Expression: `request.duration <= duration("1h")`,
+	})
+	err = stage.Verify(facts, bundle)
+	assert.EqualError(t, err, `expression rule oneHour failed: request.duration <= duration("1h")`)
+}
+
+func TestParseStageRejectsInvalidExpressions(t *testing.T) {
+	_, err := ParseStage([]byte(`
+name: invalid
+envelopes:
+  - type: granted.dev/Init/v0.1
+    signers: [user]
+expressions:
+  - name: broken
+    expression: 'request.role =='
+`))
+	assert.Error(t, err)
+}
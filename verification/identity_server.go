@@ -0,0 +1,141 @@
+package verification
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+)
+
+// resolveIdentityServer resolves the identity server role for envelope e, signed at t.
+// When only a single identity server key is trusted it must sign the envelope, otherwise
+// a signature made with any of the trusted identity servers' keys is sufficient.
+func resolveIdentityServer(f schema.Facts, e schema.Envelope, t time.Time) (signerRequirement, error) {
+	var candidates []schema.ExpectedSigner
+	var firstErr error
+	for _, server := range f.TrustedIdentityServers() {
+		signers, err := identityServerSigners(f, server, e, t)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		candidates = append(candidates, signers...)
+	}
+	switch len(candidates) {
+	case 0:
+		if firstErr != nil {
+			return signerRequirement{}, firstErr
+		}
+		return signerRequirement{}, errors.New("envelope was not signed by any trusted identity server")
+	case 1:
+		return signerRequirement{signers: candidates}, nil
+	}
+	return signerRequirement{thresholds: []schema.ThresholdPolicy{{Candidates: candidates, Threshold: 1}}}, nil
+}
+
+// identityServerSigners returns the keys which the identity server may have signed envelope e
+// with at t. These are its keys in the trust store which were valid at t, or otherwise its keys
+// in the Facts, along with any keys certified by its certificate authority in the envelope's signatures.
+func identityServerSigners(f schema.Facts, server schema.IdentityServer, e schema.Envelope, t time.Time) ([]schema.ExpectedSigner, error) {
+	var signers []schema.ExpectedSigner
+	var keysErr error
+	if f.TrustStore.Has(server.Signer().Name) {
+		signers, keysErr = f.TrustStore.SignersAt(server.Signer(), t)
+	} else {
+		signers = server.Signers()
+	}
+
+	certified, err := server.CertificateSigners(e, t)
+	if err != nil {
+		return signers, err
+	}
+	signers = append(signers, certified...)
+	if len(signers) == 0 {
+		return nil, keysErr
+	}
+	return signers, nil
+}
+
+// vouchingIdentityServer returns the trusted identity server which signed an envelope at t.
+func vouchingIdentityServer(f schema.Facts, e schema.Envelope, t time.Time, versions []schema.SigningVersion) (schema.IdentityServer, error) {
+	for _, server := range f.TrustedIdentityServers() {
+		signers, _ := identityServerSigners(f, server, e, t)
+		for _, signer := range signers {
+			status, err := e.VerifySigner(signer, versions...)
+			if err != nil {
+				return schema.IdentityServer{}, err
+			}
+			if status == schema.SignatureValid {
+				return server, nil
+			}
+		}
+	}
+	return schema.IdentityServer{}, errors.New("authentication was not signed by a trusted identity server")
+}
+
+// verifyVouchingServer returns the trusted identity server which vouched for an authentication by
+// signing its envelope, and checks that the authentication was issued by the server's issuer.
+func verifyVouchingServer(f schema.Facts, e schema.Envelope, auth *schema.AuthenticatedPayload, versions []schema.SigningVersion) (schema.IdentityServer, error) {
+	server, err := vouchingIdentityServer(f, e, auth.AttestedAt(), versions)
+	if err != nil {
+		return schema.IdentityServer{}, err
+	}
+	err = auth.ValidateIssuer(server)
+	if err != nil {
+		return schema.IdentityServer{}, err
+	}
+	return server, nil
+}
+
+// vouchedForBySpec reports whether an envelope spec requires the identity server
+// to vouch for an authentication payload.
+func vouchedForBySpec(spec EnvelopeSpec, p schema.Payload) (*schema.AuthenticatedPayload, bool) {
+	auth, ok := p.(*schema.AuthenticatedPayload)
+	if !ok {
+		return nil, false
+	}
+	for _, role := range spec.Signers {
+		if role == RoleIdentityServer {
+			return auth, true
+		}
+	}
+	return nil, false
+}
+
+// vouchingServerBySpec returns the trusted identity server which vouched for the payload of an
+// envelope, or nil if the envelope's spec doesn't require the identity server to vouch for it.
+// It is used when verifying a bundle and when reporting on it, so that rules are given the same server.
+func vouchingServerBySpec(f schema.Facts, spec EnvelopeSpec, e schema.Envelope, p schema.Payload, versions []schema.SigningVersion) (*schema.IdentityServer, error) {
+	auth, ok := vouchedForBySpec(spec, p)
+	if !ok {
+		return nil, nil
+	}
+	server, err := verifyVouchingServer(f, e, auth, versions)
+	if err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+// VouchedBy returns the trusted identity server which vouched for the user in a bundle,
+// by signing the bundle's authenticated envelope. The bundle should be verified against
+// the stage before calling VouchedBy.
+func (s *Stage) VouchedBy(f schema.Facts, b schema.Bundle) (schema.IdentityServer, error) {
+	versions := signingVersions(s.AcceptLegacySignatures)
+	for i := range b {
+		spec, err := s.envelopeSpec(b, i)
+		if err != nil {
+			return schema.IdentityServer{}, err
+		}
+		if spec.Type != types.PayloadAuthenticated {
+			continue
+		}
+		p, err := s.deserializePayload(b[i].Payload, spec.Type)
+		if err != nil {
+			return schema.IdentityServer{}, err
+		}
+		return vouchingIdentityServer(f, b[i], p.(*schema.AuthenticatedPayload).AttestedAt(), versions)
+	}
+	return schema.IdentityServer{}, fmt.Errorf("stage %s does not include an authentication", s.Name)
+}
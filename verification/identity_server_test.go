@@ -0,0 +1,78 @@
+package verification
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/clientactions"
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/serveractions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultipleIdentityServers(t *testing.T) {
+	ctx := context.Background()
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "us", "eu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := clientactions.New(&schema.LocalSigner{PrivateKey: kp["user"].Private})
+	eu := serveractions.New(&schema.LocalSigner{PrivateKey: kp["eu"].Private})
+
+	bundle, err := user.Init(ctx, kp["user"].Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = eu.Authenticate(ctx, bundle[0], schema.AuthMessageOpts{
+		Time:   time.Now(),
+		UserID: userID,
+		Claims: map[string]interface{}{"iss": "https://eu.idp.example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServers: []schema.IdentityServer{
+				{ID: "us", Issuer: "https://us.idp.example.com", PublicKey: kp["us"].Public},
+				{ID: "eu", Issuer: "https://eu.idp.example.com", PublicKey: kp["eu"].Public},
+			},
+		},
+	}
+
+	a := AuthenticationStage{}
+	err = a.Verify(facts, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := AuthenticationStageSpec.VouchedBy(facts, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "eu", server.ID)
+
+	r := AuthenticationStageSpec.VerifyReport(facts, bundle)
+	assert.True(t, r.Valid)
+	assert.Equal(t, "eu", r.Envelopes[1].IdentityServer)
+
+	// the identity server which vouched for the user must be the authentication's issuer
+	facts.Actors.IdentityServers[1].Issuer = "https://idp.example.com"
+	err = a.Verify(facts, bundle)
+	targetErr := &schema.ErrClaimNotAllowed{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, "https://eu.idp.example.com", targetErr.Value)
+
+	// the authentication must be vouched for by a trusted identity server
+	facts.Actors.IdentityServers = facts.Actors.IdentityServers[:1]
+	err = a.Verify(facts, bundle)
+	assert.ErrorAs(t, err, new(*schema.ErrMissingSignatures))
+}
@@ -0,0 +1,210 @@
+package verification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+)
+
+// NonceStore records the nonces of INIT envelopes which have been seen.
+type NonceStore interface {
+	// CheckAndStore records a nonce as seen until expiresAt. It returns ErrNonceReused
+	// if the nonce has already been seen and hadn't expired by now. Stores should use now,
+	// rather than their own clock, so that nonces expire on the same clock which the
+	// replay window is checked against.
+	CheckAndStore(ctx context.Context, nonce string, now time.Time, expiresAt time.Time) error
+}
+
+// ErrNonceReused is returned when an INIT envelope's nonce has already been seen.
+type ErrNonceReused struct {
+	Nonce string
+}
+
+func (e *ErrNonceReused) Error() string {
+	return fmt.Sprintf("nonce %s has already been used", e.Nonce)
+}
+
+// ErrInitOutsideWindow is returned when an INIT envelope was created
+// too long ago, or in the future.
+type ErrInitOutsideWindow struct {
+	CreatedAt time.Time
+	Now       time.Time
+}
+
+func (e *ErrInitOutsideWindow) Error() string {
+	return fmt.Sprintf("INIT envelope created at %s is outside of the replay window at %s", e.CreatedAt.Format(time.RFC3339), e.Now.Format(time.RFC3339))
+}
+
+// ReplayGuard rejects INIT envelopes which have been seen before, or which
+// were created outside of the replay window.
+//
+// A bundle legitimately carries the same INIT envelope through every stage,
+// so Check should only be called once per bundle, when the identity server
+// first receives the INIT envelope. No stage calls Check, so the identity
+// server must call it itself before countersigning the INIT envelope.
+type ReplayGuard struct {
+	VerifyOptions
+	Store NonceStore
+	// Window is how long an INIT envelope may be used for after it was created.
+	// Nonces are remembered for this long.
+	Window time.Duration
+	// ClockSkew is how far in the future an INIT envelope may have been created,
+	// to allow for differences between the client and server clocks.
+	ClockSkew time.Duration
+}
+
+// Check verifies that the bundle's INIT envelope is within the replay window and
+// that its nonce hasn't been seen before, and records the nonce as seen.
+// The INIT envelope is verified against InitStageSpec first, so that nonces can
+// only be recorded by the user, rather than by anyone who sees or predicts them.
+// The current time is taken from the Facts, or the system clock if it isn't set.
+func (g *ReplayGuard) Check(ctx context.Context, f schema.Facts, b schema.Bundle) error {
+	if len(b) == 0 {
+		return errors.New("bundle did not contain an INIT envelope")
+	}
+	err := verifyWithSpec(InitStageSpec, g.VerifyOptions, f, b[:1])
+	if err != nil {
+		return err
+	}
+	p, err := schema.DeserializePayload(b[0].Payload, types.PayloadInit)
+	if err != nil {
+		return err
+	}
+	init := p.(*schema.InitPayload)
+
+	if init.Nonce == "" {
+		return &schema.ErrInvalidPayloadContents{
+			Msg: "INIT envelope did not contain a nonce",
+		}
+	}
+
+	now := f.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	createdAt := time.Unix(0, init.Time)
+	if now.Sub(createdAt) > g.Window || createdAt.Sub(now) > g.ClockSkew {
+		return &ErrInitOutsideWindow{
+			CreatedAt: createdAt,
+			Now:       now,
+		}
+	}
+
+	return g.Store.CheckAndStore(ctx, init.Nonce, now, createdAt.Add(g.Window))
+}
+
+// MemoryNonceStore is a NonceStore which holds nonces in memory.
+// It is suitable for a single verifier process.
+type MemoryNonceStore struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		seen: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryNonceStore) CheckAndStore(ctx context.Context, nonce string, now time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pruneNonces(s.seen, now)
+	if _, ok := s.seen[nonce]; ok {
+		return &ErrNonceReused{Nonce: nonce}
+	}
+	s.seen[nonce] = expiresAt
+	return nil
+}
+
+// FileNonceStore is a NonceStore which persists nonces to a JSON file,
+// so that nonces are remembered when the verifier restarts.
+// It is safe for concurrent use within a process, but the file must
+// not be shared between processes.
+type FileNonceStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileNonceStore(path string) *FileNonceStore {
+	return &FileNonceStore{
+		path: path,
+	}
+}
+
+func (s *FileNonceStore) CheckAndStore(ctx context.Context, nonce string, now time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	pruneNonces(seen, now)
+	if _, ok := seen[nonce]; ok {
+		return &ErrNonceReused{Nonce: nonce}
+	}
+	seen[nonce] = expiresAt
+
+	return s.save(seen)
+}
+
+func (s *FileNonceStore) load() (map[string]time.Time, error) {
+	seen := make(map[string]time.Time)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return seen, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &seen)
+	if err != nil {
+		return nil, err
+	}
+	return seen, nil
+}
+
+// save writes the nonces to a temporary file and renames it over the store,
+// so that the store isn't left partially written if the process exits.
+func (s *FileNonceStore) save(seen map[string]time.Time) error {
+	data, err := json.Marshal(seen)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// pruneNonces removes nonces which expired before now.
+func pruneNonces(seen map[string]time.Time, now time.Time) {
+	for nonce, expiresAt := range seen {
+		if now.After(expiresAt) {
+			delete(seen, nonce)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package verification
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/clientactions"
+	"github.com/common-fate/attestations/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayGuardRejectsReusedInit(t *testing.T) {
+	ctx := context.Background()
+	kp, err := MakeTestKeyPairs([]string{"user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := clientactions.New(&schema.LocalSigner{PrivateKey: kp["user"].Private})
+
+	stores := map[string]NonceStore{
+		"memory": NewMemoryNonceStore(),
+		"file":   NewFileNonceStore(filepath.Join(t.TempDir(), "nonces.json")),
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{User: schema.User{ID: "alice", PublicKey: kp["user"].Public}},
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			g := ReplayGuard{Store: store, Window: time.Minute}
+
+			bundle, err := user.Init(ctx, kp["user"].Public)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = g.Check(ctx, facts, bundle)
+			assert.NoError(t, err)
+
+			err = g.Check(ctx, facts, bundle)
+			targetErr := &ErrNonceReused{}
+			assert.ErrorAs(t, err, &targetErr)
+		})
+	}
+}
+
+func TestReplayGuardRejectsExpiredInit(t *testing.T) {
+	ctx := context.Background()
+	kp, err := MakeTestKeyPairs([]string{"user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := clientactions.New(&schema.LocalSigner{PrivateKey: kp["user"].Private})
+	g := ReplayGuard{Store: NewMemoryNonceStore(), Window: time.Minute}
+
+	bundle, err := user.Init(ctx, kp["user"].Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{User: schema.User{ID: "alice", PublicKey: kp["user"].Public}},
+		Time:   time.Now().Add(time.Hour),
+	}
+	err = g.Check(ctx, facts, bundle)
+	targetErr := &ErrInitOutsideWindow{}
+	assert.ErrorAs(t, err, &targetErr)
+}
+
+// Only the user's own INIT envelope can record its nonce, so a nonce can't
+// be burned by someone who sees or predicts it before the user does.
+func TestReplayGuardRequiresUserSignature(t *testing.T) {
+	ctx := context.Background()
+	kp, err := MakeTestKeyPairs([]string{"user", "mallory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := clientactions.New(&schema.LocalSigner{PrivateKey: kp["user"].Private})
+	mallory := clientactions.New(&schema.LocalSigner{PrivateKey: kp["mallory"].Private})
+	g := ReplayGuard{Store: NewMemoryNonceStore(), Window: time.Minute}
+	facts := schema.Facts{
+		Actors: schema.Actors{User: schema.User{ID: "alice", PublicKey: kp["user"].Public}},
+	}
+	nonce := mustGenerateNonce()
+
+	forged, err := mallory.InitWithNonce(ctx, kp["user"].Public, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.Check(ctx, facts, forged)
+	assert.ErrorAs(t, err, new(*schema.ErrMissingSignatures))
+
+	unsigned, err := schema.EnvelopeFromPayload(schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), nonce, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.Check(ctx, facts, schema.Bundle{unsigned})
+	assert.ErrorAs(t, err, new(*schema.ErrMissingSignatures))
+
+	bundle, err := user.InitWithNonce(ctx, kp["user"].Public, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, g.Check(ctx, facts, bundle))
+}
+
+// Nonces expire on the guard's clock, which is taken from the Facts, rather than
+// the system clock. Otherwise a nonce would be forgotten while verifying at a time
+// in the Facts when it is still within the replay window.
+func TestReplayGuardClock(t *testing.T) {
+	ctx := context.Background()
+	kp, err := MakeTestKeyPairs([]string{"user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createdAt := time.Now().Add(-2 * time.Hour)
+	bundle, err := ParseTestBundle([]TestEnvelope{
+		{
+			Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), createdAt),
+			SignedBy: []string{"user"},
+		},
+	}, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	facts := schema.Facts{
+		Actors: schema.Actors{User: schema.User{ID: "alice", PublicKey: kp["user"].Public}},
+		Time:   createdAt.Add(30 * time.Second),
+	}
+
+	for name, store := range map[string]NonceStore{
+		"memory": NewMemoryNonceStore(),
+		"file":   NewFileNonceStore(filepath.Join(t.TempDir(), "nonces.json")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := ReplayGuard{Store: store, Window: time.Minute}
+			assert.NoError(t, g.Check(ctx, facts, bundle))
+			err := g.Check(ctx, facts, bundle)
+			assert.ErrorAs(t, err, new(*ErrNonceReused))
+		})
+	}
+}
+
+// Nonces written by one file store must be seen by another using the same file,
+// and expired nonces must be pruned.
+func TestFileNonceStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nonces.json")
+	now := time.Now()
+
+	err := NewFileNonceStore(path).CheckAndStore(ctx, "nonce", now, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewFileNonceStore(path).CheckAndStore(ctx, "nonce", now, now.Add(time.Minute))
+	targetErr := &ErrNonceReused{}
+	assert.ErrorAs(t, err, &targetErr)
+
+	err = NewFileNonceStore(path).CheckAndStore(ctx, "nonce", now.Add(time.Hour), now.Add(2*time.Hour))
+	assert.NoError(t, err)
+}
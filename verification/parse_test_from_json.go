@@ -2,9 +2,7 @@ package verification
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
+	"crypto"
 
 	"github.com/common-fate/attestations/schema"
 )
@@ -15,23 +13,38 @@ type TestEnvelope struct {
 }
 
 type KeyPair struct {
-	Public  ecdsa.PublicKey
-	Private *ecdsa.PrivateKey
+	Public  schema.PublicKey
+	Private crypto.Signer
 }
 
 type KeyPairMap map[string]KeyPair
 
-// MakeTestKeyPairs provides a convenient method to set up named keypairs for testing
+// MakeTestKeyPairs provides a convenient method to set up named keypairs for testing.
+// The keypairs use ECDSA with the P-256 curve.
 func MakeTestKeyPairs(signers []string) (KeyPairMap, error) {
-	sigMap := make(KeyPairMap)
+	algs := make(map[string]schema.Algorithm)
 	for _, signer := range signers {
+		algs[signer] = schema.AlgorithmES256
+	}
+	return MakeTestKeyPairsWithAlgorithms(algs)
+}
+
+// MakeTestKeyPairsWithAlgorithms sets up named keypairs for testing,
+// using the provided signature algorithm for each signer.
+func MakeTestKeyPairsWithAlgorithms(signers map[string]schema.Algorithm) (KeyPairMap, error) {
+	sigMap := make(KeyPairMap)
+	for signer, alg := range signers {
 		// provision new keys for the signer
-		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		priv, err := schema.GenerateKey(alg)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := schema.NewPublicKey(priv.Public())
 		if err != nil {
 			return nil, err
 		}
 		kp := KeyPair{
-			Public:  priv.PublicKey,
+			Public:  pub,
 			Private: priv,
 		}
 		sigMap[signer] = kp
@@ -40,13 +53,13 @@ func MakeTestKeyPairs(signers []string) (KeyPairMap, error) {
 }
 
 // ParseTestBundle provides a convenient method to construct bundles with various types of signatures
-// for testing
+// for testing. Each envelope is linked to the envelope before it.
 func ParseTestBundle(testBundle []TestEnvelope, sigMap KeyPairMap) (schema.Bundle, error) {
 	b := schema.Bundle{}
 	ctx := context.Background()
 
 	for _, e := range testBundle {
-		env, err := schema.EnvelopeFromPayload(e.Payload)
+		env, err := schema.NewChainedEnvelope(b, e.Payload)
 		if err != nil {
 			return nil, err
 		}
@@ -2,9 +2,8 @@ package verification
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"crypto/x509"
 	"testing"
+	"time"
 
 	"github.com/common-fate/attestations/schema"
 	"github.com/stretchr/testify/assert"
@@ -15,13 +14,12 @@ func TestParseTestBundle(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	pub := kp["user"].Public
-	publicDerBytes, err := x509.MarshalPKIXPublicKey(&pub)
+	publicDerBytes, err := schema.MarshalPublicKey(kp["user"].Public)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	payload := schema.NewInitMessage(publicDerBytes)
+	payload := schema.NewInitMessage(publicDerBytes, mustGenerateNonce(), time.Now())
 
 	testBundle := []TestEnvelope{
 		{
@@ -50,7 +48,7 @@ func TestParseTestBundle(t *testing.T) {
 	assert.Equal(t, expectedEnv.Payload, b[0].Payload)
 	assert.Equal(t, expectedEnv.PayloadType, b[0].PayloadType)
 
-	err = b[0].VerifySignatures([]ecdsa.PublicKey{kp["user"].Public})
+	err = b[0].VerifySignatures([]schema.ExpectedSigner{{Name: "user", PublicKey: kp["user"].Public}})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,304 @@
+package verification
+
+import (
+	"fmt"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+)
+
+// CheckStatus is the outcome of a single check in a verification report.
+type CheckStatus string
+
+const (
+	CheckPassed CheckStatus = "passed"
+	CheckFailed CheckStatus = "failed"
+	// CheckSkipped means that the check couldn't be run because an earlier check failed
+	CheckSkipped CheckStatus = "skipped"
+)
+
+// CheckResult is the outcome of a check, with the error if it failed.
+type CheckResult struct {
+	Status CheckStatus `json:"status"`
+	Error  string      `json:"error,omitempty"`
+}
+
+func checkResult(err error) CheckResult {
+	if err != nil {
+		return CheckResult{Status: CheckFailed, Error: err.Error()}
+	}
+	return CheckResult{Status: CheckPassed}
+}
+
+var skipped = CheckResult{Status: CheckSkipped}
+
+// SignerReport is the outcome of checking an expected signer's signature.
+type SignerReport struct {
+	Role SignerRole `json:"role"`
+	// Name is the name of the actor, such as "user" or "approver:bob"
+	Name   string                 `json:"name,omitempty"`
+	KeyID  string                 `json:"keyId,omitempty"`
+	Status schema.SignatureStatus `json:"status,omitempty"`
+	// Error is set if the role couldn't be resolved to a signer
+	Error string `json:"error,omitempty"`
+}
+
+// ThresholdReport is the outcome of checking a threshold signature policy.
+type ThresholdReport struct {
+	Role      SignerRole  `json:"role"`
+	Threshold int         `json:"threshold"`
+	Signed    []string    `json:"signed"`
+	Result    CheckResult `json:"result"`
+}
+
+// EnvelopeReport is the outcome of verifying an envelope against its spec.
+type EnvelopeReport struct {
+	Index        int               `json:"index"`
+	ExpectedType types.Payload     `json:"expectedType"`
+	Type         CheckResult       `json:"type"`
+	Signers      []SignerReport    `json:"signers"`
+	Thresholds   []ThresholdReport `json:"thresholds,omitempty"`
+	Contents     CheckResult       `json:"contents"`
+	// IdentityServer is the ID of the identity server which vouched for
+	// an authentication, if the envelope contains one
+	IdentityServer string `json:"identityServer,omitempty"`
+}
+
+// Valid reports whether each check on the envelope passed.
+func (r EnvelopeReport) Valid() bool {
+	if r.Type.Status != CheckPassed || r.Contents.Status != CheckPassed {
+		return false
+	}
+	for _, s := range r.Signers {
+		if s.Status != schema.SignatureValid {
+			return false
+		}
+	}
+	for _, t := range r.Thresholds {
+		if t.Result.Status != CheckPassed {
+			return false
+		}
+	}
+	return true
+}
+
+// RuleReport is the outcome of a bundle-level rule.
+type RuleReport struct {
+	Name string `json:"name"`
+	// Expression is the CEL expression, if the rule is an expression rule
+	Expression string      `json:"expression,omitempty"`
+	Result     CheckResult `json:"result"`
+}
+
+// Report is the full outcome of verifying a bundle against a stage.
+// Unlike Verify, which returns on the first error, a report describes every
+// problem with the bundle. It can be serialized to JSON to return to clients.
+type Report struct {
+	Stage     string           `json:"stage"`
+	Valid     bool             `json:"valid"`
+	Length    CheckResult      `json:"length"`
+	Chain     CheckResult      `json:"chain"`
+	Envelopes []EnvelopeReport `json:"envelopes"`
+	Rules     []RuleReport     `json:"rules,omitempty"`
+}
+
+// VerifyReport verifies a bundle against the stage specification, running every check
+// rather than stopping at the first failure. Each envelope in the bundle which has a
+// spec is checked, even if the bundle has the wrong number of envelopes.
+// When the stage allows trailing envelopes, every envelope in the bundle is checked.
+func (s *Stage) VerifyReport(f schema.Facts, b schema.Bundle) *Report {
+	r := Report{
+		Stage:  s.Name,
+		Length: CheckResult{Status: CheckPassed},
+	}
+	switch {
+	case s.Trailing != nil && len(b) < len(s.Envelopes):
+		r.Length = checkResult(fmt.Errorf("bundle contained %d envelopes but at least %d were expected", len(b), len(s.Envelopes)))
+	case s.Trailing == nil && len(b) != len(s.Envelopes):
+		r.Length = checkResult(fmt.Errorf("bundle contained %d envelopes but %d were expected", len(b), len(s.Envelopes)))
+	}
+
+	versions := signingVersions(s.AcceptLegacySignatures)
+
+	n := len(b)
+	if s.Trailing == nil && len(s.Envelopes) < n {
+		n = len(s.Envelopes)
+	}
+	payloads := make([]schema.Payload, n)
+	// envelopes without a spec have no required signers, so their links are always checked
+	reqs := make([]signerRequirement, len(b))
+	var server *schema.IdentityServer
+	for i := 0; i < n; i++ {
+		spec, err := s.envelopeSpec(b, i)
+		if err != nil {
+			r.Envelopes = append(r.Envelopes, EnvelopeReport{
+				Index:    i,
+				Type:     checkResult(err),
+				Signers:  []SignerReport{},
+				Contents: skipped,
+			})
+			continue
+		}
+		er, res := s.reportEnvelopeSpec(f, b, payloads[:i], i, spec, versions)
+		r.Envelopes = append(r.Envelopes, er)
+		payloads[i] = res.payload
+		reqs[i] = res.required
+		if res.identityServer != nil {
+			server = res.identityServer
+		}
+	}
+	r.Chain = checkResult(verifyChain(b, reqs, s.AcceptLegacySignatures))
+
+	// rules rely on every payload being present, so they are skipped if any envelope couldn't be decoded
+	decoded := r.Length.Status == CheckPassed
+	for _, p := range payloads {
+		if p == nil {
+			decoded = false
+		}
+	}
+	in := RuleInput{
+		Facts:          f,
+		Bundle:         b,
+		Payloads:       payloads,
+		IdentityServer: server,
+	}
+	for _, name := range s.Rules {
+		rr := RuleReport{Name: name, Result: skipped}
+		if decoded {
+			rule, ok := lookupRule(name)
+			if !ok {
+				rr.Result = checkResult(fmt.Errorf("unknown rule %s", name))
+			} else {
+				rr.Result = checkResult(rule(in))
+			}
+		}
+		r.Rules = append(r.Rules, rr)
+	}
+	r.Rules = append(r.Rules, s.reportExpressions(f, payloads, decoded)...)
+
+	r.Valid = r.valid()
+	return &r
+}
+
+// reportExpressions reports the outcome of each of the stage's expression rules.
+func (s *Stage) reportExpressions(f schema.Facts, payloads []schema.Payload, decoded bool) []RuleReport {
+	var reports []RuleReport
+	for _, e := range s.Expressions {
+		reports = append(reports, RuleReport{Name: e.Name, Expression: e.Expression, Result: skipped})
+	}
+	if !decoded || len(reports) == 0 {
+		return reports
+	}
+
+	ev, err := NewExpressionEvaluator(s.Expressions)
+	var results []error
+	if err == nil {
+		results, err = ev.Evaluate(f, payloads)
+	}
+	for i := range reports {
+		if err != nil {
+			reports[i].Result = checkResult(err)
+		} else {
+			reports[i].Result = checkResult(results[i])
+		}
+	}
+	return reports
+}
+
+func (r *Report) valid() bool {
+	if r.Length.Status != CheckPassed || r.Chain.Status != CheckPassed {
+		return false
+	}
+	for _, e := range r.Envelopes {
+		if !e.Valid() {
+			return false
+		}
+	}
+	for _, rr := range r.Rules {
+		if rr.Result.Status != CheckPassed {
+			return false
+		}
+	}
+	return true
+}
+
+// envelopeResult is what reportEnvelopeSpec found out about an envelope
+// which is needed by the bundle-level checks.
+type envelopeResult struct {
+	// payload is the deserialized payload, if the type check passed
+	payload schema.Payload
+	// required are the signers which were required on the envelope
+	required signerRequirement
+	// identityServer is the trusted identity server which vouched for the payload, if any
+	identityServer *schema.IdentityServer
+}
+
+// reportEnvelopeSpec checks the envelope at index i of the bundle against its spec.
+func (s *Stage) reportEnvelopeSpec(f schema.Facts, b schema.Bundle, previous []schema.Payload, i int, spec EnvelopeSpec, versions []schema.SigningVersion) (EnvelopeReport, envelopeResult) {
+	e := b[i]
+	er := EnvelopeReport{
+		Index:        i,
+		ExpectedType: spec.Type,
+		Signers:      []SignerReport{},
+		Contents:     skipped,
+	}
+
+	payload, err := s.deserializePayload(e.Payload, spec.Type)
+	er.Type = checkResult(err)
+
+	var res envelopeResult
+	for _, role := range spec.Signers {
+		req, err := resolveRole(role, f, e, previous, payload)
+		if err != nil {
+			er.Signers = append(er.Signers, SignerReport{Role: role, Error: err.Error()})
+			continue
+		}
+		res.required.signers = append(res.required.signers, req.signers...)
+		res.required.thresholds = append(res.required.thresholds, req.thresholds...)
+		for _, signer := range req.signers {
+			er.Signers = append(er.Signers, reportSigner(role, signer, e, versions))
+		}
+		for _, policy := range req.thresholds {
+			tr := ThresholdReport{Role: role, Threshold: policy.Threshold, Signed: []string{}}
+			result, err := e.VerifyThresholdWithVersions(policy, versions...)
+			if result != nil {
+				for _, s := range result.Signed {
+					tr.Signed = append(tr.Signed, s.Name)
+				}
+			}
+			tr.Result = checkResult(err)
+			er.Thresholds = append(er.Thresholds, tr)
+		}
+	}
+
+	if payload != nil {
+		err = s.validateContents(f, payload, previous)
+		if err == nil {
+			res.identityServer, err = vouchingServerBySpec(f, spec, e, payload, versions)
+		}
+		if res.identityServer != nil {
+			er.IdentityServer = res.identityServer.ID
+		}
+		er.Contents = checkResult(err)
+	}
+	res.payload = payload
+
+	return er, res
+}
+
+func reportSigner(role SignerRole, signer schema.ExpectedSigner, e schema.Envelope, versions []schema.SigningVersion) SignerReport {
+	sr := SignerReport{Role: role, Name: signer.Name}
+	keyID, err := signer.KeyID()
+	if err != nil {
+		sr.Error = err.Error()
+		return sr
+	}
+	sr.KeyID = keyID
+	status, err := e.VerifySigner(signer, versions...)
+	if err != nil {
+		sr.Error = err.Error()
+		return sr
+	}
+	sr.Status = status
+	return sr
+}
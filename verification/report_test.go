@@ -0,0 +1,165 @@
+package verification
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+// The report should describe every problem with a bundle, rather than only the first.
+func TestVerifyReportListsAllProblems(t *testing.T) {
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testBundle := []TestEnvelope{
+		{
+			// missing the identity server's signature
+			Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), time.Now()),
+			SignedBy: []string{"user"},
+		},
+		{
+			// for a different user
+			Payload: schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+				Time:   time.Now(),
+				UserID: "mallory",
+				Claims: map[string]interface{}{},
+			}),
+			SignedBy: []string{"server"},
+		},
+	}
+	bundle, err := ParseTestBundle(testBundle, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        "alice",
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+
+	r := AuthenticationStageSpec.VerifyReport(facts, bundle)
+	assert.False(t, r.Valid)
+	assert.Equal(t, CheckPassed, r.Length.Status)
+	assert.Equal(t, CheckPassed, r.Chain.Status)
+	assert.Len(t, r.Envelopes, 2)
+
+	init := r.Envelopes[0]
+	assert.Equal(t, CheckPassed, init.Type.Status)
+	assert.Equal(t, CheckPassed, init.Contents.Status)
+	assert.Equal(t, schema.SignatureValid, init.Signers[0].Status)
+	assert.Equal(t, RoleIdentityServer, init.Signers[1].Role)
+	assert.Equal(t, schema.SignatureMissing, init.Signers[1].Status)
+
+	auth := r.Envelopes[1]
+	assert.Equal(t, schema.SignatureValid, auth.Signers[0].Status)
+	assert.Equal(t, CheckResult{Status: CheckFailed, Error: "invalid payload contents: user ID didn't match"}, auth.Contents)
+
+	_, err = json.Marshal(r)
+	assert.NoError(t, err)
+}
+
+func TestVerifyReportValidBundle(t *testing.T) {
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := ParseTestBundle([]TestEnvelope{
+		{
+			Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), time.Now()),
+			SignedBy: []string{"user"},
+		},
+	}, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        "alice",
+				PublicKey: kp["user"].Public,
+			},
+		},
+	}
+
+	r := InitStageSpec.VerifyReport(facts, bundle)
+	assert.True(t, r.Valid)
+	assert.NoError(t, InitStageSpec.Verify(facts, bundle))
+}
+
+// Rules are given the identity server which vouched for the user,
+// whether the bundle is verified or reported on.
+func TestVerifyReportRuleIdentityServer(t *testing.T) {
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := ParseTestBundle([]TestEnvelope{
+		{
+			Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), time.Now()),
+			SignedBy: []string{"user", "server"},
+		},
+		{
+			Payload: schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+				Time:   time.Now(),
+				UserID: "alice",
+				Claims: map[string]interface{}{},
+			}),
+			SignedBy: []string{"server"},
+		},
+	}, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        "alice",
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServers: []schema.IdentityServer{
+				{ID: "idp", PublicKey: kp["server"].Public},
+			},
+		},
+	}
+
+	var vouchedBy []*schema.IdentityServer
+	err = RegisterRule("test.reportIdentityServer", func(in RuleInput) error {
+		vouchedBy = append(vouchedBy, in.IdentityServer)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := AuthenticationStageSpec
+	s.Rules = []string{"test.reportIdentityServer"}
+
+	err = s.Verify(facts, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := s.VerifyReport(facts, bundle)
+	assert.True(t, r.Valid)
+
+	if assert.Len(t, vouchedBy, 2) {
+		assert.Equal(t, vouchedBy[0], vouchedBy[1])
+		if assert.NotNil(t, vouchedBy[1]) {
+			assert.Equal(t, "idp", vouchedBy[1].ID)
+		}
+	}
+}
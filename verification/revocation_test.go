@@ -0,0 +1,76 @@
+package verification
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/clientactions"
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/serveractions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevokedUserKey(t *testing.T) {
+	ctx := context.Background()
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID, err := schema.KeyID(kp["user"].Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revocations := schema.NewMemoryRevocationList()
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+		RevokedKeys: revocations,
+	}
+
+	user := clientactions.New(&schema.LocalSigner{PrivateKey: kp["user"].Private})
+	initBundle, err := user.Init(ctx, kp["user"].Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := serveractions.New(&schema.LocalSigner{PrivateKey: kp["server"].Private})
+	grantBundle := makeDecisionBundle(t, kp, userID, schema.Decision{AutoAllow: true})
+	grantBundle, err = server.CreateGrant(ctx, grantBundle, schema.Grant{
+		Type:      schema.GrantTypeAWS,
+		ExpiresAt: time.Now().Add(time.Hour),
+		AWS:       &schema.AWSGrant{RoleARN: testRoleARN},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	initStage := InitStage{}
+	err = initStage.Verify(facts, initBundle)
+	assert.NoError(t, err)
+	grantStage := AutoApproveDecisionVerifier{}
+	err = grantStage.Verify(facts, grantBundle)
+	assert.NoError(t, err)
+
+	revokedAt := time.Now()
+	revocations.Revoke(schema.KeyRevocation{KeyID: keyID, RevokedAt: revokedAt, Reason: "laptop lost"})
+
+	err = initStage.Verify(facts, initBundle)
+	targetErr := &schema.ErrKeyRevoked{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, "laptop lost", targetErr.Reason)
+	assert.True(t, revokedAt.Equal(targetErr.RevokedAt))
+
+	// bundles for later stages are rejected too, even if they were signed before the key was revoked
+	err = grantStage.Verify(facts, grantBundle)
+	assert.ErrorAs(t, err, &targetErr)
+}
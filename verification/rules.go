@@ -0,0 +1,171 @@
+package verification
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+)
+
+// RuleInput is the bundle being verified by a rule.
+type RuleInput struct {
+	Facts  schema.Facts
+	Bundle schema.Bundle
+	// Payloads are the deserialized payloads of each envelope in the bundle
+	Payloads []schema.Payload
+	// IdentityServer is the trusted identity server which vouched for the
+	// user's authentication, if the bundle contains one
+	IdentityServer *schema.IdentityServer
+}
+
+// Rule is a bundle-level check which is run once each envelope
+// in the bundle has been verified.
+type Rule func(in RuleInput) error
+
+var rules = struct {
+	sync.RWMutex
+	m map[string]Rule
+}{
+	m: make(map[string]Rule),
+}
+
+// RegisterRule registers a rule so that it can be referred to by name in a Stage.
+func RegisterRule(name string, r Rule) error {
+	rules.Lock()
+	defer rules.Unlock()
+	if _, ok := rules.m[name]; ok {
+		return fmt.Errorf("rule %s is already registered", name)
+	}
+	rules.m[name] = r
+	return nil
+}
+
+func lookupRule(name string) (Rule, bool) {
+	rules.RLock()
+	defer rules.RUnlock()
+	r, ok := rules.m[name]
+	return r, ok
+}
+
+// Built-in rule names
+const (
+	// RuleDecisionAutoAllowed requires the decision to allow access without approval
+	RuleDecisionAutoAllowed = "decisionAutoAllowed"
+	// RuleDecisionRequiresApproval requires the decision to require approval
+	RuleDecisionRequiresApproval = "decisionRequiresApproval"
+	// RuleDecisionDenied requires the decision to deny access
+	RuleDecisionDenied = "decisionDenied"
+	// RuleApprovalMatchesRequest requires the approval to refer to the access request envelope
+	RuleApprovalMatchesRequest = "approvalMatchesRequest"
+	// RuleGrantActive requires the grant to give access at the time in the facts,
+	// taking into account any extension, revocation or expiry records in the bundle
+	RuleGrantActive = "grantActive"
+)
+
+func init() {
+	mustRegisterRule(RuleDecisionAutoAllowed, decisionAutoAllowed)
+	mustRegisterRule(RuleDecisionRequiresApproval, decisionRequiresApproval)
+	mustRegisterRule(RuleDecisionDenied, decisionDenied)
+	mustRegisterRule(RuleApprovalMatchesRequest, approvalMatchesRequest)
+	mustRegisterRule(RuleGrantActive, grantActive)
+}
+
+func mustRegisterRule(name string, r Rule) {
+	err := RegisterRule(name, r)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// decision returns the decision in the bundle.
+func (in RuleInput) decision() (schema.Decision, error) {
+	p, ok := schema.FindPayload(in.Payloads, types.PayloadDecision)
+	if !ok {
+		return schema.Decision{}, fmt.Errorf("bundle did not contain a %s envelope", types.PayloadDecision)
+	}
+	return p.(*schema.DecisionPayload).Decision, nil
+}
+
+// envelopeIndex returns the index of the last envelope with the given payload type.
+func (in RuleInput) envelopeIndex(t types.Payload) (int, bool) {
+	for i := len(in.Payloads) - 1; i >= 0; i-- {
+		if in.Payloads[i] != nil && in.Payloads[i].Type() == t {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func decisionAutoAllowed(in RuleInput) error {
+	d, err := in.decision()
+	if err != nil {
+		return err
+	}
+	if d.EffectiveOutcome() != schema.DecisionAllow {
+		return &schema.ErrInvalidPayloadContents{
+			Msg: "decision was not automatically approved",
+		}
+	}
+	return nil
+}
+
+func decisionRequiresApproval(in RuleInput) error {
+	d, err := in.decision()
+	if err != nil {
+		return err
+	}
+	if d.EffectiveOutcome() != schema.DecisionPendingApproval {
+		return &schema.ErrInvalidPayloadContents{
+			Msg: "decision did not require approval",
+		}
+	}
+	return nil
+}
+
+func decisionDenied(in RuleInput) error {
+	d, err := in.decision()
+	if err != nil {
+		return err
+	}
+	if d.EffectiveOutcome() != schema.DecisionDeny {
+		return &schema.ErrInvalidPayloadContents{
+			Msg: "decision did not deny access",
+		}
+	}
+	return nil
+}
+
+func approvalMatchesRequest(in RuleInput) error {
+	approvalIndex, ok := in.envelopeIndex(types.PayloadApproval)
+	if !ok {
+		return fmt.Errorf("bundle did not contain a %s envelope", types.PayloadApproval)
+	}
+	requestIndex, ok := in.envelopeIndex(types.PayloadAccessRequest)
+	if !ok {
+		return fmt.Errorf("bundle did not contain a %s envelope", types.PayloadAccessRequest)
+	}
+
+	approval := in.Payloads[approvalIndex].(*schema.ApprovalPayload).Approval
+	if approval.RequestDigest != in.Bundle[requestIndex].Digest() {
+		return &schema.ErrInvalidPayloadContents{
+			Msg: "approval does not refer to the access request",
+		}
+	}
+	return nil
+}
+
+func grantActive(in RuleInput) error {
+	state, err := schema.CurrentGrant(in.Payloads)
+	if err != nil {
+		return err
+	}
+	switch {
+	case state.Status == schema.GrantStatusRevoked:
+		return fmt.Errorf("grant was revoked at %s", state.EndedAt)
+	case state.Status == schema.GrantStatusExpired, in.Facts.Time.After(state.ExpiresAt):
+		return errors.New("grant is expired")
+	}
+	return nil
+}
@@ -0,0 +1,447 @@
+package verification
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+	"gopkg.in/yaml.v3"
+)
+
+// SignerRole identifies an actor which must sign an envelope.
+type SignerRole string
+
+const (
+	// RoleUser is the user described in the Facts
+	RoleUser SignerRole = "user"
+	// RoleIdentityServer is the identity server described in the Facts
+	RoleIdentityServer SignerRole = "identityServer"
+	// RoleApprover is the trusted approver named in an approval payload.
+	// If the bundle's decision requires approval from an approver group,
+	// the group's threshold of approvers must also have signed.
+	RoleApprover SignerRole = "approver"
+)
+
+// EnvelopeSpec describes the envelope expected at a position in a bundle.
+type EnvelopeSpec struct {
+	// Type is the expected payload type
+	Type types.Payload `json:"type" yaml:"type"`
+	// Signers are the roles which must have signed the envelope
+	Signers []SignerRole `json:"signers" yaml:"signers"`
+}
+
+// TrailingSpec describes envelopes which may follow a stage's expected envelopes,
+// such as the lifecycle records which are appended after a grant is created.
+type TrailingSpec struct {
+	// Types are the payload types which each trailing envelope may have
+	Types []types.Payload `json:"types" yaml:"types"`
+	// Signers are the roles which must have signed each trailing envelope
+	Signers []SignerRole `json:"signers" yaml:"signers"`
+}
+
+// VerifyOptions are options which apply to every stage. They are embedded in
+// Stage and in each of the verifiers for the built-in stages.
+type VerifyOptions struct {
+	// AcceptLegacySignatures allows envelopes signed over the raw payload,
+	// rather than the DSSE pre-authentication encoding, to be verified.
+	// Legacy bundles aren't hash-chained, so the chain isn't enforced either.
+	// This should only be enabled while migrating existing bundles.
+	AcceptLegacySignatures bool `json:"acceptLegacySignatures,omitempty" yaml:"acceptLegacySignatures,omitempty"`
+	// AcceptLegacyPayloads allows payloads with legacy payload types, such as v0.1 access
+	// requests, to be verified. Legacy payloads are upgraded to the current version and
+	// validated against its rules. This should only be enabled while migrating existing bundles.
+	AcceptLegacyPayloads bool `json:"acceptLegacyPayloads,omitempty" yaml:"acceptLegacyPayloads,omitempty"`
+}
+
+// Stage is a declarative specification of the bundle expected at a stage
+// of the access workflow. Stages may be defined as Go structs or loaded from
+// YAML or JSON using ParseStage, and are verified by the same engine.
+type Stage struct {
+	Name string `json:"name" yaml:"name"`
+	// Envelopes are the expected envelopes, in bundle order.
+	// Bundles must contain exactly this many envelopes, unless Trailing is set.
+	Envelopes []EnvelopeSpec `json:"envelopes" yaml:"envelopes"`
+	// Trailing, if set, allows any number of further envelopes to follow Envelopes.
+	Trailing *TrailingSpec `json:"trailing,omitempty" yaml:"trailing,omitempty"`
+	// Rules are the names of bundle-level rules which must pass,
+	// such as "decisionAutoAllowed". See RegisterRule.
+	Rules []string `json:"rules,omitempty" yaml:"rules,omitempty"`
+	// Expressions are CEL expression rules which must evaluate to true.
+	// They are evaluated after the named rules.
+	Expressions []ExpressionRule `json:"expressions,omitempty" yaml:"expressions,omitempty"`
+	// AuthFreshness, if set, limits how long before Facts.Time the user may have
+	// authenticated. Facts.Time must be set when verifying a stage with this policy.
+	AuthFreshness *schema.FreshnessPolicy `json:"authFreshness,omitempty" yaml:"authFreshness,omitempty"`
+	// RequiredClaims, if set, are conditions which the OIDC claims of the
+	// user's authentication must meet, such as requiring MFA.
+	RequiredClaims *schema.ClaimRequirements `json:"requiredClaims,omitempty" yaml:"requiredClaims,omitempty"`
+	VerifyOptions  `yaml:",inline"`
+}
+
+// ParseStage parses a stage specification from YAML or JSON.
+func ParseStage(data []byte) (*Stage, error) {
+	var s Stage
+	err := yaml.Unmarshal(data, &s)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks that the stage specification is well formed.
+func (s *Stage) Validate() error {
+	if len(s.Envelopes) == 0 {
+		return fmt.Errorf("stage %s must expect at least one envelope", s.Name)
+	}
+	for i, e := range s.Envelopes {
+		if e.Type == "" {
+			return fmt.Errorf("stage %s: envelope %d must have a type", s.Name, i)
+		}
+		err := validateSigners(e.Signers)
+		if err != nil {
+			return fmt.Errorf("stage %s: envelope %d %s", s.Name, i, err)
+		}
+	}
+	if s.Trailing != nil {
+		if len(s.Trailing.Types) == 0 {
+			return fmt.Errorf("stage %s: trailing envelopes must have at least one type", s.Name)
+		}
+		err := validateSigners(s.Trailing.Signers)
+		if err != nil {
+			return fmt.Errorf("stage %s: trailing envelopes %s", s.Name, err)
+		}
+	}
+	for _, name := range s.Rules {
+		if _, ok := lookupRule(name); !ok {
+			return fmt.Errorf("stage %s: unknown rule %s", s.Name, name)
+		}
+	}
+	_, err := NewExpressionEvaluator(s.Expressions)
+	if err != nil {
+		return fmt.Errorf("stage %s: %w", s.Name, err)
+	}
+	return nil
+}
+
+func validateSigners(signers []SignerRole) error {
+	if len(signers) == 0 {
+		return errors.New("must have at least one signer")
+	}
+	for _, role := range signers {
+		switch role {
+		case RoleUser, RoleIdentityServer, RoleApprover:
+		default:
+			return fmt.Errorf("has unknown signer role %s", role)
+		}
+	}
+	return nil
+}
+
+// Verify verifies a bundle against the stage specification.
+//
+// Rules:
+// - the bundle must contain exactly the expected number of envelopes,
+//   or at least that many if trailing envelopes are allowed
+// - each envelope must be linked to the previous envelope
+// - each envelope must have the expected payload type, be signed by
+//   each of the expected signer roles, and have valid payload contents
+// - each trailing envelope must have one of the trailing payload types
+//   and be signed by each of the trailing signer roles
+// - each payload must be consistent with the payloads before it
+// - an authentication must be vouched for by a trusted identity server,
+//   which must be the authentication's issuer if the server has one
+// - each of the stage's rules must pass
+// - each of the stage's expression rules must evaluate to true
+func (s *Stage) Verify(f schema.Facts, b schema.Bundle) error {
+	err := s.checkLength(b)
+	if err != nil {
+		return err
+	}
+
+	versions := signingVersions(s.AcceptLegacySignatures)
+
+	payloads := make([]schema.Payload, len(b))
+	in := RuleInput{
+		Facts:    f,
+		Bundle:   b,
+		Payloads: payloads,
+	}
+	for i := range b {
+		spec, err := s.envelopeSpec(b, i)
+		if err != nil {
+			return err
+		}
+		p, err := s.verifyEnvelopeSpec(f, b, payloads[:i], i, spec, versions)
+		if err != nil {
+			return err
+		}
+		payloads[i] = p
+
+		server, err := vouchingServerBySpec(f, spec, b[i], p, versions)
+		if err != nil {
+			return err
+		}
+		if server != nil {
+			in.IdentityServer = server
+		}
+	}
+
+	for _, name := range s.Rules {
+		rule, ok := lookupRule(name)
+		if !ok {
+			return fmt.Errorf("unknown rule %s", name)
+		}
+		err = rule(in)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(s.Expressions) > 0 {
+		ev, err := NewExpressionEvaluator(s.Expressions)
+		if err != nil {
+			return err
+		}
+		err = ev.Verify(f, payloads)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkLength checks that the bundle contains the number of envelopes expected by the stage.
+func (s *Stage) checkLength(b schema.Bundle) error {
+	if s.Trailing != nil {
+		if len(b) < len(s.Envelopes) {
+			return fmt.Errorf("bundle did not contain at least %d envelopes", len(s.Envelopes))
+		}
+		return nil
+	}
+	if len(b) != len(s.Envelopes) {
+		return fmt.Errorf("bundle did not contain %d envelopes", len(s.Envelopes))
+	}
+	return nil
+}
+
+// envelopeSpec returns the spec for the envelope at index i of the bundle.
+// Trailing envelopes are matched against the trailing spec by their payload type.
+func (s *Stage) envelopeSpec(b schema.Bundle, i int) (EnvelopeSpec, error) {
+	if i < len(s.Envelopes) {
+		return s.Envelopes[i], nil
+	}
+	if s.Trailing == nil {
+		return EnvelopeSpec{}, fmt.Errorf("stage %s does not allow envelope %d", s.Name, i)
+	}
+
+	var pt struct {
+		PayloadType types.Payload `json:"type"`
+	}
+	err := json.Unmarshal(b[i].Payload, &pt)
+	if err != nil {
+		return EnvelopeSpec{}, err
+	}
+	for _, t := range s.Trailing.Types {
+		if t == pt.PayloadType {
+			return EnvelopeSpec{Type: t, Signers: s.Trailing.Signers}, nil
+		}
+	}
+	return EnvelopeSpec{}, fmt.Errorf("envelope %d has type %s, which is not allowed to follow the envelopes of stage %s", i, pt.PayloadType, s.Name)
+}
+
+// deserializePayload deserializes a payload, accepting legacy payload
+// types if the stage has opted in to them.
+func (s *Stage) deserializePayload(payload []byte, expected types.Payload) (schema.Payload, error) {
+	return schema.DeserializePayloadWithOptions(payload, expected, schema.DeserializeOptions{AcceptLegacy: s.AcceptLegacyPayloads})
+}
+
+// verifyEnvelopeSpec verifies the envelope at index i of the bundle against its spec.
+// previous are the payloads of the envelopes before it, which have already been verified.
+func (s *Stage) verifyEnvelopeSpec(f schema.Facts, b schema.Bundle, previous []schema.Payload, i int, spec EnvelopeSpec, versions []schema.SigningVersion) (schema.Payload, error) {
+	e := b[i]
+	payload, err := s.deserializePayload(e.Payload, spec.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	var req signerRequirement
+	for _, role := range spec.Signers {
+		r, err := resolveRole(role, f, e, previous, payload)
+		if err != nil {
+			return nil, err
+		}
+		req.signers = append(req.signers, r.signers...)
+		req.thresholds = append(req.thresholds, r.thresholds...)
+	}
+
+	// validate signatures
+	err = e.VerifySignaturesWithVersions(req.signers, versions...)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range req.thresholds {
+		_, err = e.VerifyThresholdWithVersions(policy, versions...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// validate the link to the previous envelope
+	err = verifyLink(b, i, req, s.AcceptLegacySignatures)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate payload contents
+	err = s.validateContents(f, payload, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// validateContents validates a payload's contents and its consistency with the
+// payloads before it, along with any content checks which are configured on the stage.
+func (s *Stage) validateContents(f schema.Facts, p schema.Payload, previous []schema.Payload) error {
+	err := p.ValidateContents(f)
+	if err != nil {
+		return err
+	}
+
+	if bv, ok := p.(schema.BundleValidator); ok {
+		err = bv.ValidateBundle(f, previous)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateFreshness(f, p, s.AuthFreshness)
+	if err != nil {
+		return err
+	}
+
+	if auth, ok := p.(*schema.AuthenticatedPayload); ok && s.RequiredClaims != nil {
+		err = auth.ValidateClaims(*s.RequiredClaims)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateFreshness checks that an authentication isn't dated in the future and, if there is a
+// freshness policy, that it is fresh. The future-date check uses the policy's clock skew, so it
+// can be tolerated beyond schema.DefaultClockSkew. Without a policy, the default clock skew is used,
+// and authentications are only checked if the Facts include the time.
+func validateFreshness(f schema.Facts, p schema.Payload, policy *schema.FreshnessPolicy) error {
+	auth, ok := p.(*schema.AuthenticatedPayload)
+	if !ok {
+		return nil
+	}
+	if policy == nil {
+		if f.Time.IsZero() {
+			return nil
+		}
+		return auth.ValidateNotFuture(f.Time, schema.DefaultClockSkew)
+	}
+	if f.Time.IsZero() {
+		return errors.New("facts must include the time to check authentication freshness")
+	}
+	return auth.ValidateFreshness(f.Time, *policy)
+}
+
+// signerRequirement is the set of signatures required from a signer role.
+type signerRequirement struct {
+	// signers must all sign the envelope
+	signers []schema.ExpectedSigner
+	// thresholds must also each be met
+	thresholds []schema.ThresholdPolicy
+}
+
+// resolveRole resolves a signer role to the signatures it requires on envelope e, which
+// contains payload p. If the Facts include a trust store, the signatures must be made with
+// keys which were valid at the time attested to by the bundle.
+func resolveRole(role SignerRole, f schema.Facts, e schema.Envelope, previous []schema.Payload, p schema.Payload) (signerRequirement, error) {
+	t, err := attestedAt(f, previous, p)
+	if err != nil {
+		return signerRequirement{}, err
+	}
+	switch role {
+	case RoleUser:
+		req := signerRequirement{signers: []schema.ExpectedSigner{f.Actors.User.Signer()}}
+		return applyTrustStore(f.TrustStore, req, t)
+	case RoleIdentityServer:
+		return resolveIdentityServer(f, e, t)
+	case RoleApprover:
+		req, err := resolveApprover(f, previous, p)
+		if err != nil {
+			return signerRequirement{}, err
+		}
+		return applyTrustStore(f.TrustStore, req, t)
+	default:
+		return signerRequirement{}, fmt.Errorf("unknown signer role %s", role)
+	}
+}
+
+// resolveApprover resolves the approver role for an approval payload. The approver named in
+// the payload must sign. If the decision requires approval from an approver group, the named
+// approver must be a member of the group and the group's threshold must also be met.
+func resolveApprover(f schema.Facts, previous []schema.Payload, p schema.Payload) (signerRequirement, error) {
+	approvalPayload, ok := p.(*schema.ApprovalPayload)
+	if !ok {
+		return signerRequirement{}, fmt.Errorf("the %s role can only sign %s payloads", RoleApprover, types.PayloadApproval)
+	}
+	approval := approvalPayload.Approval
+
+	// the approver must be trusted before we know which key should have signed the envelope
+	approver, ok := f.Actors.Approver(approval.ApproverID)
+	if !ok {
+		return signerRequirement{}, &schema.ErrInvalidPayloadContents{
+			Msg: fmt.Sprintf("%s is not a trusted approver", approval.ApproverID),
+		}
+	}
+	req := signerRequirement{signers: []schema.ExpectedSigner{approver.Signer()}}
+
+	decisionPayload, ok := schema.FindPayload(previous, types.PayloadDecision)
+	if !ok {
+		return req, nil
+	}
+	d := decisionPayload.(*schema.DecisionPayload).Decision
+	if d.ApproverGroup == "" {
+		return req, nil
+	}
+
+	group, ok := f.Actors.ApproverGroup(d.ApproverGroup)
+	if !ok {
+		return signerRequirement{}, fmt.Errorf("approver group %s not found", d.ApproverGroup)
+	}
+	if !contains(group.ApproverIDs, approval.ApproverID) {
+		return signerRequirement{}, &schema.ErrInvalidPayloadContents{
+			Msg: fmt.Sprintf("%s is not a member of approver group %s", approval.ApproverID, group.Name),
+		}
+	}
+	policy, err := f.Actors.ApprovalPolicy(group)
+	if err != nil {
+		return signerRequirement{}, err
+	}
+	req.thresholds = []schema.ThresholdPolicy{policy}
+	return req, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
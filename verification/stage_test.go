@@ -0,0 +1,298 @@
+package verification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStageYAML(t *testing.T) {
+	stage, err := ParseStage([]byte(`
+name: accessRequest
+envelopes:
+  - type: granted.dev/Init/v0.1
+    signers: [user, identityServer]
+  - type: granted.dev/Authenticated/v0.1
+    signers: [user, identityServer]
+  - type: granted.dev/AccessRequest/v0.2
+    signers: [user]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &AccessRequestStageSpec, stage)
+}
+
+func TestParseStageJSON(t *testing.T) {
+	stage, err := ParseStage([]byte(`{
+		"name": "autoApproveDecision",
+		"envelopes": [
+			{"type": "granted.dev/Init/v0.1", "signers": ["user", "identityServer"]},
+			{"type": "granted.dev/Authenticated/v0.1", "signers": ["user", "identityServer"]},
+			{"type": "granted.dev/AccessRequest/v0.2", "signers": ["user", "identityServer"]},
+			{"type": "granted.dev/Decision/v0.2", "signers": ["identityServer"]},
+			{"type": "granted.dev/GrantCreated/v0.2", "signers": ["identityServer"]}
+		],
+		"rules": ["decisionAutoAllowed", "grantActive"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &AutoApproveDecisionStageSpec, stage)
+}
+
+func TestParseStageVerifyOptions(t *testing.T) {
+	for _, spec := range []string{
+		"name: legacy\nenvelopes: [{type: granted.dev/Init/v0.1, signers: [user]}]\nacceptLegacySignatures: true\n",
+		`{"name": "legacy", "envelopes": [{"type": "granted.dev/Init/v0.1", "signers": ["user"]}], "acceptLegacySignatures": true}`,
+	} {
+		stage, err := ParseStage([]byte(spec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, stage.AcceptLegacySignatures)
+	}
+}
+
+func TestParseStageRejectsInvalidSpecs(t *testing.T) {
+	_, err := ParseStage([]byte(`
+name: invalid
+envelopes:
+  - type: granted.dev/Init/v0.1
+    signers: [admin]
+`))
+	assert.EqualError(t, err, "stage invalid: envelope 0 has unknown signer role admin")
+
+	_, err = ParseStage([]byte(`
+name: invalid
+envelopes:
+  - type: granted.dev/Init/v0.1
+    signers: [user]
+rules: [doesNotExist]
+`))
+	assert.EqualError(t, err, "stage invalid: unknown rule doesNotExist")
+}
+
+// Stages can be defined for bundles other than the built-in ones,
+// such as verifying the authenticated envelope alone.
+func TestCustomStage(t *testing.T) {
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testBundle := []TestEnvelope{
+		{
+			Payload: schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+				Time:   time.Now(),
+				UserID: userID,
+				Claims: map[string]interface{}{},
+			}),
+			SignedBy: []string{"server"},
+		},
+	}
+	bundle, err := ParseTestBundle(testBundle, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+
+	stage := Stage{
+		Name: "authenticated",
+		Envelopes: []EnvelopeSpec{
+			{Type: types.PayloadAuthenticated, Signers: []SignerRole{RoleIdentityServer}},
+		},
+	}
+	err = stage.Verify(facts, bundle)
+	assert.NoError(t, err)
+
+	stage.Envelopes[0].Signers = []SignerRole{RoleUser, RoleIdentityServer}
+	err = stage.Verify(facts, bundle)
+	targetErr := &schema.ErrMissingSignatures{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, []schema.ExpectedSigner{facts.Actors.User.Signer()}, targetErr.Missing)
+}
+
+func TestStageAuthFreshness(t *testing.T) {
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loginTime := time.Now().Add(-2 * time.Hour)
+
+	bundle, err := ParseTestBundle([]TestEnvelope{
+		{
+			Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), loginTime),
+			SignedBy: []string{"user", "server"},
+		},
+		{
+			Payload: schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+				Time:   loginTime,
+				UserID: userID,
+				Claims: map[string]interface{}{},
+			}),
+			SignedBy: []string{"server"},
+		},
+	}, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+		Time: time.Now(),
+	}
+
+	stage, err := ParseStage([]byte(`
+name: authentication
+envelopes:
+  - type: granted.dev/Init/v0.1
+    signers: [user, identityServer]
+  - type: granted.dev/Authenticated/v0.1
+    signers: [identityServer]
+authFreshness:
+  maxAge: 1h
+  clockSkew: 1m
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &schema.FreshnessPolicy{MaxAge: time.Hour, ClockSkew: time.Minute}, stage.AuthFreshness)
+
+	err = stage.Verify(facts, bundle)
+	targetErr := &schema.ErrStaleAuthentication{}
+	assert.ErrorAs(t, err, &targetErr)
+
+	stage.AuthFreshness.MaxAge = 3 * time.Hour
+	err = stage.Verify(facts, bundle)
+	assert.NoError(t, err)
+}
+
+// The stage's clock skew applies to authentications dated in the future,
+// and may be larger than the default clock skew.
+func TestStageAuthFreshnessClockSkew(t *testing.T) {
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	loginTime := now.Add(7 * time.Minute)
+
+	bundle, err := ParseTestBundle([]TestEnvelope{
+		{
+			Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), loginTime),
+			SignedBy: []string{"user", "server"},
+		},
+		{
+			Payload: schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+				Time:   loginTime,
+				UserID: userID,
+				Claims: map[string]interface{}{},
+			}),
+			SignedBy: []string{"user", "server"},
+		},
+	}, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User:           schema.User{ID: userID, PublicKey: kp["user"].Public},
+			IdentityServer: schema.IdentityServer{PublicKey: kp["server"].Public},
+		},
+		Time: now,
+	}
+
+	stage := AuthenticationStageSpec
+	stage.AuthFreshness = &schema.FreshnessPolicy{MaxAge: time.Hour, ClockSkew: 10 * time.Minute}
+	assert.NoError(t, stage.Verify(facts, bundle))
+
+	// without a freshness policy, the default clock skew applies
+	err = (&AuthenticationStage{}).Verify(facts, bundle)
+	assert.ErrorAs(t, err, new(*schema.ErrFutureAuthentication))
+}
+
+func TestStageRequiredClaims(t *testing.T) {
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := ParseTestBundle([]TestEnvelope{
+		{
+			Payload: schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+				Time:   time.Now(),
+				UserID: userID,
+				Claims: map[string]interface{}{
+					"iss": "https://idp.example.com",
+					"amr": []interface{}{"pwd"},
+				},
+			}),
+			SignedBy: []string{"server"},
+		},
+	}, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+
+	stage, err := ParseStage([]byte(`
+name: authentication
+envelopes:
+  - type: granted.dev/Authenticated/v0.1
+    signers: [identityServer]
+requiredClaims:
+  issuers: [https://idp.example.com]
+  amr: [mfa]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = stage.Verify(facts, bundle)
+	targetErr := &schema.ErrMissingClaimValue{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, "amr", targetErr.Claim)
+
+	stage.RequiredClaims.AMR = []string{"pwd"}
+	err = stage.Verify(facts, bundle)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,75 @@
+package verification
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+)
+
+// attestedAt returns the time which the envelope containing p was signed at, as attested to by
+// the payload or the payloads before it. If the bundle doesn't attest to a time, Facts.Time is used.
+//
+// A payload can't attest to a time before the envelopes before it were signed, allowing for
+// clock skew, so that a key which is no longer valid can't be used by backdating a payload.
+func attestedAt(f schema.Facts, previous []schema.Payload, p schema.Payload) (time.Time, error) {
+	// copy previous so that appending to it can't overwrite the caller's payloads
+	payloads := append(previous[:len(previous):len(previous)], p)
+	t, ok := schema.AttestedAt(payloads...)
+	if !ok {
+		return f.Time, nil
+	}
+	if prev, ok := schema.AttestedAt(previous...); ok && t.Before(prev.Add(-schema.DefaultClockSkew)) {
+		return time.Time{}, &schema.ErrInconsistentBundle{
+			Msg: fmt.Sprintf("%s payload was signed at %s, before the payload before it was signed at %s", p.Type(), t.Format(time.RFC3339), prev.Format(time.RFC3339)),
+		}
+	}
+	return t, nil
+}
+
+// applyTrustStore replaces the signers in a requirement with their keys in the trust store
+// which were valid at t. A signer with several valid keys may sign with any one of them.
+func applyTrustStore(store *schema.TrustStore, req signerRequirement, t time.Time) (signerRequirement, error) {
+	if store == nil {
+		return req, nil
+	}
+
+	var out signerRequirement
+	for _, signer := range req.signers {
+		signers, err := store.SignersAt(signer, t)
+		if err != nil {
+			return signerRequirement{}, err
+		}
+		if len(signers) == 1 {
+			out.signers = append(out.signers, signers[0])
+			continue
+		}
+		out.thresholds = append(out.thresholds, schema.ThresholdPolicy{Candidates: signers, Threshold: 1})
+	}
+
+	for _, policy := range req.thresholds {
+		expanded := schema.ThresholdPolicy{Threshold: policy.Threshold}
+		for _, candidate := range policy.Candidates {
+			signers, err := trustedSignersAt(store, candidate, t)
+			if err != nil {
+				return signerRequirement{}, err
+			}
+			expanded.Candidates = append(expanded.Candidates, signers...)
+		}
+		out.thresholds = append(out.thresholds, expanded)
+	}
+
+	return out, nil
+}
+
+// trustedSignersAt returns the keys which a candidate signer may have signed with at t.
+// Candidates without any valid keys can't sign, so no signers are returned for them.
+func trustedSignersAt(store *schema.TrustStore, candidate schema.ExpectedSigner, t time.Time) ([]schema.ExpectedSigner, error) {
+	signers, err := store.SignersAt(candidate, t)
+	var noKeys *schema.ErrNoValidKeys
+	if errors.As(err, &noKeys) {
+		return nil, nil
+	}
+	return signers, err
+}
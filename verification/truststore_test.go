@@ -0,0 +1,301 @@
+package verification
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+// makeAuthenticationBundle returns a bundle for the authentication stage in which the
+// user authenticated at loginTime, signed by the given identity server key.
+func makeAuthenticationBundle(t *testing.T, kp KeyPairMap, userID string, server string, loginTime time.Time) schema.Bundle {
+	bundle, err := ParseTestBundle([]TestEnvelope{
+		{
+			Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), loginTime),
+			SignedBy: []string{"user", server},
+		},
+		{
+			Payload: schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+				Time:   loginTime,
+				UserID: userID,
+				Claims: map[string]interface{}{},
+			}),
+			SignedBy: []string{server},
+		},
+	}, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func TestTrustStoreKeyRotation(t *testing.T) {
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "oldServer", "newServer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rotatedAt := now.Add(-24 * time.Hour)
+
+	store := schema.NewTrustStore()
+	store.Add(schema.ActorIdentityServer, schema.TrustedKey{PublicKey: kp["oldServer"].Public, NotAfter: rotatedAt})
+	store.Add(schema.ActorIdentityServer, schema.TrustedKey{PublicKey: kp["newServer"].Public, NotBefore: rotatedAt})
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+		},
+		Time:       now,
+		TrustStore: store,
+	}
+
+	a := AuthenticationStage{}
+
+	// bundles signed before the rotation are verified using the old key
+	lastQuarter := makeAuthenticationBundle(t, kp, userID, "oldServer", now.AddDate(0, -3, 0))
+	err = a.Verify(facts, lastQuarter)
+	assert.NoError(t, err)
+
+	current := makeAuthenticationBundle(t, kp, userID, "newServer", now)
+	err = a.Verify(facts, current)
+	assert.NoError(t, err)
+
+	// the old key is no longer valid after the rotation
+	signedWithOldKey := makeAuthenticationBundle(t, kp, userID, "oldServer", now)
+	err = a.Verify(facts, signedWithOldKey)
+	assert.ErrorAs(t, err, new(*schema.ErrMissingSignatures))
+
+	// revoked keys aren't valid at any time
+	store.Revoke(schema.ActorIdentityServer, kp["oldServer"].Public)
+	err = a.Verify(facts, lastQuarter)
+	targetErr := &schema.ErrNoValidKeys{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, schema.ActorIdentityServer, targetErr.Actor)
+}
+
+func TestTrustStoreUserKeys(t *testing.T) {
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	store := schema.NewTrustStore()
+	store.Add(schema.ActorUser, schema.TrustedKey{PublicKey: kp["user"].Public, NotBefore: now.Add(-time.Hour)})
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{ID: userID},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+		Time:       now,
+		TrustStore: store,
+	}
+
+	a := AuthenticationStage{}
+	err = a.Verify(facts, makeAuthenticationBundle(t, kp, userID, "server", now))
+	assert.NoError(t, err)
+
+	// the user's key wasn't yet trusted
+	err = a.Verify(facts, makeAuthenticationBundle(t, kp, userID, "server", now.Add(-2*time.Hour)))
+	targetErr := &schema.ErrNoValidKeys{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, schema.ActorUser, targetErr.Actor)
+}
+
+// makeApprovedBundle returns a bundle for an approved grant in which the user authenticated at
+// loginTime, and the request was approved at approvedAt with the given approver key.
+func makeApprovedBundle(t *testing.T, kp KeyPairMap, approver string, loginTime, approvedAt time.Time) schema.Bundle {
+	bundle, err := ParseTestBundle([]TestEnvelope{
+		{
+			Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), loginTime),
+			SignedBy: []string{"user", "server"},
+		},
+		{
+			Payload: schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+				Time:   loginTime,
+				UserID: "alice",
+				Claims: map[string]interface{}{},
+			}),
+			SignedBy: []string{"user", "server"},
+		},
+		{
+			Payload:  schema.NewAccessRequestMessage(schema.AccessRequest{Role: testRoleARN, Reason: "test", Duration: 4 * time.Hour}),
+			SignedBy: []string{"user", "server"},
+		},
+		{
+			Payload:  schema.NewDecisionPayload(schema.Decision{Outcome: schema.DecisionPendingApproval, DecidedAt: loginTime}),
+			SignedBy: []string{"server"},
+		},
+	}, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	approval, err := schema.NewChainedEnvelope(bundle, schema.NewApprovalPayload(schema.Approval{
+		ApproverID:    "bob",
+		RequestDigest: bundle[2].Digest(),
+		ApprovedAt:    approvedAt,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = approval.Sign(context.Background(), &schema.LocalSigner{PrivateKey: kp[approver].Private})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle = append(bundle, approval)
+
+	grant, err := schema.NewChainedEnvelope(bundle, schema.NewGrantCreatedPayload(schema.Grant{
+		Type:      schema.GrantTypeAWS,
+		ExpiresAt: loginTime.Add(3 * time.Hour),
+		CreatedAt: approvedAt,
+		AWS:       &schema.AWSGrant{RoleARN: testRoleARN},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = grant.Sign(context.Background(), &schema.LocalSigner{PrivateKey: kp["server"].Private})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(bundle, grant)
+}
+
+func TestTrustStoreRotationBetweenStages(t *testing.T) {
+	kp, err := MakeTestKeyPairs([]string{"user", "server", "oldApprover", "newApprover"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	loginTime := now.Add(-2 * time.Hour)
+	// the approver's key was rotated after the user logged in, but before they approved the request
+	rotatedAt := now.Add(-time.Hour)
+
+	approver := schema.Approver{ID: "bob"}
+	store := schema.NewTrustStore()
+	store.Add(approver.Signer().Name, schema.TrustedKey{PublicKey: kp["oldApprover"].Public, NotAfter: rotatedAt})
+	store.Add(approver.Signer().Name, schema.TrustedKey{PublicKey: kp["newApprover"].Public, NotBefore: rotatedAt})
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User:           schema.User{ID: "alice", PublicKey: kp["user"].Public},
+			IdentityServer: schema.IdentityServer{PublicKey: kp["server"].Public},
+			Approvers:      []schema.Approver{approver},
+		},
+		Time:       now,
+		TrustStore: store,
+	}
+
+	v := ApprovedDecisionVerifier{}
+
+	// the approval is verified using the keys which were valid when it was signed,
+	// rather than when the user logged in
+	err = v.Verify(facts, makeApprovedBundle(t, kp, "newApprover", loginTime, now))
+	assert.NoError(t, err)
+
+	err = v.Verify(facts, makeApprovedBundle(t, kp, "oldApprover", loginTime, now))
+	assert.ErrorAs(t, err, new(*schema.ErrMissingSignatures))
+
+	// the approval was signed with the old key before it was rotated
+	err = v.Verify(facts, makeApprovedBundle(t, kp, "oldApprover", loginTime, rotatedAt.Add(-time.Minute)))
+	assert.NoError(t, err)
+
+	// the old key can't be used by backdating the approval to before the user logged in
+	err = v.Verify(facts, makeApprovedBundle(t, kp, "oldApprover", loginTime, loginTime.Add(-time.Hour)))
+	assert.ErrorAs(t, err, new(*schema.ErrInconsistentBundle))
+}
+
+func TestTrustStoreRotationBeforeExtension(t *testing.T) {
+	kp, err := MakeTestKeyPairs([]string{"user", "oldServer", "newServer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	loginTime := now.Add(-3 * time.Hour)
+	// the identity server's key was rotated after the grant was created, but before it was extended
+	rotatedAt := now.Add(-time.Hour)
+
+	store := schema.NewTrustStore()
+	store.Add(schema.ActorIdentityServer, schema.TrustedKey{PublicKey: kp["oldServer"].Public, NotAfter: rotatedAt})
+	store.Add(schema.ActorIdentityServer, schema.TrustedKey{PublicKey: kp["newServer"].Public, NotBefore: rotatedAt})
+
+	facts := schema.Facts{
+		Actors:     schema.Actors{User: schema.User{ID: "alice", PublicKey: kp["user"].Public}},
+		Time:       now,
+		TrustStore: store,
+	}
+
+	grant, err := ParseTestBundle([]TestEnvelope{
+		{
+			Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), loginTime),
+			SignedBy: []string{"user", "oldServer"},
+		},
+		{
+			Payload: schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+				Time:   loginTime,
+				UserID: "alice",
+				Claims: map[string]interface{}{},
+			}),
+			SignedBy: []string{"user", "oldServer"},
+		},
+		{
+			Payload:  schema.NewAccessRequestMessage(schema.AccessRequest{Role: testRoleARN, Reason: "test", Duration: 4 * time.Hour}),
+			SignedBy: []string{"user", "oldServer"},
+		},
+		{
+			Payload:  schema.NewDecisionPayload(schema.Decision{Outcome: schema.DecisionAllow, DecidedAt: loginTime}),
+			SignedBy: []string{"oldServer"},
+		},
+		{
+			Payload: schema.NewGrantCreatedPayload(schema.Grant{
+				Type:      schema.GrantTypeAWS,
+				ExpiresAt: now.Add(time.Hour),
+				CreatedAt: loginTime,
+				AWS:       &schema.AWSGrant{RoleARN: testRoleARN},
+			}),
+			SignedBy: []string{"oldServer"},
+		},
+	}, kp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extend := func(server string) schema.Bundle {
+		env, err := schema.NewChainedEnvelope(grant, schema.NewGrantExtendedPayload(schema.GrantExtension{
+			ExtendedAt: now,
+			ExpiresAt:  now.Add(2 * time.Hour),
+			ExtendedBy: "bob",
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = env.Sign(context.Background(), &schema.LocalSigner{PrivateKey: kp[server].Private})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(grant[:len(grant):len(grant)], env)
+	}
+
+	v := AutoApproveGrantLifecycleVerifier{}
+	err = v.Verify(facts, grant)
+	assert.NoError(t, err)
+
+	// the extension is verified using the keys which were valid when it was made,
+	// rather than when the grant was created
+	err = v.Verify(facts, extend("newServer"))
+	assert.NoError(t, err)
+
+	err = v.Verify(facts, extend("oldServer"))
+	assert.ErrorAs(t, err, new(*schema.ErrMissingSignatures))
+}
@@ -25,7 +25,7 @@ func TestAccessRequest(t *testing.T) {
 
 	testBundle := []TestEnvelope{
 		{
-			Payload:  schema.NewInitMessage(mustSerializeECDSAPublicKey(kp["user"].Public)),
+			Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), time.Now()),
 			SignedBy: []string{"user", "server"},
 		},
 		{
@@ -43,8 +43,9 @@ func TestAccessRequest(t *testing.T) {
 	}
 
 	outBundle, err := user.RequestAccess(ctx, bundle, schema.AccessRequest{
-		Role:   "test-role",
-		Reason: "test",
+		Role:     "test-role",
+		Reason:   "test",
+		Duration: time.Hour,
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -60,3 +61,142 @@ func TestAccessRequest(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// An access request envelope taken from another bundle must not verify,
+// even though it is validly signed by the user.
+func TestAccessRequestSplicedEnvelope(t *testing.T) {
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	makeBundle := func(loginTime time.Time, role string) schema.Bundle {
+		testBundle := []TestEnvelope{
+			{
+				Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), time.Now()),
+				SignedBy: []string{"user", "server"},
+			},
+			{
+				Payload: schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+					Time:   loginTime,
+					UserID: userID,
+					Claims: map[string]interface{}{},
+				}),
+				SignedBy: []string{"user", "server"},
+			},
+			{
+				Payload: schema.NewAccessRequestMessage(schema.AccessRequest{
+					Role:     role,
+					Reason:   "test",
+					Duration: time.Hour,
+				}),
+				SignedBy: []string{"user"},
+			},
+		}
+		bundle, err := ParseTestBundle(testBundle, kp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bundle
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+
+	a := makeBundle(time.Now(), "test-role")
+	b := makeBundle(time.Now().Add(-time.Hour), "admin-role")
+
+	s := AccessRequestStage{}
+	err = s.Verify(facts, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spliced := schema.Bundle{a[0], a[1], b[2]}
+	err = s.Verify(facts, spliced)
+	targetErr := &schema.ErrBrokenChain{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, 2, targetErr.Index)
+
+	// accepting legacy signatures must not turn off the chain for envelopes signed using DSSE
+	legacy := AccessRequestStage{VerifyOptions{AcceptLegacySignatures: true}}
+	err = legacy.Verify(facts, spliced)
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, 2, targetErr.Index)
+
+	// envelopes signed over the raw payload predate chaining, so their links aren't checked
+	legacySigned := b[2]
+	sig, err := (&schema.LocalSigner{PrivateKey: kp["user"].Private}).Sign(context.Background(), legacySigned.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacySigned.Signatures = []schema.Signature{{Sig: sig}}
+	assert.NoError(t, legacy.Verify(facts, schema.Bundle{a[0], a[1], legacySigned}))
+}
+
+// A user must not be able to avoid the v0.2 access request rules, such as the
+// maximum duration, by signing a request with the v0.1 payload type.
+func TestAccessRequestV01Downgrade(t *testing.T) {
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	makeBundle := func(req schema.AccessRequest) schema.Bundle {
+		testBundle := []TestEnvelope{
+			{
+				Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), time.Now()),
+				SignedBy: []string{"user", "server"},
+			},
+			{
+				Payload: schema.NewAuthenticatedMessage(schema.AuthMessageOpts{
+					Time:   time.Now(),
+					UserID: userID,
+					Claims: map[string]interface{}{},
+				}),
+				SignedBy: []string{"user", "server"},
+			},
+			{
+				Payload:  &schema.AccessRequestPayload{Request: req, PayloadType: types.PayloadAccessRequestV01},
+				SignedBy: []string{"user"},
+			},
+		}
+		bundle, err := ParseTestBundle(testBundle, kp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bundle
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User:           schema.User{ID: userID, PublicKey: kp["user"].Public},
+			IdentityServer: schema.IdentityServer{PublicKey: kp["server"].Public},
+		},
+	}
+	legacy := AccessRequestStage{VerifyOptions{AcceptLegacyPayloads: true}}
+
+	v01 := makeBundle(schema.AccessRequest{Role: "test-role", Reason: "test"})
+	err = (&AccessRequestStage{}).Verify(facts, v01)
+	assert.ErrorAs(t, err, new(*schema.ErrLegacyPayloadType))
+	assert.NoError(t, legacy.Verify(facts, v01))
+
+	inflated := makeBundle(schema.AccessRequest{Role: "test-role", Reason: "test", Duration: 100 * time.Hour})
+	err = legacy.Verify(facts, inflated)
+	assert.ErrorAs(t, err, new(*schema.ErrInvalidPayloadContents))
+
+	unexplained := makeBundle(schema.AccessRequest{Role: "test-role"})
+	err = legacy.Verify(facts, unexplained)
+	assert.EqualError(t, err, "invalid payload contents: access request did not specify a reason")
+}
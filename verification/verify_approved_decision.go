@@ -0,0 +1,59 @@
+package verification
+
+import (
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+)
+
+// ApprovedDecisionStageSpec verifies payloads for a grant which was
+// created after a decision requiring approval.
+//
+// Rules:
+// - there must only be 6 envelopes
+// - each envelope must be linked to the previous envelope
+// - first envelope:
+// 		type: must be granted.dev/Init/v0.1
+//		signatures: user and identity server
+//		payload: public key must match user and must not be revoked
+// - second envelope:
+// 		type: must be granted.dev/Authenticated/v0.1
+//		signatures: user and identity server
+//		payload: user ID must match user
+// - third envelope:
+// 		type: must be granted.dev/AccessRequest/v0.2
+//		signatures: user and identity server
+//		payload:
+// - fourth envelope:
+// 		type: must be granted.dev/Decision/v0.2
+//		signatures: identity server
+//		payload: decision must require approval
+// - fifth envelope:
+// 		type: must be granted.dev/Approval/v0.1
+//		signatures: the approver named in the payload, or the threshold of the
+//		            approver group named in the decision
+//		payload: approver must be trusted, request digest must match the third envelope
+// - sixth envelope:
+// 		type: must be granted.dev/GrantCreated/v0.2
+//		signatures: identity server
+//		payload: grant must be for the requested role and must not have expired
+var ApprovedDecisionStageSpec = Stage{
+	Name: "approvedDecision",
+	Envelopes: []EnvelopeSpec{
+		{Type: types.PayloadInit, Signers: []SignerRole{RoleUser, RoleIdentityServer}},
+		{Type: types.PayloadAuthenticated, Signers: []SignerRole{RoleUser, RoleIdentityServer}},
+		{Type: types.PayloadAccessRequest, Signers: []SignerRole{RoleUser, RoleIdentityServer}},
+		{Type: types.PayloadDecision, Signers: []SignerRole{RoleIdentityServer}},
+		{Type: types.PayloadApproval, Signers: []SignerRole{RoleApprover}},
+		{Type: types.PayloadGrantCreated, Signers: []SignerRole{RoleIdentityServer}},
+	},
+	Rules: []string{RuleDecisionRequiresApproval, RuleApprovalMatchesRequest, RuleGrantActive},
+}
+
+type ApprovedDecisionVerifier struct {
+	VerifyOptions
+}
+
+// Verify payloads for an approved grant. See ApprovedDecisionStageSpec for the rules.
+func (s *ApprovedDecisionVerifier) Verify(f schema.Facts, b schema.Bundle) error {
+	return verifyWithSpec(ApprovedDecisionStageSpec, s.VerifyOptions, f, b)
+}
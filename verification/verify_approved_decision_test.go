@@ -0,0 +1,261 @@
+package verification
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/approveractions"
+	"github.com/common-fate/attestations/clientactions"
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/serveractions"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRoleARN = "arn:aws:iam::123456789012:role/test-role"
+
+// makeDecisionBundle runs through the actor flow up to and including the DECISION envelope.
+func makeDecisionBundle(t *testing.T, kp KeyPairMap, userID string, d schema.Decision) schema.Bundle {
+	ctx := context.Background()
+	user := clientactions.New(&schema.LocalSigner{PrivateKey: kp["user"].Private})
+	server := serveractions.New(&schema.LocalSigner{PrivateKey: kp["server"].Private})
+
+	bundle, err := user.Init(ctx, kp["user"].Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = server.Authenticate(ctx, bundle[0], schema.AuthMessageOpts{
+		Time:   time.Now(),
+		UserID: userID,
+		Claims: map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = user.CounterSignAuth(ctx, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = user.RequestAccess(ctx, bundle, schema.AccessRequest{
+		Role:     testRoleARN,
+		Reason:   "test",
+		Duration: 2 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = server.Decision(ctx, bundle, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func TestApprovedDecision(t *testing.T) {
+	ctx := context.Background()
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "server", "approver"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := serveractions.New(&schema.LocalSigner{PrivateKey: kp["server"].Private})
+	approver := approveractions.New("bob", &schema.LocalSigner{PrivateKey: kp["approver"].Private})
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+			Approvers: []schema.Approver{
+				{
+					ID:        "bob",
+					PublicKey: kp["approver"].Public,
+				},
+			},
+		},
+	}
+
+	bundle := makeDecisionBundle(t, kp, userID, schema.Decision{RequireApproval: true})
+	bundle, err = approver.Approve(ctx, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = server.CreateGrant(ctx, bundle, schema.Grant{
+		Type:      schema.GrantTypeAWS,
+		ExpiresAt: time.Now().Add(time.Hour),
+		AWS:       &schema.AWSGrant{RoleARN: testRoleARN},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := ApprovedDecisionVerifier{}
+	err = v.Verify(facts, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the approver must be in the trusted set of approvers
+	facts.Actors.Approvers[0].ID = "mallory"
+	err = v.Verify(facts, bundle)
+	targetErr := &schema.ErrInvalidPayloadContents{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, "bob is not a trusted approver", targetErr.Msg)
+}
+
+// A grant must not be accepted without an approval if the decision required one.
+func TestAutoApproveRejectsDecisionRequiringApproval(t *testing.T) {
+	ctx := context.Background()
+	userID := "alice"
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := serveractions.New(&schema.LocalSigner{PrivateKey: kp["server"].Private})
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+
+	bundle := makeDecisionBundle(t, kp, userID, schema.Decision{RequireApproval: true})
+	bundle, err = server.CreateGrant(ctx, bundle, schema.Grant{
+		Type:      schema.GrantTypeAWS,
+		ExpiresAt: time.Now().Add(time.Hour),
+		AWS:       &schema.AWSGrant{RoleARN: testRoleARN},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the grant is inconsistent with a decision which required approval,
+	// so it is rejected before the stage's rules are evaluated.
+	v := AutoApproveDecisionVerifier{}
+	err = v.Verify(facts, bundle)
+	targetErr := &schema.ErrInconsistentBundle{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, "grant was created without the required approval", targetErr.Msg)
+}
+
+func TestGrantMustMatchRequestedRole(t *testing.T) {
+	ctx := context.Background()
+	userID := "alice"
+
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := serveractions.New(&schema.LocalSigner{PrivateKey: kp["server"].Private})
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+
+	bundle := makeDecisionBundle(t, kp, userID, schema.Decision{AutoAllow: true})
+	bundle, err = server.CreateGrant(ctx, bundle, schema.Grant{
+		Type:      schema.GrantTypeAWS,
+		ExpiresAt: time.Now().Add(time.Hour),
+		AWS:       &schema.AWSGrant{RoleARN: "arn:aws:iam::123456789012:role/admin-role"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := AutoApproveDecisionVerifier{}
+	err = v.Verify(facts, bundle)
+	targetErr := &schema.ErrInconsistentBundle{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, "grant role arn:aws:iam::123456789012:role/admin-role does not match requested role arn:aws:iam::123456789012:role/test-role", targetErr.Msg)
+}
+
+func TestApprovedDecisionApproverGroup(t *testing.T) {
+	ctx := context.Background()
+	userID := "alice"
+	approverIDs := []string{"bob", "carol", "dave", "erin", "frank"}
+	kp, err := MakeTestKeyPairs(append([]string{"user", "server"}, approverIDs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := serveractions.New(&schema.LocalSigner{PrivateKey: kp["server"].Private})
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+			ApproverGroups: []schema.ApproverGroup{
+				{
+					Name:        "production",
+					ApproverIDs: approverIDs,
+					Threshold:   2,
+				},
+			},
+		},
+	}
+	for _, id := range approverIDs {
+		facts.Actors.Approvers = append(facts.Actors.Approvers, schema.Approver{ID: id, PublicKey: kp[id].Public})
+	}
+
+	bob := approveractions.New("bob", &schema.LocalSigner{PrivateKey: kp["bob"].Private})
+	dave := approveractions.New("dave", &schema.LocalSigner{PrivateKey: kp["dave"].Private})
+
+	bundle := makeDecisionBundle(t, kp, userID, schema.Decision{RequireApproval: true, ApproverGroup: "production"})
+	bundle, err = bob.Approve(ctx, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grant := schema.Grant{
+		Type:      schema.GrantTypeAWS,
+		ExpiresAt: time.Now().Add(time.Hour),
+		AWS:       &schema.AWSGrant{RoleARN: testRoleARN},
+	}
+
+	// a single approval isn't enough to meet the group's threshold
+	onlyBob, err := server.CreateGrant(ctx, bundle, grant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := ApprovedDecisionVerifier{}
+	err = v.Verify(facts, onlyBob)
+	targetErr := &schema.ErrThresholdNotMet{}
+	assert.ErrorAs(t, err, &targetErr)
+
+	bundle, err = dave.CounterSign(ctx, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = server.CreateGrant(ctx, bundle, grant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Verify(facts, bundle)
+	assert.NoError(t, err)
+}
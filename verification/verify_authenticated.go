@@ -1,41 +1,37 @@
 package verification
 
 import (
-	"crypto/ecdsa"
-	"errors"
-
 	"github.com/common-fate/attestations/schema"
 	"github.com/common-fate/attestations/types"
 )
 
-type AuthenticationStage struct{}
-
-// Verify payloads for the authentication stage
+// AuthenticationStageSpec verifies payloads for the authentication stage,
+// before the user has countersigned the AUTHENTICATED envelope.
 //
 // Rules:
 // - there must only be two envelopes
+// - each envelope must be linked to the previous envelope
 // - first envelope:
 // 		type: must be granted.dev/Init/v0.1
 //		signatures: user and identity server
-//		payload: public key and user ID must match user
+//		payload: public key must match user and must not be revoked
 // - second envelope:
 // 		type: must be granted.dev/Authenticated/v0.1
 //		signatures: identity server
-//		payload:
-func (s *AuthenticationStage) Verify(f schema.Facts, b schema.Bundle) error {
-	if len(b) != 2 {
-		return errors.New("bundle did not contain 2 envelopes")
-	}
-
-	err := VerifyEnvelope(f, b[0], types.PayloadInit, []ecdsa.PublicKey{f.Actors.User.PublicKey, f.Actors.IdentityServer.PublicKey})
-	if err != nil {
-		return err
-	}
+//		payload: user ID must match user
+var AuthenticationStageSpec = Stage{
+	Name: "authentication",
+	Envelopes: []EnvelopeSpec{
+		{Type: types.PayloadInit, Signers: []SignerRole{RoleUser, RoleIdentityServer}},
+		{Type: types.PayloadAuthenticated, Signers: []SignerRole{RoleIdentityServer}},
+	},
+}
 
-	err = VerifyEnvelope(f, b[1], types.PayloadAuthenticated, []ecdsa.PublicKey{f.Actors.IdentityServer.PublicKey})
-	if err != nil {
-		return err
-	}
+type AuthenticationStage struct {
+	VerifyOptions
+}
 
-	return nil
+// Verify payloads for the authentication stage. See AuthenticationStageSpec for the rules.
+func (s *AuthenticationStage) Verify(f schema.Facts, b schema.Bundle) error {
+	return verifyWithSpec(AuthenticationStageSpec, s.VerifyOptions, f, b)
 }
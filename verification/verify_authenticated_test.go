@@ -5,7 +5,6 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/x509"
 	"testing"
 	"time"
 
@@ -22,14 +21,14 @@ func TestAuthProcess(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	userPub := userPriv.PublicKey
+	userPub := &schema.ECDSAPublicKey{Key: &userPriv.PublicKey}
 
 	idServerPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 
 	if err != nil {
 		t.Fatal(err)
 	}
-	idServerPub := idServerPriv.PublicKey
+	idServerPub := &schema.ECDSAPublicKey{Key: &idServerPriv.PublicKey}
 
 	user := clientactions.New(&schema.LocalSigner{PrivateKey: userPriv})
 	server := serveractions.New(&schema.LocalSigner{PrivateKey: idServerPriv})
@@ -75,14 +74,14 @@ func TestAuthProcessInvalidUserID(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	userPub := userPriv.PublicKey
+	userPub := &schema.ECDSAPublicKey{Key: &userPriv.PublicKey}
 
 	idServerPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 
 	if err != nil {
 		t.Fatal(err)
 	}
-	idServerPub := idServerPriv.PublicKey
+	idServerPub := &schema.ECDSAPublicKey{Key: &idServerPriv.PublicKey}
 
 	user := clientactions.New(&schema.LocalSigner{PrivateKey: userPriv})
 	server := serveractions.New(&schema.LocalSigner{PrivateKey: idServerPriv})
@@ -130,20 +129,20 @@ func TestAuthProcessInvalidUserPublicKey(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	userPub := userPriv.PublicKey
+	userPub := &schema.ECDSAPublicKey{Key: &userPriv.PublicKey}
 
 	idServerPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 
 	if err != nil {
 		t.Fatal(err)
 	}
-	idServerPub := idServerPriv.PublicKey
+	idServerPub := &schema.ECDSAPublicKey{Key: &idServerPriv.PublicKey}
 
 	differentKeyPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
 		t.Fatal(err)
 	}
-	differentKey := differentKeyPriv.PublicKey
+	differentKey := &schema.ECDSAPublicKey{Key: &differentKeyPriv.PublicKey}
 
 	user := clientactions.New(&schema.LocalSigner{PrivateKey: userPriv})
 	server := serveractions.New(&schema.LocalSigner{PrivateKey: idServerPriv})
@@ -196,7 +195,7 @@ func TestAuthProcessMissingServerSignature(t *testing.T) {
 
 	testBundle := []TestEnvelope{
 		{
-			Payload:  schema.NewInitMessage(mustSerializeECDSAPublicKey(kp["user"].Public)),
+			Payload:  schema.NewInitMessage(mustSerializePublicKey(kp["user"].Public), mustGenerateNonce(), time.Now()),
 			SignedBy: []string{"user"},
 		},
 		{
@@ -229,14 +228,73 @@ func TestAuthProcessMissingServerSignature(t *testing.T) {
 	err = a.Verify(facts, bundle)
 	targetErr := &schema.ErrMissingSignatures{}
 	assert.ErrorAs(t, err, &targetErr)
-	assert.Equal(t, []ecdsa.PublicKey{kp["server"].Public}, targetErr.MissingKeys)
+	assert.Equal(t, []schema.ExpectedSigner{{Name: schema.ActorIdentityServer, PublicKey: kp["server"].Public}}, targetErr.Missing)
 }
 
 // panics if the key didn't serialize properly
-func mustSerializeECDSAPublicKey(key ecdsa.PublicKey) []byte {
-	bytes, err := x509.MarshalPKIXPublicKey(&key)
+func mustSerializePublicKey(key schema.PublicKey) []byte {
+	bytes, err := schema.MarshalPublicKey(key)
 	if err != nil {
 		panic(err)
 	}
 	return bytes
 }
+
+// Users may sign with Ed25519 device keys, while the identity server uses P-384.
+func TestAuthProcessMixedAlgorithms(t *testing.T) {
+	userID := "alice"
+	kp, err := MakeTestKeyPairsWithAlgorithms(map[string]schema.Algorithm{
+		"user":   schema.AlgorithmEdDSA,
+		"server": schema.AlgorithmES384,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := clientactions.New(&schema.LocalSigner{PrivateKey: kp["user"].Private})
+	server := serveractions.New(&schema.LocalSigner{PrivateKey: kp["server"].Private})
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+	ctx := context.Background()
+
+	bundle, err := user.Init(ctx, kp["user"].Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err = server.Authenticate(ctx, bundle[0], schema.AuthMessageOpts{
+		Time:   time.Now(),
+		UserID: userID,
+		Claims: map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := AuthenticationStage{}
+	err = a.Verify(facts, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, schema.AlgorithmEdDSA, bundle[0].Signatures[0].Algorithm)
+	assert.Equal(t, schema.AlgorithmES384, bundle[1].Signatures[0].Algorithm)
+}
+
+// panics if a nonce couldn't be generated
+func mustGenerateNonce() string {
+	nonce, err := schema.GenerateNonce()
+	if err != nil {
+		panic(err)
+	}
+	return nonce
+}
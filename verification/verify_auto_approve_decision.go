@@ -1,68 +1,53 @@
 package verification
 
 import (
-	"crypto/ecdsa"
-	"errors"
-
 	"github.com/common-fate/attestations/schema"
 	"github.com/common-fate/attestations/types"
 )
 
-type AutoApproveDecisionVerifier struct{}
-
-// Verify payloads for the access request stage
+// AutoApproveDecisionStageSpec verifies payloads for a grant which was
+// created after the identity server automatically allowed the request.
 //
 // Rules:
-// - there must only be 3 envelopes
+// - there must only be 5 envelopes
+// - each envelope must be linked to the previous envelope
 // - first envelope:
 // 		type: must be granted.dev/Init/v0.1
 //		signatures: user and identity server
-//		payload: public key and user ID must match user
+//		payload: public key must match user and must not be revoked
 // - second envelope:
 // 		type: must be granted.dev/Authenticated/v0.1
-//		signatures: identity server
+//		signatures: user and identity server
 //		payload: user ID must match user
 // - third envelope:
-// 		type: must be granted.dev/AccessRequest/v0.1
+// 		type: must be granted.dev/AccessRequest/v0.2
 //		signatures: user and identity server
 //		payload:
 // - fourth envelope:
-// 		type: must be granted.dev/Decision/v0.1
+// 		type: must be granted.dev/Decision/v0.2
 //		signatures: identity server
-//		payload:
+//		payload: decision must be automatically allowed without requiring approval
 // - fifth envelope:
-// 		type: must be granted.dev/GrantCreated/v0.1
+// 		type: must be granted.dev/GrantCreated/v0.2
 //		signatures: identity server
-//		payload:
-func (s *AutoApproveDecisionVerifier) Verify(f schema.Facts, b schema.Bundle) error {
-	if len(b) != 5 {
-		return errors.New("bundle did not contain 4 envelopes")
-	}
-
-	err := VerifyEnvelope(f, b[0], types.PayloadInit, []ecdsa.PublicKey{f.Actors.User.PublicKey, f.Actors.IdentityServer.PublicKey})
-	if err != nil {
-		return err
-	}
-
-	err = VerifyEnvelope(f, b[1], types.PayloadAuthenticated, []ecdsa.PublicKey{f.Actors.User.PublicKey, f.Actors.IdentityServer.PublicKey})
-	if err != nil {
-		return err
-	}
-
-	err = VerifyEnvelope(f, b[2], types.PayloadAccessRequest, []ecdsa.PublicKey{f.Actors.User.PublicKey, f.Actors.IdentityServer.PublicKey})
-	if err != nil {
-		return err
-	}
-
-	err = VerifyEnvelope(f, b[3], types.PayloadDecision, []ecdsa.PublicKey{f.Actors.IdentityServer.PublicKey})
-	if err != nil {
-		return err
-	}
+//		payload: grant must be for the requested role and must not have expired
+var AutoApproveDecisionStageSpec = Stage{
+	Name: "autoApproveDecision",
+	Envelopes: []EnvelopeSpec{
+		{Type: types.PayloadInit, Signers: []SignerRole{RoleUser, RoleIdentityServer}},
+		{Type: types.PayloadAuthenticated, Signers: []SignerRole{RoleUser, RoleIdentityServer}},
+		{Type: types.PayloadAccessRequest, Signers: []SignerRole{RoleUser, RoleIdentityServer}},
+		{Type: types.PayloadDecision, Signers: []SignerRole{RoleIdentityServer}},
+		{Type: types.PayloadGrantCreated, Signers: []SignerRole{RoleIdentityServer}},
+	},
+	Rules: []string{RuleDecisionAutoAllowed, RuleGrantActive},
+}
 
-	err = VerifyEnvelope(f, b[4], types.PayloadGrantCreated, []ecdsa.PublicKey{f.Actors.IdentityServer.PublicKey})
-	if err != nil {
-		return err
-	}
+type AutoApproveDecisionVerifier struct {
+	VerifyOptions
+}
 
-	return nil
+// Verify payloads for an automatically approved grant. See AutoApproveDecisionStageSpec for the rules.
+func (s *AutoApproveDecisionVerifier) Verify(f schema.Facts, b schema.Bundle) error {
+	return verifyWithSpec(AutoApproveDecisionStageSpec, s.VerifyOptions, f, b)
 }
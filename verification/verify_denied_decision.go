@@ -0,0 +1,48 @@
+package verification
+
+import (
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+)
+
+// DeniedDecisionStageSpec verifies payloads for a request which the identity server denied.
+// A denied bundle is terminal: no grant or approval may follow the decision.
+//
+// Rules:
+// - there must only be 4 envelopes
+// - each envelope must be linked to the previous envelope
+// - first envelope:
+// 		type: must be granted.dev/Init/v0.1
+//		signatures: user and identity server
+//		payload: public key must match user and must not be revoked
+// - second envelope:
+// 		type: must be granted.dev/Authenticated/v0.1
+//		signatures: user and identity server
+//		payload: user ID must match user
+// - third envelope:
+// 		type: must be granted.dev/AccessRequest/v0.2
+//		signatures: user and identity server
+//		payload:
+// - fourth envelope:
+// 		type: must be granted.dev/Decision/v0.2
+//		signatures: identity server
+//		payload: decision must deny access, giving a reason
+var DeniedDecisionStageSpec = Stage{
+	Name: "deniedDecision",
+	Envelopes: []EnvelopeSpec{
+		{Type: types.PayloadInit, Signers: []SignerRole{RoleUser, RoleIdentityServer}},
+		{Type: types.PayloadAuthenticated, Signers: []SignerRole{RoleUser, RoleIdentityServer}},
+		{Type: types.PayloadAccessRequest, Signers: []SignerRole{RoleUser, RoleIdentityServer}},
+		{Type: types.PayloadDecision, Signers: []SignerRole{RoleIdentityServer}},
+	},
+	Rules: []string{RuleDecisionDenied},
+}
+
+type DeniedDecisionVerifier struct {
+	VerifyOptions
+}
+
+// Verify payloads for a denied request. See DeniedDecisionStageSpec for the rules.
+func (s *DeniedDecisionVerifier) Verify(f schema.Facts, b schema.Bundle) error {
+	return verifyWithSpec(DeniedDecisionStageSpec, s.VerifyOptions, f, b)
+}
@@ -0,0 +1,56 @@
+package verification
+
+import (
+	"testing"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeniedDecision(t *testing.T) {
+	userID := "alice"
+
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+
+	bundle := makeDecisionBundle(t, kp, userID, schema.Decision{
+		Outcome: schema.DecisionDeny,
+		Reason:  "production access requires an incident",
+		RuleID:  "prod-requires-incident",
+		Message: "Please link an incident ticket to your request",
+	})
+
+	v := DeniedDecisionVerifier{}
+	err = v.Verify(facts, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := schema.DeserializePayload(bundle[3].Payload, types.PayloadDecision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "prod-requires-incident", p.(*schema.DecisionPayload).Decision.RuleID)
+
+	// an allowed decision can't be presented as a denial
+	bundle = makeDecisionBundle(t, kp, userID, schema.Decision{Outcome: schema.DecisionAllow})
+	err = v.Verify(facts, bundle)
+	targetErr := &schema.ErrInvalidPayloadContents{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, "decision did not deny access", targetErr.Msg)
+}
@@ -1,28 +1,144 @@
 package verification
 
 import (
-	"crypto/ecdsa"
-
 	"github.com/common-fate/attestations/schema"
 	"github.com/common-fate/attestations/types"
 )
 
 var AccessRequestBundle = []types.Payload{types.PayloadInit, types.PayloadAuthenticated, types.PayloadAccessRequest}
 
-func VerifyEnvelope(f schema.Facts, e schema.Envelope, expectedType types.Payload, signedBy []ecdsa.PublicKey) error {
+func VerifyEnvelope(f schema.Facts, e schema.Envelope, expectedType types.Payload, signedBy []schema.ExpectedSigner) error {
+	return VerifyEnvelopeWithVersions(f, e, expectedType, signedBy, schema.SigningVersionDSSEv1)
+}
+
+// VerifyEnvelopeWithVersions is the same as VerifyEnvelope, but accepts signatures
+// made using any of the provided signing versions.
+func VerifyEnvelopeWithVersions(f schema.Facts, e schema.Envelope, expectedType types.Payload, signedBy []schema.ExpectedSigner, versions ...schema.SigningVersion) error {
 	payload, err := schema.DeserializePayload(e.Payload, expectedType)
 	if err != nil {
 		return err
 	}
 
 	// validate signatures
-	err = e.VerifySignatures(signedBy)
+	err = e.VerifySignaturesWithVersions(signedBy, versions...)
 	if err != nil {
 		return err
 	}
 
 	// validate payload contents
-	return payload.ValidateContents(f)
+	err = payload.ValidateContents(f)
+	if err != nil {
+		return err
+	}
+	return validateFreshness(f, payload, nil)
+}
+
+// VerifyEnvelopeThreshold verifies an envelope which must be signed by at least a threshold
+// of candidate signers, such as a decision or approval which requires several approvers.
+// The result reports which of the candidates signed the envelope.
+// If no signing versions are provided, only DSSE signatures are accepted.
+func VerifyEnvelopeThreshold(f schema.Facts, e schema.Envelope, expectedType types.Payload, policy schema.ThresholdPolicy, versions ...schema.SigningVersion) (*schema.ThresholdResult, error) {
+	if len(versions) == 0 {
+		versions = []schema.SigningVersion{schema.SigningVersionDSSEv1}
+	}
+
+	payload, err := schema.DeserializePayload(e.Payload, expectedType)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate signatures
+	result, err := e.VerifyThresholdWithVersions(policy, versions...)
+	if err != nil {
+		return result, err
+	}
+
+	// validate payload contents
+	err = payload.ValidateContents(f)
+	if err != nil {
+		return result, err
+	}
+	return result, validateFreshness(f, payload, nil)
+}
+
+// signingVersions returns the signing versions accepted by a stage.
+// Legacy signatures over the raw payload are only accepted if the stage
+// has explicitly opted in to them.
+func signingVersions(acceptLegacy bool) []schema.SigningVersion {
+	if acceptLegacy {
+		return []schema.SigningVersion{schema.SigningVersionDSSEv1, schema.SigningVersionLegacy}
+	}
+	return []schema.SigningVersion{schema.SigningVersionDSSEv1}
+}
+
+// verifyChain verifies that each envelope in the bundle is linked to the envelope before it.
+// reqs are the signers which were required on each envelope.
+func verifyChain(b schema.Bundle, reqs []signerRequirement, acceptLegacy bool) error {
+	for i := range b {
+		err := verifyLink(b, i, reqs[i], acceptLegacy)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyLink verifies that the envelope at index i of the bundle is linked to the envelope before it.
+//
+// Envelopes signed before chaining was introduced aren't linked, so when legacy signatures are
+// accepted the link isn't checked for envelopes which were signed the legacy way or which don't
+// refer to a previous envelope. Every other envelope must be linked, so DSSE-signed envelopes
+// can't be spliced into another bundle just because legacy signatures are accepted.
+func verifyLink(b schema.Bundle, i int, req signerRequirement, acceptLegacy bool) error {
+	if acceptLegacy {
+		link, err := b[i].Link()
+		if err != nil {
+			return err
+		}
+		if link.Previous == "" {
+			return nil
+		}
+		legacy, err := legacySigned(b[i], req)
+		if err != nil {
+			return err
+		}
+		if legacy {
+			return nil
+		}
+	}
+	return b.VerifyLink(i)
+}
+
+// legacySigned reports whether any of the signers required on an envelope signed it the
+// legacy way, over the raw payload rather than the DSSE pre-authentication encoding.
+func legacySigned(e schema.Envelope, req signerRequirement) (bool, error) {
+	signers := req.signers
+	for _, policy := range req.thresholds {
+		signers = append(signers[:len(signers):len(signers)], policy.Candidates...)
+	}
+	for _, signer := range signers {
+		status, err := e.VerifySigner(signer, schema.SigningVersionDSSEv1)
+		if err != nil {
+			return false, err
+		}
+		if status == schema.SignatureValid {
+			continue
+		}
+		status, err = e.VerifySigner(signer, schema.SigningVersionLegacy)
+		if err != nil {
+			return false, err
+		}
+		if status == schema.SignatureValid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// verifyWithSpec verifies a bundle against a copy of the stage spec, using the verifier's options.
+func verifyWithSpec(spec Stage, opts VerifyOptions, f schema.Facts, b schema.Bundle) error {
+	spec.VerifyOptions = opts
+	return spec.Verify(f, b)
 }
 
 // ParseBundleNoVerification verifies that a bundle contains an expected set of payloads,
@@ -0,0 +1,67 @@
+package verification
+
+import (
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/types"
+)
+
+// GrantLifecycleTrailingSpec allows a grant's lifecycle records to follow the grant.
+// Each record must be signed by the identity server.
+var GrantLifecycleTrailingSpec = TrailingSpec{
+	Types:   []types.Payload{types.PayloadGrantExtended, types.PayloadGrantRevoked, types.PayloadGrantExpired},
+	Signers: []SignerRole{RoleIdentityServer},
+}
+
+// AutoApproveGrantLifecycleStageSpec verifies the full audit trail of an automatically
+// approved grant, which may have been extended, revoked or marked as expired.
+//
+// Rules:
+// - the first 5 envelopes must match AutoApproveDecisionStageSpec,
+//   except that the grant may have expired or been revoked
+// - any further envelopes:
+// 		type: must be granted.dev/GrantExtended/v0.1, granted.dev/GrantRevoked/v0.1
+//		or granted.dev/GrantExpired/v0.1
+//		signatures: identity server
+//		payload: grant must not already have been revoked or marked as expired
+var AutoApproveGrantLifecycleStageSpec = Stage{
+	Name:      "autoApproveGrantLifecycle",
+	Envelopes: AutoApproveDecisionStageSpec.Envelopes,
+	Trailing:  &GrantLifecycleTrailingSpec,
+	Rules:     []string{RuleDecisionAutoAllowed},
+}
+
+// ApprovedGrantLifecycleStageSpec verifies the full audit trail of a grant which was
+// approved by an approver, which may have been extended, revoked or marked as expired.
+//
+// Rules:
+// - the first 6 envelopes must match ApprovedDecisionStageSpec,
+//   except that the grant may have expired or been revoked
+// - any further envelopes:
+// 		type: must be granted.dev/GrantExtended/v0.1, granted.dev/GrantRevoked/v0.1
+//		or granted.dev/GrantExpired/v0.1
+//		signatures: identity server
+//		payload: grant must not already have been revoked or marked as expired
+var ApprovedGrantLifecycleStageSpec = Stage{
+	Name:      "approvedGrantLifecycle",
+	Envelopes: ApprovedDecisionStageSpec.Envelopes,
+	Trailing:  &GrantLifecycleTrailingSpec,
+	Rules:     []string{RuleDecisionRequiresApproval, RuleApprovalMatchesRequest},
+}
+
+type AutoApproveGrantLifecycleVerifier struct {
+	VerifyOptions
+}
+
+// Verify payloads for the lifecycle of an automatically approved grant. See AutoApproveGrantLifecycleStageSpec for the rules.
+func (s *AutoApproveGrantLifecycleVerifier) Verify(f schema.Facts, b schema.Bundle) error {
+	return verifyWithSpec(AutoApproveGrantLifecycleStageSpec, s.VerifyOptions, f, b)
+}
+
+type ApprovedGrantLifecycleVerifier struct {
+	VerifyOptions
+}
+
+// Verify payloads for the lifecycle of an approved grant. See ApprovedGrantLifecycleStageSpec for the rules.
+func (s *ApprovedGrantLifecycleVerifier) Verify(f schema.Facts, b schema.Bundle) error {
+	return verifyWithSpec(ApprovedGrantLifecycleStageSpec, s.VerifyOptions, f, b)
+}
@@ -0,0 +1,217 @@
+package verification
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/serveractions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantLifecycle(t *testing.T) {
+	ctx := context.Background()
+	userID := "alice"
+
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := serveractions.New(&schema.LocalSigner{PrivateKey: kp["server"].Private})
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+
+	expires := time.Now().Add(time.Hour)
+	bundle := makeDecisionBundle(t, kp, userID, schema.Decision{AutoAllow: true})
+	bundle, err = server.CreateGrant(ctx, bundle, schema.Grant{
+		Type:      schema.GrantTypeAWS,
+		ExpiresAt: expires,
+		AWS:       &schema.AWSGrant{RoleARN: testRoleARN},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := AutoApproveGrantLifecycleVerifier{}
+
+	// a bundle ending in the grant is at the start of its lifecycle
+	err = v.Verify(facts, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err = server.ExtendGrant(ctx, bundle, schema.GrantExtension{
+		ExtendedAt: time.Now(),
+		ExpiresAt:  expires.Add(time.Hour),
+		ExtendedBy: "bob",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Verify(facts, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the extension moves the expiry which the grantActive rule checks
+	facts.Time = expires.Add(time.Minute)
+	err = (&AutoApproveDecisionVerifier{}).Verify(facts, bundle[:5])
+	assert.EqualError(t, err, "grant is expired")
+	err = verifyWithSpec(Stage{
+		Name:      "activeGrant",
+		Envelopes: AutoApproveDecisionStageSpec.Envelopes,
+		Trailing:  &GrantLifecycleTrailingSpec,
+		Rules:     []string{RuleGrantActive},
+	}, VerifyOptions{}, facts, bundle)
+	assert.NoError(t, err)
+
+	bundle, err = server.RevokeGrant(ctx, bundle, schema.GrantRevocation{
+		RevokedAt: facts.Time,
+		RevokedBy: "bob",
+		Reason:    "incident resolved",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Verify(facts, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing may follow a revocation
+	expiry := schema.NewGrantExpiredPayload(schema.GrantExpiry{ExpiredAt: expires.Add(time.Hour)})
+	_, err = server.ExpireGrant(ctx, bundle, expiry.Expiry)
+	targetErr := &schema.ErrInconsistentBundle{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, "grant was already revoked", targetErr.Msg)
+
+	env, err := schema.NewChainedEnvelope(bundle, expiry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Sign(ctx, &schema.LocalSigner{PrivateKey: kp["server"].Private})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = v.Verify(facts, append(bundle, env))
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, "grant was already revoked", targetErr.Msg)
+}
+
+func TestGrantLifecycleRejectsOtherTrailingTypes(t *testing.T) {
+	ctx := context.Background()
+	userID := "alice"
+
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := serveractions.New(&schema.LocalSigner{PrivateKey: kp["server"].Private})
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+
+	bundle := makeDecisionBundle(t, kp, userID, schema.Decision{AutoAllow: true})
+	grant := schema.Grant{
+		Type:      schema.GrantTypeAWS,
+		ExpiresAt: time.Now().Add(time.Hour),
+		AWS:       &schema.AWSGrant{RoleARN: testRoleARN},
+	}
+	bundle, err = server.CreateGrant(ctx, bundle, grant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a second grant can't be appended to the bundle
+	bundle, err = server.CreateGrant(ctx, bundle, grant)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = (&AutoApproveGrantLifecycleVerifier{}).Verify(facts, bundle)
+	assert.EqualError(t, err, "envelope 5 has type granted.dev/GrantCreated/v0.2, which is not allowed to follow the envelopes of stage autoApproveGrantLifecycle")
+
+	report := AutoApproveGrantLifecycleStageSpec.VerifyReport(facts, bundle)
+	assert.False(t, report.Valid)
+	assert.Len(t, report.Envelopes, 6)
+	assert.Equal(t, CheckFailed, report.Envelopes[5].Type.Status)
+}
+
+func TestGrantLifecycleAppendChecks(t *testing.T) {
+	ctx := context.Background()
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := serveractions.New(&schema.LocalSigner{PrivateKey: kp["server"].Private})
+
+	// lifecycle records can only follow a grant
+	bundle := makeDecisionBundle(t, kp, "alice", schema.Decision{AutoAllow: true})
+	_, err = server.RevokeGrant(ctx, bundle, schema.GrantRevocation{RevokedAt: time.Now(), RevokedBy: "bob"})
+	assert.EqualError(t, err, "bundle must end with a grant or a grant lifecycle record, but contained a granted.dev/Decision/v0.2 envelope")
+
+	startsAt := time.Now()
+	bundle, err = server.CreateGrant(ctx, bundle, schema.Grant{
+		Type:      schema.GrantTypeAWS,
+		StartsAt:  startsAt,
+		ExpiresAt: startsAt.Add(time.Hour),
+		AWS:       &schema.AWSGrant{RoleARN: testRoleARN},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// extensions can't make the grant last longer than the maximum duration
+	_, err = server.ExtendGrant(ctx, bundle, schema.GrantExtension{
+		ExtendedAt: startsAt,
+		ExpiresAt:  startsAt.Add(schema.MaxAccessRequestDuration + time.Minute),
+		ExtendedBy: "bob",
+	})
+	targetErr := &schema.ErrInconsistentBundle{}
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Equal(t, "extended grant duration 12h1m0s exceeds the maximum of 12h0m0s", targetErr.Msg)
+
+	bundle, err = server.ExtendGrant(ctx, bundle, schema.GrantExtension{
+		ExtendedAt: startsAt,
+		ExpiresAt:  startsAt.Add(schema.MaxAccessRequestDuration),
+		ExtendedBy: "bob",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, bundle, 6)
+
+	// a grant can't be extended after it expired
+	_, err = server.ExtendGrant(ctx, bundle, schema.GrantExtension{
+		ExtendedAt: startsAt.Add(schema.MaxAccessRequestDuration + time.Minute),
+		ExpiresAt:  startsAt.Add(schema.MaxAccessRequestDuration + time.Hour),
+		ExtendedBy: "bob",
+	})
+	assert.ErrorAs(t, err, &targetErr)
+	assert.Contains(t, targetErr.Msg, "grant was extended at")
+
+	// lifecycle records must be valid
+	_, err = server.ExpireGrant(ctx, bundle, schema.GrantExpiry{})
+	assert.ErrorAs(t, err, new(*schema.ErrInvalidPayloadContents))
+}
@@ -1,32 +1,33 @@
 package verification
 
 import (
-	"crypto/ecdsa"
-	"errors"
-
 	"github.com/common-fate/attestations/schema"
 	"github.com/common-fate/attestations/types"
 )
 
-type InitStage struct{}
-
-// Verify payloads for the authentication stage
+// InitStageSpec verifies payloads for the init stage, before the
+// identity server has countersigned the INIT envelope.
 //
 // Rules:
 // - there must only be one envelope
 // - first envelope:
 // 		type: must be granted.dev/Init/v0.1
-//		signatures: user and identity server
-//		payload: public key must match user
-func (s *InitStage) Verify(f schema.Facts, b schema.Bundle) error {
-	if len(b) != 1 {
-		return errors.New("bundle did not contain 1 envelopes")
-	}
+//		signatures: user
+//		payload: public key must match user and must not be revoked
+var InitStageSpec = Stage{
+	Name: "init",
+	Envelopes: []EnvelopeSpec{
+		{Type: types.PayloadInit, Signers: []SignerRole{RoleUser}},
+	},
+}
 
-	err := VerifyEnvelope(f, b[0], types.PayloadInit, []ecdsa.PublicKey{f.Actors.User.PublicKey})
-	if err != nil {
-		return err
-	}
+// InitStage verifies the init stage. It doesn't check whether the INIT envelope
+// has been replayed, which ReplayGuard must be used for.
+type InitStage struct {
+	VerifyOptions
+}
 
-	return nil
+// Verify payloads for the init stage. See InitStageSpec for the rules.
+func (s *InitStage) Verify(f schema.Facts, b schema.Bundle) error {
+	return verifyWithSpec(InitStageSpec, s.VerifyOptions, f, b)
 }
@@ -0,0 +1,108 @@
+package verification
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/common-fate/attestations/clientactions"
+	"github.com/common-fate/attestations/policy"
+	"github.com/common-fate/attestations/schema"
+	"github.com/common-fate/attestations/serveractions"
+	"github.com/common-fate/attestations/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyDecision(t *testing.T) {
+	ctx := context.Background()
+	userID := "alice"
+
+	kp, err := MakeTestKeyPairs([]string{"user", "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := policy.NewRuleList(policy.RuleListSpec{
+		ID: "aws-roles",
+		Rules: []policy.RoleRule{
+			{ID: "test", Role: "arn:aws:iam::*:role/test-*", Result: policy.Result{Outcome: schema.DecisionAllow}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := clientactions.New(&schema.LocalSigner{PrivateKey: kp["user"].Private})
+	server := serveractions.NewWithPolicy(&schema.LocalSigner{PrivateKey: kp["server"].Private}, p, &AccessRequestStageSpec)
+
+	facts := schema.Facts{
+		Actors: schema.Actors{
+			User: schema.User{
+				ID:        userID,
+				PublicKey: kp["user"].Public,
+			},
+			IdentityServer: schema.IdentityServer{
+				PublicKey: kp["server"].Public,
+			},
+		},
+	}
+
+	bundle, err := user.Init(ctx, kp["user"].Public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = server.Authenticate(ctx, bundle[0], schema.AuthMessageOpts{
+		Time:   time.Now(),
+		UserID: userID,
+		Claims: map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = user.CounterSignAuth(ctx, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err = user.RequestAccess(ctx, bundle, schema.AccessRequest{
+		Role:     testRoleARN,
+		Reason:   "test",
+		Duration: 2 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a server with a policy can't sign decisions which the policy didn't make
+	_, err = server.Decision(ctx, bundle, schema.Decision{Outcome: schema.DecisionAllow})
+	assert.ErrorIs(t, err, serveractions.ErrHasPolicy)
+
+	// the server refuses to decide on a bundle which doesn't verify
+	_, err = server.Decide(ctx, schema.Facts{Actors: schema.Actors{User: facts.Actors.User}}, bundle)
+	assert.Error(t, err)
+
+	bundle, err = server.Decide(ctx, facts, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := schema.DeserializePayload(bundle[3].Payload, types.PayloadDecision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := payload.(*schema.DecisionPayload).Decision
+	assert.Equal(t, schema.DecisionAllow, d.Outcome)
+	assert.Equal(t, "test", d.RuleID)
+	assert.Equal(t, "aws-roles", d.PolicyID)
+	assert.Equal(t, p.Digest(), d.PolicyDigest)
+
+	bundle, err = server.CreateGrant(ctx, bundle, schema.Grant{
+		Type:      schema.GrantTypeAWS,
+		ExpiresAt: time.Now().Add(time.Hour),
+		AWS:       &schema.AWSGrant{RoleARN: testRoleARN},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = (&AutoApproveDecisionVerifier{}).Verify(facts, bundle)
+	assert.NoError(t, err)
+}